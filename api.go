@@ -26,11 +26,22 @@ var (
 	// ErrAlreadyClosed is returned if a Backend implementation is already closed.
 	ErrAlreadyClosed = fmt.Errorf("already closed")
 
+	// ErrAlreadyExists is returned if the import path being added already exists.
+	ErrAlreadyExists = fmt.Errorf("already exists")
+
+	// ErrConflict is returned if a conditional write finds the import path at a
+	// revision other than the expected one.
+	ErrConflict = fmt.Errorf("revision conflict")
+
 	// ErrNotFound is returned if the import path cannot MockBackend found.
 	ErrNotFound = fmt.Errorf("not found")
 
 	// ErrNotSupported is returned if the Backend method is not supported by the implementation.
 	ErrNotSupported = fmt.Errorf("not supported")
+
+	// ErrNotReady is returned if a Backend implementation is not yet ready to
+	// serve vanity URL configurations.
+	ErrNotReady = fmt.Errorf("not ready")
 )
 
 // Backend implementations provide access to a vanity URL store.
@@ -43,7 +54,8 @@ type Backend interface {
 	// Get vanity URL configuration for a given import path
 	Get(ctx context.Context, importPath string) (vcs, vcsPath string, err error)
 
-	// Add a vanity URL configuration
+	// Add a vanity URL configuration; ErrAlreadyExists is returned if the
+	// import path already exists
 	Add(ctx context.Context, importPath, vcs, vcsPath string) error
 
 	// Remove a vanity URL configuration by it's key, the import path
@@ -56,6 +68,29 @@ type Backend interface {
 	Healthz(ctx context.Context) error
 }
 
+// PrefixGetter is an optional interface implemented by Backend implementations
+// that can efficiently find the vanity URL configuration whose import path is
+// the longest prefix of a path, e.g. "l7e.io/vanity/cmd/vanity" is resolved to
+// the configuration of "l7e.io/vanity".
+//
+// Backends that do not implement this interface are searched using GetPrefix,
+// which walks the path segments through EntryBackend.GetEntry or Backend.Get.
+type PrefixGetter interface {
+	// GetPrefix obtains the vanity URL configuration whose import path is the
+	// longest prefix of path.  ErrNotFound is returned if there is no such
+	// configuration.
+	GetPrefix(ctx context.Context, path string) (*Entry, error)
+}
+
+// ReadinessChecker is an optional interface implemented by Backend
+// implementations that can be healthy without being ready to serve vanity URL
+// configurations, e.g. while loading them.
+type ReadinessChecker interface {
+	// Readyz is a readiness check point for Kubernetes; ErrNotReady is
+	// returned until the implementation is ready.
+	Readyz(ctx context.Context) error
+}
+
 // Consumer is the interface whose implementations are provided to the
 // Backend.List() method which calls their OnEntry method with the vanity
 // entries found.
@@ -14,7 +14,8 @@
  * limitations under the License.
  */
 
-// Package apitest contains a helpful mock implementation of Backend.
+// Package apitest contains a helpful mock implementation of Backend, and the
+// tests every implementation of EntryBackend must pass.
 package apitest
 
 import (
@@ -51,6 +52,9 @@ func (b *MockBackend) Add(ctx context.Context, importPath, vcs, vcsPath string)
 	if b.Healthy != nil {
 		return b.Healthy
 	}
+	if _, ok := b.Urls[importPath]; ok {
+		return vanity.ErrAlreadyExists
+	}
 	b.Urls[importPath] = []string{vcs, vcsPath}
 	return nil
 }
@@ -0,0 +1,285 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package apitest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+)
+
+// A Factory creates an empty backend for a test, along with a function that
+// releases its resources once the test is done.
+type Factory func(t *testing.T) (vanity.EntryBackend, func())
+
+// TestEntryBackend runs the tests every EntryBackend must pass against the
+// backends created by factory; Apply is only tested if they are Batchers.
+func TestEntryBackend(t *testing.T, factory Factory) {
+	t.Run("Basic", func(t *testing.T) { testBasic(t, factory) })
+	t.Run("Entries", func(t *testing.T) { testEntries(t, factory) })
+	t.Run("UpdateAllFields", func(t *testing.T) { testUpdateAllFields(t, factory) })
+	t.Run("Apply", func(t *testing.T) { testApply(t, factory) })
+	t.Run("ListPage", func(t *testing.T) { testListPage(t, factory) })
+}
+
+// TestWatcher runs the tests every Watcher must pass against the backends
+// created by factory, which must notify of their own changes.
+func TestWatcher(t *testing.T, factory Factory) {
+	be, done := factory(t)
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := be.(vanity.Watcher).Watch(ctx, "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.NoError(t, be.Add(ctx, "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+	assert.NoError(t, be.UpsertEntry(ctx, vanity.NewEntry("l7e.io/vanity", "git", "https://gitlab.com/livetribe/vanity")))
+	assert.NoError(t, be.Remove(ctx, "l7e.io/vanity"))
+
+	for _, expected := range []vanity.EventType{vanity.EventAdded, vanity.EventUpdated, vanity.EventRemoved} {
+		select {
+		case e := <-events:
+			assert.Equal(t, expected, e.Type)
+			assert.Equal(t, "l7e.io/vanity", e.ImportPath)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s event", expected)
+		}
+	}
+
+	assert.NoError(t, be.Close())
+
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+func testBasic(t *testing.T, factory Factory) {
+	be, done := factory(t)
+	defer done()
+
+	ctx := context.Background()
+
+	assert.NoError(t, be.Add(ctx, "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+	assert.Equal(t, vanity.ErrAlreadyExists, be.Add(ctx, "l7e.io/vanity", "git", "https://github.com/livetribe/other"))
+
+	vcs, vcsPath, err := be.Get(ctx, "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, "git", vcs)
+	assert.Equal(t, "https://github.com/livetribe/vanity", vcsPath)
+
+	_, _, err = be.Get(ctx, "l7e.io/yama")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	e, err := vanity.GetPrefix(ctx, be, "l7e.io/vanity/cmd/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, "l7e.io/vanity", e.ImportPath)
+
+	_, err = vanity.GetPrefix(ctx, be, "l7e.io/vanityx")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	assert.NoError(t, be.Healthz(ctx))
+
+	assert.NoError(t, be.Remove(ctx, "l7e.io/vanity"))
+	assert.Equal(t, vanity.ErrNotFound, be.Remove(ctx, "l7e.io/vanity"))
+
+	assert.NoError(t, be.Close())
+	assert.Equal(t, vanity.ErrAlreadyClosed, be.Healthz(ctx))
+	assert.Equal(t, vanity.ErrAlreadyClosed, be.Add(ctx, "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+	assert.NoError(t, be.Close())
+}
+
+func testEntries(t *testing.T, factory Factory) {
+	be, done := factory(t)
+	defer done()
+
+	ctx := context.Background()
+
+	in := vanity.NewEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
+	in.Description = "Vanity URLs"
+	in.Visibility = vanity.VisibilityPublic
+	in.Labels = map[string]string{"team": "platform"}
+	assert.NoError(t, be.InsertEntry(ctx, in))
+	assert.Equal(t, vanity.ErrAlreadyExists, be.InsertEntry(ctx, in))
+
+	e, err := be.GetEntry(ctx, "l7e.io/vanity")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, int64(1), e.Revision)
+	assert.Equal(t, "Vanity URLs", e.Description)
+	assert.Equal(t, vanity.VisibilityPublic, e.Visibility)
+	assert.Equal(t, map[string]string{"team": "platform"}, e.Labels)
+	assert.False(t, e.Created.IsZero())
+	assert.True(t, e.Created.Equal(e.Updated))
+
+	// updates replace the entry, clearing the fields that are not set
+	e.VCSPath = "https://gitlab.com/livetribe/vanity"
+	e.Description = ""
+	assert.NoError(t, be.UpdateEntry(ctx, e, 1))
+	assert.Equal(t, vanity.ErrConflict, be.UpdateEntry(ctx, e, 1))
+
+	updated, err := be.GetEntry(ctx, "l7e.io/vanity")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, int64(2), updated.Revision)
+	assert.Equal(t, "https://gitlab.com/livetribe/vanity", updated.VCSPath)
+	assert.Empty(t, updated.Description)
+	assert.Equal(t, map[string]string{"team": "platform"}, updated.Labels)
+	assert.True(t, e.Created.Equal(updated.Created))
+
+	assert.Equal(t, vanity.ErrNotFound,
+		be.UpdateEntry(ctx, vanity.NewEntry("m4o.io/pbf", "git", "https://github.com/magurl/pbf"), vanity.AnyRevision))
+	assert.NoError(t, be.UpsertEntry(ctx, vanity.NewEntry("m4o.io/pbf", "git", "https://github.com/magurl/pbf")))
+	assert.NoError(t, be.UpsertEntry(ctx, vanity.NewEntry("m4o.io/pbf", "git", "https://gitlab.com/magurl/pbf")))
+
+	e, err = be.GetEntry(ctx, "m4o.io/pbf")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, int64(2), e.Revision)
+	assert.Equal(t, "https://gitlab.com/magurl/pbf", e.VCSPath)
+}
+
+func testUpdateAllFields(t *testing.T, factory Factory) {
+	be, done := factory(t)
+	defer done()
+
+	ctx := context.Background()
+
+	in := vanity.NewEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
+	in.Description = "Vanity URLs"
+	in.Owner = "platform"
+	in.DefaultBranch = "main"
+	in.DocURL = "https://docs.l7e.io/{import-path}"
+	in.SourceTemplate = "gitlab"
+	in.Visibility = vanity.VisibilityInternal
+	in.Labels = map[string]string{"team": "platform"}
+	assert.NoError(t, be.InsertEntry(ctx, in))
+
+	in.Description = "Vanity URL server"
+	assert.NoError(t, be.UpdateEntry(ctx, in, 1))
+	assert.NoError(t, be.UpsertEntry(ctx, in))
+
+	e, err := be.GetEntry(ctx, "l7e.io/vanity")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, int64(3), e.Revision)
+	assert.Equal(t, "Vanity URL server", e.Description)
+	assert.Equal(t, "platform", e.Owner)
+	assert.Equal(t, "main", e.DefaultBranch)
+	assert.Equal(t, "https://docs.l7e.io/{import-path}", e.DocURL)
+	assert.Equal(t, "gitlab", e.SourceTemplate)
+	assert.Equal(t, vanity.VisibilityInternal, e.Visibility)
+	assert.Equal(t, map[string]string{"team": "platform"}, e.Labels)
+}
+
+func testApply(t *testing.T, factory Factory) {
+	be, done := factory(t)
+	defer done()
+
+	b, ok := be.(vanity.Batcher)
+	if !ok {
+		t.Skip("not a Batcher")
+	}
+
+	ctx := context.Background()
+
+	assert.NoError(t, be.Add(ctx, "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+
+	err := b.Apply(ctx, []vanity.Mutation{
+		vanity.InsertMutation(vanity.NewEntry("l7e.io/yama", "git", "https://github.com/livetribe/yama")),
+		vanity.InsertMutation(vanity.NewEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")),
+	})
+	assert.Equal(t, &vanity.MutationError{Index: 1, Err: vanity.ErrAlreadyExists}, err)
+
+	// the failed batch was not applied
+	_, _, err = be.Get(ctx, "l7e.io/yama")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	err = b.Apply(ctx, []vanity.Mutation{
+		vanity.InsertMutation(vanity.NewEntry("l7e.io/yama", "git", "https://github.com/livetribe/yama")),
+		vanity.RemoveMutation("l7e.io/vanity"),
+	})
+	assert.NoError(t, err)
+
+	var paths []string
+	err = be.List(ctx, vanity.ConsumerFunc(func(_ context.Context, importPath, _, _ string) {
+		paths = append(paths, importPath)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"l7e.io/yama"}, paths)
+}
+
+func testListPage(t *testing.T, factory Factory) {
+	be, done := factory(t)
+	defer done()
+
+	ctx := context.Background()
+	assert.NoError(t, be.Add(ctx, "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+	assert.NoError(t, be.Add(ctx, "l7e.io/yama", "git", "https://github.com/livetribe/yama"))
+	assert.NoError(t, be.Add(ctx, "m4o.io/pbf", "git", "https://github.com/magurl/pbf"))
+	assert.NoError(t, be.Add(ctx, "example.com/tool", "git", "https://github.com/example/tool"))
+
+	list := func(opts vanity.ListOptions) ([]string, string) {
+		var paths []string
+		next, err := vanity.ListPage(ctx, be, opts, vanity.EntryConsumerFunc(func(_ context.Context, e *vanity.Entry) {
+			paths = append(paths, e.ImportPath)
+		}))
+		assert.NoError(t, err)
+		return paths, next
+	}
+
+	paths, next := list(vanity.ListOptions{})
+	assert.Equal(t, []string{"example.com/tool", "l7e.io/vanity", "l7e.io/yama", "m4o.io/pbf"}, paths)
+	assert.Empty(t, next)
+
+	opts := vanity.ListOptions{Prefix: "l7e.io/", PageSize: 1}
+	paths, opts.PageToken = list(opts)
+	assert.Equal(t, []string{"l7e.io/vanity"}, paths)
+	paths, next = list(opts)
+	assert.Equal(t, []string{"l7e.io/yama"}, paths)
+	assert.Empty(t, next)
+
+	opts = vanity.ListOptions{Prefix: "l7e.io/", PageSize: 1, Order: vanity.Descending}
+	paths, opts.PageToken = list(opts)
+	assert.Equal(t, []string{"l7e.io/yama"}, paths)
+	paths, next = list(opts)
+	assert.Equal(t, []string{"l7e.io/vanity"}, paths)
+	assert.Empty(t, next)
+
+	opts = vanity.ListOptions{PageSize: 3, Order: vanity.Descending}
+	paths, opts.PageToken = list(opts)
+	assert.Equal(t, []string{"m4o.io/pbf", "l7e.io/yama", "l7e.io/vanity"}, paths)
+	paths, next = list(opts)
+	assert.Equal(t, []string{"example.com/tool"}, paths)
+	assert.Empty(t, next)
+
+	paths, _ = list(vanity.ListOptions{Prefix: "n"})
+	assert.Empty(t, paths)
+
+	_, err := vanity.ListPage(ctx, be, vanity.ListOptions{PageToken: "!"}, vanity.EntryConsumerFunc(func(context.Context, *vanity.Entry) {}))
+	assert.Equal(t, vanity.ErrInvalidPageToken, err)
+}
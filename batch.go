@@ -0,0 +1,206 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MutationOp is the operation of a Mutation.
+type MutationOp int
+
+// Mutation operations.
+const (
+	// MutationInsert adds a vanity URL configuration, like EntryBackend.InsertEntry.
+	MutationInsert MutationOp = iota + 1
+
+	// MutationUpdate replaces a vanity URL configuration, like EntryBackend.UpdateEntry.
+	MutationUpdate
+
+	// MutationUpsert adds or replaces a vanity URL configuration, like EntryBackend.UpsertEntry.
+	MutationUpsert
+
+	// MutationRemove removes a vanity URL configuration, like Backend.Remove.
+	MutationRemove
+)
+
+var errInvalidMutation = fmt.Errorf("invalid mutation")
+
+// Mutation is a change to a vanity URL configuration, applied with other
+// mutations by Apply.
+type Mutation struct {
+	// Op is the operation.
+	Op MutationOp
+
+	// Entry is the configuration to be stored.  Only the import path is used
+	// by MutationRemove.
+	Entry *Entry
+
+	// IfRevision is the revision expected by MutationUpdate.
+	IfRevision int64
+}
+
+// InsertMutation creates a Mutation that adds entry.
+func InsertMutation(entry *Entry) Mutation {
+	return Mutation{Op: MutationInsert, Entry: entry}
+}
+
+// UpdateMutation creates a Mutation that replaces entry if it is at revision
+// ifRevision, or at any revision if ifRevision is AnyRevision.
+func UpdateMutation(entry *Entry, ifRevision int64) Mutation {
+	return Mutation{Op: MutationUpdate, Entry: entry, IfRevision: ifRevision}
+}
+
+// UpsertMutation creates a Mutation that adds or replaces entry.
+func UpsertMutation(entry *Entry) Mutation {
+	return Mutation{Op: MutationUpsert, Entry: entry}
+}
+
+// RemoveMutation creates a Mutation that removes the configuration of importPath.
+func RemoveMutation(importPath string) Mutation {
+	return Mutation{Op: MutationRemove, Entry: &Entry{ImportPath: importPath}}
+}
+
+// MutationError is returned by Apply when a mutation fails.
+type MutationError struct {
+	// Index is the index of the failed mutation.
+	Index int
+
+	// Err is the reason the mutation failed, e.g. ErrAlreadyExists.
+	Err error
+}
+
+func (e *MutationError) Error() string {
+	return fmt.Sprintf("mutation %d: %s", e.Index, e.Err)
+}
+
+// Batcher is an optional interface implemented by Backend implementations
+// that can apply several mutations atomically, e.g. in a single transaction.
+type Batcher interface {
+	// Apply applies mutations, in order, atomically; either all or none of
+	// them are applied.  A *MutationError is returned if a mutation fails.
+	Apply(ctx context.Context, mutations []Mutation) error
+}
+
+// Apply applies mutations, in order, to api.  If api implements Batcher, the
+// mutations are applied atomically; otherwise they are applied one at a time,
+// stopping at the first that fails, and the mutations preceding it remain
+// applied.  A *MutationError is returned if a mutation fails.
+func Apply(ctx context.Context, api Backend, mutations []Mutation) error {
+	if b, ok := api.(Batcher); ok {
+		return b.Apply(ctx, mutations)
+	}
+
+	eb := AsEntryBackend(api)
+
+	for i, m := range mutations {
+		var err error
+
+		switch {
+		case m.Entry == nil:
+			err = errInvalidMutation
+		case m.Op == MutationInsert:
+			err = eb.InsertEntry(ctx, m.Entry)
+		case m.Op == MutationUpdate:
+			err = eb.UpdateEntry(ctx, m.Entry, m.IfRevision)
+		case m.Op == MutationUpsert:
+			err = eb.UpsertEntry(ctx, m.Entry)
+		case m.Op == MutationRemove:
+			err = eb.Remove(ctx, m.Entry.ImportPath)
+		default:
+			err = errInvalidMutation
+		}
+
+		if err != nil {
+			return &MutationError{Index: i, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// ApplyMutations is a helper for Batcher implementations which applies
+// mutations, in order, to entries, the current configurations of the import
+// paths of the mutations; absent configurations are either missing or nil.
+//
+// When all the mutations succeed, entries holds the resulting configurations,
+// with their revisions and timestamps stamped using now, and nil for removed
+// configurations.  A *MutationError is returned if a mutation fails, in which
+// case entries is left in an unspecified state.
+func ApplyMutations(entries map[string]*Entry, mutations []Mutation, now time.Time) error {
+	for i, m := range mutations {
+		if err := applyMutation(entries, m, now); err != nil {
+			return &MutationError{Index: i, Err: err}
+		}
+	}
+
+	return nil
+}
+
+func applyMutation(entries map[string]*Entry, m Mutation, now time.Time) error {
+	if m.Entry == nil {
+		return errInvalidMutation
+	}
+
+	importPath := m.Entry.ImportPath
+	current := entries[importPath]
+
+	switch m.Op {
+	case MutationInsert:
+		if current != nil {
+			return ErrAlreadyExists
+		}
+	case MutationUpdate:
+		if current == nil {
+			return ErrNotFound
+		}
+		if m.IfRevision != AnyRevision && m.IfRevision != current.Revision {
+			return ErrConflict
+		}
+	case MutationUpsert:
+	case MutationRemove:
+		if current == nil {
+			return ErrNotFound
+		}
+		entries[importPath] = nil
+
+		return nil
+	default:
+		return errInvalidMutation
+	}
+
+	e := m.Entry.Clone()
+	if e.Version == 0 {
+		e.Version = EntryVersion
+	}
+	if current != nil {
+		e.Revision = current.Revision + 1
+		e.Created = current.Created
+	} else {
+		e.Revision = 1
+		if e.Created.IsZero() {
+			e.Created = now
+		}
+	}
+	e.Updated = now
+
+	entries[importPath] = e
+
+	return nil
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/pkg/memory"
+)
+
+func TestApplyMutations(t *testing.T) {
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	created := now.Add(-time.Hour)
+
+	current := vanity.NewEntry("a.com/b", "git", "https://github.com/b")
+	current.Revision = 4
+	current.Created = created
+
+	entries := map[string]*vanity.Entry{"a.com/b": current}
+
+	err := vanity.ApplyMutations(entries, []vanity.Mutation{
+		vanity.UpdateMutation(vanity.NewEntry("a.com/b", "git", "https://gitlab.com/b"), 4),
+		vanity.InsertMutation(vanity.NewEntry("a.com/c", "git", "https://github.com/c")),
+		vanity.UpsertMutation(vanity.NewEntry("a.com/c", "git", "https://gitlab.com/c")),
+		vanity.InsertMutation(vanity.NewEntry("a.com/d", "git", "https://github.com/d")),
+		vanity.RemoveMutation("a.com/d"),
+	}, now)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "https://gitlab.com/b", entries["a.com/b"].VCSPath)
+	assert.Equal(t, int64(5), entries["a.com/b"].Revision)
+	assert.Equal(t, created, entries["a.com/b"].Created)
+	assert.Equal(t, now, entries["a.com/b"].Updated)
+
+	assert.Equal(t, "https://gitlab.com/c", entries["a.com/c"].VCSPath)
+	assert.Equal(t, int64(2), entries["a.com/c"].Revision)
+	assert.Equal(t, now, entries["a.com/c"].Created)
+
+	d, ok := entries["a.com/d"]
+	assert.True(t, ok)
+	assert.Nil(t, d)
+}
+
+func TestApplyMutations_errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutation vanity.Mutation
+		expected error
+	}{
+		{"insert", vanity.InsertMutation(vanity.NewEntry("a.com/b", "git", "https://github.com/b")), vanity.ErrAlreadyExists},
+		{"update", vanity.UpdateMutation(vanity.NewEntry("a.com/z", "git", "https://github.com/z"), 0), vanity.ErrNotFound},
+		{"conflict", vanity.UpdateMutation(vanity.NewEntry("a.com/b", "git", "https://github.com/b"), 2), vanity.ErrConflict},
+		{"remove", vanity.RemoveMutation("a.com/z"), vanity.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		current := vanity.NewEntry("a.com/b", "git", "https://github.com/b")
+		current.Revision = 1
+
+		err := vanity.ApplyMutations(map[string]*vanity.Entry{"a.com/b": current},
+			[]vanity.Mutation{vanity.UpsertMutation(vanity.NewEntry("a.com/c", "git", "https://github.com/c")), tt.mutation},
+			time.Now())
+		assert.Equal(t, &vanity.MutationError{Index: 1, Err: tt.expected}, err, tt.name)
+	}
+}
+
+func TestApply_batcher(t *testing.T) {
+	be := memory.NewInMemoryAPI()
+	be.AddEntry("a.com/b", "git", "https://github.com/b")
+
+	err := vanity.Apply(context.Background(), be, []vanity.Mutation{
+		vanity.InsertMutation(vanity.NewEntry("a.com/c", "git", "https://github.com/c")),
+		vanity.InsertMutation(vanity.NewEntry("a.com/b", "git", "https://github.com/b")),
+	})
+	assert.Equal(t, &vanity.MutationError{Index: 1, Err: vanity.ErrAlreadyExists}, err)
+
+	// atomic, so nothing was applied
+	_, _, err = be.Get(context.Background(), "a.com/c")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	err = vanity.Apply(context.Background(), be, []vanity.Mutation{
+		vanity.InsertMutation(vanity.NewEntry("a.com/c", "git", "https://github.com/c")),
+		vanity.RemoveMutation("a.com/b"),
+	})
+	assert.NoError(t, err)
+
+	_, _, err = be.Get(context.Background(), "a.com/c")
+	assert.NoError(t, err)
+	_, _, err = be.Get(context.Background(), "a.com/b")
+	assert.Equal(t, vanity.ErrNotFound, err)
+}
+
+func TestApply_fallback(t *testing.T) {
+	mock := &apitest.MockBackend{Urls: map[string][]string{"a.com/b": {"git", "https://github.com/b"}}}
+
+	err := vanity.Apply(context.Background(), mock, []vanity.Mutation{
+		vanity.InsertMutation(vanity.NewEntry("a.com/c", "git", "https://github.com/c")),
+		vanity.UpdateMutation(vanity.NewEntry("a.com/b", "git", "https://gitlab.com/b"), vanity.AnyRevision),
+		vanity.RemoveMutation("a.com/z"),
+		vanity.InsertMutation(vanity.NewEntry("a.com/d", "git", "https://github.com/d")),
+	})
+	assert.Equal(t, &vanity.MutationError{Index: 2, Err: vanity.ErrNotFound}, err)
+	assert.Equal(t, "mutation 2: not found", err.Error())
+
+	// not atomic, so the preceding mutations remain applied
+	assert.Equal(t, map[string][]string{
+		"a.com/b": {"git", "https://gitlab.com/b"},
+		"a.com/c": {"git", "https://github.com/c"},
+	}, mock.Urls)
+}
@@ -23,33 +23,42 @@ import (
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 
+	"l7e.io/vanity"
+	"l7e.io/vanity/cmd/vanity/cli"
 	"l7e.io/vanity/cmd/vanity/cli/backends"
 	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
 	"l7e.io/vanity/cmd/vanity/cli/log"
 )
 
+var entryFlags cli.EntryFlags
+
 func init() { //nolint:gochecknoinits
 	helpers.AddCommand(func() *cobra.Command {
-		return &cobra.Command{
+		cmd := &cobra.Command{
 			Use:   "add <importPath> <vcs> <vcsPath>",
 			Short: "Add vanity URL",
 			Long:  "Add vanity URL",
 			Args:  cobra.ExactArgs(3), // nolint
 			Run:   addCmd,
 		}
+
+		entryFlags.AddFlags(cmd.Flags())
+
+		return cmd
 	})
 }
 
 func addCmd(_ *cobra.Command, args []string) {
-	importPath := args[0]
-	vcs := args[1]
-	vcsPath := args[2]
+	entry, err := entryFlags.NewEntry(args[0], args[1], args[2])
+	if err != nil {
+		glog.Exitf("Unable to add %s: %s", args[0], err)
+	}
 
-	glog.V(log.Debug).Infof("Adding %s %s %s...", importPath, vcs, vcsPath)
+	glog.V(log.Debug).Infof("Adding %s %s %s...", entry.ImportPath, entry.VCS, entry.VCSPath)
 
-	err := backends.Get().Add(context.Background(), importPath, vcs, vcsPath)
+	err = vanity.AsEntryBackend(backends.Get()).InsertEntry(context.Background(), entry)
 	if err != nil {
-		glog.Exitf("Unable to add %s %s %s: %s", importPath, vcs, vcsPath, err)
+		glog.Exitf("Unable to add %s %s %s: %s", entry.ImportPath, entry.VCS, entry.VCSPath, err)
 	}
 
 	glog.V(log.Debug).Info("Added")
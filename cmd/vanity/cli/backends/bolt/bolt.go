@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package bolt contains the bolt sub-command.
+package bolt
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/cmd/vanity/cli"
+	"l7e.io/vanity/cmd/vanity/cli/backends"
+	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
+	"l7e.io/vanity/cmd/vanity/cli/log"
+	be "l7e.io/vanity/pkg/bolt"
+)
+
+const (
+	file = "file"
+)
+
+var (
+	errUnableToGetFile = fmt.Errorf("unable to get file")
+	saved              vanity.Backend
+)
+
+func init() { //nolint:gochecknoinits
+	cli.RootCmd.AddCommand(Command)
+	helpers.RegisterBackend(Command)
+
+	flags := Command.PersistentFlags()
+	flags.StringP(file, "", "", "bbolt file, e.g. /var/lib/vanity/db")
+}
+
+// Command is the vanity sub-command for a bbolt backend.
+var Command = &cobra.Command{
+	Use:   "bolt",
+	Short: "Use local bbolt file for a vanity store",
+	Long:  "Use local bbolt file for a vanity store",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Set backend w/ bolt")
+		err := viper.BindPFlags(cmd.Flags())
+		if err != nil {
+			return fmt.Errorf(unableToBind, err)
+		}
+
+		beHelp := newHelper(cmd)
+		backend, err := beHelp.getBackend()
+		if err != nil {
+			return fmt.Errorf(unableToInstantiate, err)
+		}
+
+		saved = backends.Get()
+		backends.Set(backend)
+
+		return nil
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Clean backend of bolt")
+		defer func() {
+			backends.Set(saved)
+		}()
+		return backends.Get().Close()
+	},
+}
+
+type helper struct {
+	*cli.FlagSet
+}
+
+// newHelper wraps the Cobra command's flags with a utility wrapper to assist in
+// the creation of a bbolt-based backend.
+func newHelper(cmd *cobra.Command) *helper {
+	return &helper{FlagSet: cli.Flags(cmd)}
+}
+
+// getString returns the value of a flag from the command line or, if not set,
+// the bolt table of the configuration.
+func (h *helper) getString(name string) string {
+	if v, err := h.GetString(name); err == nil && v != "" {
+		return v
+	}
+
+	return viper.GetString("bolt." + name)
+}
+
+// getBackend returns a bbolt-based api.Backend instance, configured by the helper.
+func (h *helper) getBackend() (vanity.Backend, error) {
+	f := h.getString(file)
+	if f == "" {
+		return nil, errUnableToGetFile
+	}
+
+	glog.V(log.Debug).Infof("bbolt file: %s", f)
+
+	return be.NewBackend(f)
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dsn adds the generic --backend flag, which installs the backend
+// registered for the scheme of a data source name, to the root command.
+package dsn
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/cmd/vanity/cli"
+	"l7e.io/vanity/cmd/vanity/cli/backends"
+	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
+	"l7e.io/vanity/cmd/vanity/cli/log"
+)
+
+const (
+	backend = "backend"
+)
+
+var (
+	errNoBackend = fmt.Errorf("no backend specified, use --backend or a backend sub-command")
+	saved        vanity.Backend
+)
+
+func init() { //nolint:gochecknoinits
+	flags := cli.RootCmd.PersistentFlags()
+	flags.StringP(backend, "", "", "backend data source name, e.g. spanner://projects/p/instances/i/databases/d")
+	_ = viper.BindPFlag(backend, flags.Lookup(backend))
+	_ = viper.BindEnv(backend)
+
+	cli.RootCmd.PersistentPreRunE = preRun
+	cli.RootCmd.PersistentPostRunE = postRun
+
+	helpers.RegisterBackend(cli.RootCmd)
+}
+
+func preRun(cmd *cobra.Command, _ []string) error {
+	if _, ok := cmd.Annotations[helpers.RequiresBackend]; !ok {
+		return nil
+	}
+
+	err := viper.BindPFlags(cmd.Flags())
+	if err != nil {
+		return fmt.Errorf(unableToBind, err)
+	}
+
+	dsn, ok := cli.Flags(cmd).GetValue(backend)
+	if !ok || dsn == "" {
+		return errNoBackend
+	}
+	glog.V(log.Debug).Infof("Set backend w/ %s", strings.SplitN(dsn, ":", 2)[0])
+
+	be, err := vanity.Open(context.Background(), dsn)
+	if err != nil {
+		return fmt.Errorf(unableToOpen, err)
+	}
+
+	saved = backends.Get()
+	backends.Set(be)
+
+	return nil
+}
+
+func postRun(cmd *cobra.Command, _ []string) error {
+	if _, ok := cmd.Annotations[helpers.RequiresBackend]; !ok {
+		return nil
+	}
+
+	glog.V(log.Debug).Infoln("Clean backend")
+	defer func() {
+		backends.Set(saved)
+	}()
+
+	return backends.Get().Close()
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package etcd contains the etcd sub-command.
+package etcd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/cmd/vanity/cli"
+	"l7e.io/vanity/cmd/vanity/cli/backends"
+	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
+	"l7e.io/vanity/cmd/vanity/cli/log"
+	be "l7e.io/vanity/pkg/etcd"
+)
+
+const (
+	endpoints     = "endpoints"
+	user          = "user"
+	prefix        = "prefix"
+	certFile      = "cert-file"
+	keyFile       = "key-file"
+	trustedCAFile = "trusted-ca-file"
+)
+
+var (
+	errUnableToGetEndpoints = fmt.Errorf("unable to get endpoints")
+	errInvalidCAFile        = fmt.Errorf("no certificates found in trusted CA file")
+	saved                   vanity.Backend
+)
+
+func init() { //nolint:gochecknoinits
+	cli.RootCmd.AddCommand(Command)
+	helpers.RegisterBackend(Command)
+
+	flags := Command.PersistentFlags()
+	flags.StringP(endpoints, "", "", "comma separated etcd endpoints, e.g. localhost:2379")
+	_ = viper.BindPFlag(endpoints, flags.Lookup(endpoints))
+	_ = viper.BindEnv(endpoints)
+
+	flags.StringP(user, "", "", "etcd username[:password] (optional)")
+	flags.StringP(prefix, "", "", "prefix of the keys of the vanity URLs (default \""+be.DefaultKeyPrefix+"\")")
+	flags.StringP(certFile, "", "", "PEM file of the client certificate (optional)")
+	flags.StringP(keyFile, "", "", "PEM file of the client certificate key (optional)")
+	flags.StringP(trustedCAFile, "", "", "PEM file of the CAs verifying the etcd endpoints (optional)")
+
+	viper.RegisterAlias(endpoints, "etcd.endpoints")
+	for _, f := range []string{user, prefix, certFile, keyFile, trustedCAFile} {
+		viper.RegisterAlias(f, "etcd."+f)
+	}
+}
+
+// Command is the vanity sub-command for an etcd backend.
+var Command = &cobra.Command{
+	Use:   "etcd",
+	Short: "Use etcd backend for a vanity store",
+	Long:  "Use etcd backend for a vanity store",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Set backend w/ etcd")
+		err := viper.BindPFlags(cmd.Flags())
+		if err != nil {
+			return fmt.Errorf(unableToBind, err)
+		}
+
+		beHelp := newHelper(cmd)
+		backend, err := beHelp.getBackend()
+		if err != nil {
+			return fmt.Errorf(unableToInstantiate, err)
+		}
+
+		saved = backends.Get()
+		backends.Set(backend)
+
+		return nil
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Clean backend of etcd")
+		defer func() {
+			backends.Set(saved)
+		}()
+		return backends.Get().Close()
+	},
+}
+
+type helper struct {
+	*cli.FlagSet
+}
+
+// newHelper wraps the Cobra command's flags with a utility wrapper to assist in
+// the creation of an etcd-based backend.
+func newHelper(cmd *cobra.Command) *helper {
+	return &helper{FlagSet: cli.Flags(cmd)}
+}
+
+// getBackend returns an etcd-based api.Backend instance, configured by the helper.
+func (h *helper) getBackend() (vanity.Backend, error) {
+	e, ok := h.GetValue(endpoints)
+	if !ok || e == "" {
+		return nil, errUnableToGetEndpoints
+	}
+
+	config := clientv3.Config{
+		Endpoints:   strings.Split(e, ","),
+		DialTimeout: be.DefaultDialTimeout,
+	}
+
+	if u, ok := h.GetValue(user); ok && u != "" {
+		s := strings.SplitN(u, ":", 2)
+		config.Username = s[0]
+		if len(s) > 1 {
+			config.Password = s[1]
+		}
+	}
+
+	tlsConfig, err := h.getTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.TLS = tlsConfig
+
+	var opts []be.BackendOption
+	if p, ok := h.GetValue(prefix); ok && p != "" {
+		opts = append(opts, be.WithKeyPrefix(p))
+	}
+
+	glog.V(log.Debug).Infof("etcd endpoints: %s, TLS: %t", e, tlsConfig != nil)
+
+	return be.NewClient(config, opts...)
+}
+
+// getTLSConfig returns the TLS configuration of the connections to the etcd
+// endpoints, nil unless a client certificate or trusted CA file is passed.
+func (h *helper) getTLSConfig() (*tls.Config, error) {
+	cert, _ := h.GetValue(certFile)
+	key, _ := h.GetValue(keyFile)
+	ca, _ := h.GetValue(trustedCAFile)
+	if cert == "" && key == "" && ca == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if ca != "" {
+		pem, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errInvalidCAFile
+		}
+	}
+
+	if cert != "" || key != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+
+	return config, nil
+}
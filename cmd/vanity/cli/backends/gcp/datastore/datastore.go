@@ -28,6 +28,7 @@ import (
 	"l7e.io/vanity/cmd/vanity/cli"
 	"l7e.io/vanity/cmd/vanity/cli/backends"
 	"l7e.io/vanity/cmd/vanity/cli/backends/gcp"
+	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
 	"l7e.io/vanity/cmd/vanity/cli/log"
 	be "l7e.io/vanity/pkg/gcp/datastore"
 )
@@ -40,6 +41,7 @@ var saved vanity.Backend
 
 func init() { //nolint:gochecknoinits
 	cli.RootCmd.AddCommand(Command)
+	helpers.RegisterBackend(Command)
 
 	flags := Command.PersistentFlags()
 	flags.StringP(projectID, "", "", "GCP project hosting datastore")
@@ -121,5 +123,5 @@ func (h *helper) getBackend() (vanity.Backend, error) {
 		return nil, err
 	}
 
-	return be.NewClient(id, options...)
+	return be.NewClient(id, be.WithClientOptions(options))
 }
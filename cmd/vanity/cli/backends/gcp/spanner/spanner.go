@@ -29,6 +29,7 @@ import (
 	"l7e.io/vanity/cmd/vanity/cli"
 	"l7e.io/vanity/cmd/vanity/cli/backends"
 	"l7e.io/vanity/cmd/vanity/cli/backends/gcp"
+	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
 	"l7e.io/vanity/cmd/vanity/cli/log"
 	be "l7e.io/vanity/pkg/gcp/spanner"
 )
@@ -44,6 +45,7 @@ var (
 
 func init() { //nolint:gochecknoinits
 	cli.RootCmd.AddCommand(Command)
+	helpers.RegisterBackend(Command)
 
 	flags := Command.PersistentFlags()
 	flags.StringP(database, "", "", "Spanner database connection string")
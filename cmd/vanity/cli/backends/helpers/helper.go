@@ -14,32 +14,67 @@
  * limitations under the License.
  *
  */
-
 /*
 Package helpers provides a way for sub-commands to register themselves with
 backend implementations.
 
-Backend implementations MUST add the proper code to the AddCommand function in
-order to participate in this sub-command registration.
+Backend implementations MUST register their sub-command using RegisterBackend
+in order to participate in this sub-command registration.
 */
 package helpers
 
 import (
-	"github.com/spf13/cobra"
+	"sync"
 
-	"l7e.io/vanity/cmd/vanity/cli/backends/gcp/datastore"
-	"l7e.io/vanity/cmd/vanity/cli/backends/gcp/spanner"
+	"github.com/spf13/cobra"
 )
 
+// RequiresBackend is the annotation of the sub-commands added to backend
+// sub-commands, which perform their functionality using the installed backend.
+const RequiresBackend = "vanity.requires-backend"
+
 // CommandProducer produces new a Command instance that can
 // be added to the set of backend sub-commands.
 type CommandProducer func() *cobra.Command
 
+var (
+	lock      sync.Mutex
+	backends  []*cobra.Command
+	producers []CommandProducer
+)
+
+// RegisterBackend registers the sub-command of a backend implementation,
+// adding the Commands of all producers, past and future, to it.
+func RegisterBackend(cmd *cobra.Command) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	backends = append(backends, cmd)
+	for _, p := range producers {
+		cmd.AddCommand(produce(p))
+	}
+}
+
 // AddCommand adds Commands produced by producers to the set
 // of backend sub-commands.
-func AddCommand(producers ...CommandProducer) {
-	for _, p := range producers {
-		datastore.Command.AddCommand(p())
-		spanner.Command.AddCommand(p())
+func AddCommand(ps ...CommandProducer) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, p := range ps {
+		producers = append(producers, p)
+		for _, cmd := range backends {
+			cmd.AddCommand(produce(p))
+		}
 	}
 }
+
+func produce(p CommandProducer) *cobra.Command {
+	cmd := p()
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[RequiresBackend] = "true"
+
+	return cmd
+}
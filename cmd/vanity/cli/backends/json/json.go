@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package json contains the json sub-command.
+package json
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/cmd/vanity/cli"
+	"l7e.io/vanity/cmd/vanity/cli/backends"
+	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
+	"l7e.io/vanity/cmd/vanity/cli/log"
+	be "l7e.io/vanity/pkg/json"
+)
+
+const (
+	file  = "file"
+	table = "table"
+)
+
+var (
+	errUnableToGetFile = fmt.Errorf("unable to get file")
+	saved              vanity.Backend
+)
+
+func init() { //nolint:gochecknoinits
+	cli.RootCmd.AddCommand(Command)
+	helpers.RegisterBackend(Command)
+
+	flags := Command.PersistentFlags()
+	flags.StringP(file, "", "", "JSON file, e.g. /etc/vanity/vanity.json")
+	flags.StringP(table, "", "", "dotted keys of the vanity URLs, the root of the document if not set, e.g. paths")
+}
+
+// Command is the vanity sub-command for a JSON backend.
+var Command = &cobra.Command{
+	Use:   "json",
+	Short: "Use local JSON file for a vanity store",
+	Long: `Use local JSON file for a vanity store
+
+The vanity URLs are read, once, from a array of objects or, as in
+govanityurls configurations, an object keyed by import path.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Set backend w/ JSON")
+		err := viper.BindPFlags(cmd.Flags())
+		if err != nil {
+			return fmt.Errorf(unableToBind, err)
+		}
+
+		beHelp := newHelper(cmd)
+		backend, err := beHelp.getBackend()
+		if err != nil {
+			return fmt.Errorf(unableToInstantiate, err)
+		}
+
+		saved = backends.Get()
+		backends.Set(backend)
+
+		return nil
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Clean backend of JSON")
+		defer func() {
+			backends.Set(saved)
+		}()
+		return backends.Get().Close()
+	},
+}
+
+type helper struct {
+	*cli.FlagSet
+}
+
+// newHelper wraps the Cobra command's flags with a utility wrapper to assist in
+// the creation of a JSON-based backend.
+func newHelper(cmd *cobra.Command) *helper {
+	return &helper{FlagSet: cli.Flags(cmd)}
+}
+
+// getString returns the value of a flag from the command line or, if not set,
+// the json table of the configuration.
+func (h *helper) getString(name string) string {
+	if v, err := h.GetString(name); err == nil && v != "" {
+		return v
+	}
+
+	return viper.GetString("json." + name)
+}
+
+// getBackend returns a JSON-based api.Backend instance, configured by the helper.
+func (h *helper) getBackend() (vanity.Backend, error) {
+	f := h.getString(file)
+	if f == "" {
+		return nil, errUnableToGetFile
+	}
+
+	glog.V(log.Debug).Infof("JSON file: %s", f)
+
+	opts := []be.Option{be.FromFile(f)}
+	if t := h.getString(table); t != "" {
+		opts = append(opts, be.InTable(strings.Split(t, ".")...))
+	}
+
+	return be.NewJSONBackend(opts...)
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package kubernetes contains the kubernetes sub-command.
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/cmd/vanity/cli"
+	"l7e.io/vanity/cmd/vanity/cli/backends"
+	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
+	"l7e.io/vanity/cmd/vanity/cli/log"
+	be "l7e.io/vanity/pkg/kubernetes"
+)
+
+const (
+	namespace     = "namespace"
+	kubeconfig    = "kubeconfig"
+	labelSelector = "label-selector"
+
+	// syncTimeout bounds the initial sync of the informer cache.
+	syncTimeout = 30 * time.Second
+)
+
+var (
+	errUnableToGetNamespace = fmt.Errorf("unable to get namespace")
+	saved                   vanity.Backend
+)
+
+func init() { //nolint:gochecknoinits
+	cli.RootCmd.AddCommand(Command)
+	helpers.RegisterBackend(Command)
+
+	flags := Command.PersistentFlags()
+	flags.StringP(namespace, "", "", "namespace of the ConfigMaps of the vanity URLs")
+	_ = viper.BindPFlag(namespace, flags.Lookup(namespace))
+	_ = viper.BindEnv(namespace)
+
+	flags.StringP(kubeconfig, "", "", "kubeconfig file, in-cluster configuration if not set (optional)")
+	flags.StringP(labelSelector, "", "", "label selector of the ConfigMaps of the vanity URLs (default \""+be.DefaultLabelSelector+"\")")
+
+	viper.RegisterAlias(namespace, "kubernetes.namespace")
+	for _, f := range []string{kubeconfig, labelSelector} {
+		viper.RegisterAlias(f, "kubernetes."+f)
+	}
+}
+
+// Command is the vanity sub-command for a Kubernetes backend.
+var Command = &cobra.Command{
+	Use:   "kubernetes",
+	Short: "Use Kubernetes backend for a vanity store",
+	Long:  "Use Kubernetes backend for a vanity store",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Set backend w/ Kubernetes")
+		err := viper.BindPFlags(cmd.Flags())
+		if err != nil {
+			return fmt.Errorf(unableToBind, err)
+		}
+
+		beHelp := newHelper(cmd)
+		backend, err := beHelp.getBackend()
+		if err != nil {
+			return fmt.Errorf(unableToInstantiate, err)
+		}
+
+		saved = backends.Get()
+		backends.Set(backend)
+
+		return nil
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Clean backend of Kubernetes")
+		defer func() {
+			backends.Set(saved)
+		}()
+		return backends.Get().Close()
+	},
+}
+
+type helper struct {
+	*cli.FlagSet
+}
+
+// newHelper wraps the Cobra command's flags with a utility wrapper to assist in
+// the creation of a Kubernetes-based backend.
+func newHelper(cmd *cobra.Command) *helper {
+	return &helper{FlagSet: cli.Flags(cmd)}
+}
+
+// getBackend returns a Kubernetes-based api.Backend instance, configured by the helper.
+func (h *helper) getBackend() (vanity.Backend, error) {
+	ns, ok := h.GetValue(namespace)
+	if !ok || ns == "" {
+		return nil, errUnableToGetNamespace
+	}
+
+	path, _ := h.GetValue(kubeconfig)
+	config, err := clientcmd.BuildConfigFromFlags("", path)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := clientset.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []be.BackendOption{
+		be.WithErrorHandler(func(name string, err error) {
+			glog.Errorf("Skipping ConfigMap %s: %s", name, err)
+		}),
+	}
+	if s, ok := h.GetValue(labelSelector); ok && s != "" {
+		opts = append(opts, be.WithLabelSelector(s))
+	}
+
+	glog.V(log.Debug).Infof("Kubernetes namespace: %s, API server: %s", ns, config.Host)
+
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+
+	return be.NewBackend(ctx, client, ns, opts...)
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package memory contains the memory sub-command.
+package memory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/cmd/vanity/cli"
+	"l7e.io/vanity/cmd/vanity/cli/backends"
+	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
+	"l7e.io/vanity/cmd/vanity/cli/log"
+	be "l7e.io/vanity/pkg/memory"
+)
+
+const (
+	entry = "entry"
+
+	// entriesKey is the configuration key of the entries; the entry flag is
+	// not aliased to it since the entry key holds the array of tables read by
+	// the toml sub-command.
+	entriesKey = "memory.entries"
+)
+
+var (
+	errInvalidEntry = fmt.Errorf("invalid entry, expected import-path,vcs,vcs-path")
+	saved           vanity.Backend
+)
+
+func init() { //nolint:gochecknoinits
+	cli.RootCmd.AddCommand(Command)
+	helpers.RegisterBackend(Command)
+
+	flags := Command.PersistentFlags()
+	flags.StringArrayP(entry, "", nil, "initial vanity URL, e.g. l7e.io/vanity,git,https://github.com/livetribe/vanity (repeatable)")
+}
+
+// Command is the vanity sub-command for an in-memory backend.
+var Command = &cobra.Command{
+	Use:   "memory",
+	Short: "Use in-memory vanity store",
+	Long: `Use in-memory vanity store
+
+The vanity URLs are kept in memory and lost when the process exits; the store
+is seeded with the vanity URLs of the command line or configuration.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Set backend w/ memory")
+		err := viper.BindPFlags(cmd.Flags())
+		if err != nil {
+			return fmt.Errorf(unableToBind, err)
+		}
+
+		beHelp := newHelper(cmd)
+		backend, err := beHelp.getBackend()
+		if err != nil {
+			return fmt.Errorf(unableToInstantiate, err)
+		}
+
+		saved = backends.Get()
+		backends.Set(backend)
+
+		return nil
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Clean backend of memory")
+		defer func() {
+			backends.Set(saved)
+		}()
+		return backends.Get().Close()
+	},
+}
+
+type helper struct {
+	*cli.FlagSet
+}
+
+// newHelper wraps the Cobra command's flags with a utility wrapper to assist in
+// the creation of an in-memory backend.
+func newHelper(cmd *cobra.Command) *helper {
+	return &helper{FlagSet: cli.Flags(cmd)}
+}
+
+// getEntries returns the initial vanity URLs from the command line or, if
+// there are none, the configuration.
+func (h *helper) getEntries() []string {
+	if entries, err := h.GetStringArray(entry); err == nil && len(entries) > 0 {
+		return entries
+	}
+
+	return viper.GetStringSlice(entriesKey)
+}
+
+// getBackend returns an in-memory api.Backend instance, configured by the helper.
+func (h *helper) getBackend() (vanity.Backend, error) {
+	backend := be.NewInMemoryAPI()
+
+	for _, e := range h.getEntries() {
+		fields := strings.Split(e, ",")
+		if len(fields) != 3 || fields[0] == "" || fields[1] == "" || fields[2] == "" {
+			_ = backend.Close()
+			return nil, errInvalidEntry
+		}
+
+		glog.V(log.Debug).Infof("In-memory entry: %s", e)
+
+		backend.AddEntry(fields[0], fields[1], fields[2])
+	}
+
+	return backend, nil
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package redis contains the redis sub-command.
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/cmd/vanity/cli"
+	"l7e.io/vanity/cmd/vanity/cli/backends"
+	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
+	"l7e.io/vanity/cmd/vanity/cli/log"
+	be "l7e.io/vanity/pkg/redis"
+)
+
+const (
+	addr        = "addr"
+	username    = "username"
+	password    = "password"
+	db          = "db"
+	keyPrefix   = "key-prefix"
+	useTLS      = "tls"
+	tlsCAFile   = "tls-ca-file"
+	tlsCertFile = "tls-cert-file"
+	tlsKeyFile  = "tls-key-file"
+)
+
+var (
+	errUnableToGetAddr = fmt.Errorf("unable to get address")
+	errInvalidDB       = fmt.Errorf("invalid database number")
+	errInvalidCAFile   = fmt.Errorf("no certificates found in CA file")
+	saved              vanity.Backend
+)
+
+func init() { //nolint:gochecknoinits
+	cli.RootCmd.AddCommand(Command)
+	helpers.RegisterBackend(Command)
+
+	flags := Command.PersistentFlags()
+	flags.StringP(addr, "", "", "Redis server address, e.g. localhost:6379")
+	_ = viper.BindPFlag(addr, flags.Lookup(addr))
+	_ = viper.BindEnv(addr)
+
+	flags.StringP(username, "", "", "Redis ACL username (optional)")
+	flags.StringP(password, "", "", "Redis password (optional)")
+	flags.StringP(db, "", "", "Redis database number (default 0)")
+	flags.StringP(keyPrefix, "", "", "prefix of the keys of the vanity URLs (default \""+be.DefaultKeyPrefix+"\")")
+	flags.BoolP(useTLS, "", false, "connect to the Redis server using TLS")
+	flags.StringP(tlsCAFile, "", "", "PEM file of the CAs verifying the Redis server (optional)")
+	flags.StringP(tlsCertFile, "", "", "PEM file of the client certificate (optional)")
+	flags.StringP(tlsKeyFile, "", "", "PEM file of the client certificate key (optional)")
+
+	viper.RegisterAlias(addr, "redis.addr")
+	for _, f := range []string{username, password, db, keyPrefix, useTLS, tlsCAFile, tlsCertFile, tlsKeyFile} {
+		viper.RegisterAlias(f, "redis."+f)
+	}
+}
+
+// Command is the vanity sub-command for a Redis backend.
+var Command = &cobra.Command{
+	Use:   "redis",
+	Short: "Use Redis backend for a vanity store",
+	Long:  "Use Redis backend for a vanity store",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Set backend w/ redis")
+		err := viper.BindPFlags(cmd.Flags())
+		if err != nil {
+			return fmt.Errorf(unableToBind, err)
+		}
+
+		beHelp := newHelper(cmd)
+		backend, err := beHelp.getBackend()
+		if err != nil {
+			return fmt.Errorf(unableToInstantiate, err)
+		}
+
+		saved = backends.Get()
+		backends.Set(backend)
+
+		return nil
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Clean backend of redis")
+		defer func() {
+			backends.Set(saved)
+		}()
+		return backends.Get().Close()
+	},
+}
+
+type helper struct {
+	*cli.FlagSet
+}
+
+// newHelper wraps the Cobra command's flags with a utility wrapper to assist in
+// the creation of a Redis-based backend.
+func newHelper(cmd *cobra.Command) *helper {
+	return &helper{FlagSet: cli.Flags(cmd)}
+}
+
+// getBackend returns a Redis-based api.Backend instance, configured by the helper.
+func (h *helper) getBackend() (vanity.Backend, error) {
+	a, ok := h.GetValue(addr)
+	if !ok || a == "" {
+		return nil, errUnableToGetAddr
+	}
+
+	options := &redis.Options{Addr: a}
+	options.Username, _ = h.GetValue(username)
+	options.Password, _ = h.GetValue(password)
+
+	// the database number may be an integer in the configuration
+	if d := h.getString(db); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			return nil, errInvalidDB
+		}
+		options.DB = n
+	}
+
+	tlsConfig, err := h.getTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	options.TLSConfig = tlsConfig
+
+	var opts []be.BackendOption
+	if p, ok := h.GetValue(keyPrefix); ok && p != "" {
+		opts = append(opts, be.WithKeyPrefix(p))
+	}
+
+	glog.V(log.Debug).Infof("Redis address: %s, database: %d, TLS: %t", a, options.DB, tlsConfig != nil)
+
+	return be.NewClient(options, opts...), nil
+}
+
+// getString returns the value of a flag from the command line or, if not set,
+// the configuration, converted to a string.
+func (h *helper) getString(name string) string {
+	if v, err := h.GetString(name); err == nil && v != "" {
+		return v
+	}
+
+	return viper.GetString(name)
+}
+
+// getTLSConfig returns the TLS configuration of the connections to the Redis
+// server, nil unless TLS is enabled.
+func (h *helper) getTLSConfig() (*tls.Config, error) {
+	enabled, err := h.GetBool(useTLS)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled && !viper.GetBool(useTLS) {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if f, ok := h.GetValue(tlsCAFile); ok && f != "" {
+		pem, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errInvalidCAFile
+		}
+	}
+
+	cert, _ := h.GetValue(tlsCertFile)
+	key, _ := h.GetValue(tlsKeyFile)
+	if cert != "" || key != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sql contains the sql sub-command.
+package sql
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	// the database/sql drivers of the supported dialects
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/cmd/vanity/cli"
+	"l7e.io/vanity/cmd/vanity/cli/backends"
+	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
+	"l7e.io/vanity/cmd/vanity/cli/log"
+	be "l7e.io/vanity/pkg/sql"
+)
+
+const (
+	dsn = "dsn"
+)
+
+var (
+	errUnableToGetDSN = fmt.Errorf("unable to get data source name")
+	errNotSQL         = fmt.Errorf("data source name is not of a SQL database")
+	saved             vanity.Backend
+)
+
+func init() { //nolint:gochecknoinits
+	cli.RootCmd.AddCommand(Command)
+	helpers.RegisterBackend(Command)
+
+	flags := Command.PersistentFlags()
+	flags.StringP(dsn, "", "", "SQL data source name, e.g. sqlite3:///var/lib/vanity/vanity.db")
+	_ = viper.BindPFlag(dsn, flags.Lookup(dsn))
+	_ = viper.BindEnv(dsn)
+
+	viper.RegisterAlias(dsn, "sql.dsn")
+}
+
+// Command is the vanity sub-command for a SQL backend.
+var Command = &cobra.Command{
+	Use:   "sql",
+	Short: "Use SQLite, PostgreSQL or MySQL backend for a vanity store",
+	Long:  "Use SQLite, PostgreSQL or MySQL backend for a vanity store",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Set backend w/ sql")
+		err := viper.BindPFlags(cmd.Flags())
+		if err != nil {
+			return fmt.Errorf(unableToBind, err)
+		}
+
+		beHelp := newHelper(cmd)
+		backend, err := beHelp.getBackend()
+		if err != nil {
+			return fmt.Errorf(unableToInstantiate, err)
+		}
+
+		saved = backends.Get()
+		backends.Set(backend)
+
+		return nil
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Clean backend of sql")
+		defer func() {
+			backends.Set(saved)
+		}()
+		return backends.Get().Close()
+	},
+}
+
+type helper struct {
+	*cli.FlagSet
+}
+
+// newHelper wraps the Cobra command's flags with a utility wrapper to assist in
+// the creation of a SQL-based backend.
+func newHelper(cmd *cobra.Command) *helper {
+	return &helper{FlagSet: cli.Flags(cmd)}
+}
+
+// getBackend returns a SQL-based api.Backend instance, configured by the helper.
+func (h *helper) getBackend() (vanity.Backend, error) {
+	d, ok := h.GetValue(dsn)
+	if !ok {
+		return nil, errUnableToGetDSN
+	}
+
+	u, err := url.Parse(d)
+	if err != nil {
+		return nil, vanity.ErrInvalidDSN
+	}
+
+	switch u.Scheme {
+	case be.SQLite, be.PostgreSQL, "postgresql", be.MySQL:
+	default:
+		return nil, errNotSQL
+	}
+
+	glog.V(log.Debug).Infof("SQL dialect: %s", u.Scheme)
+
+	return vanity.Open(context.Background(), d)
+}
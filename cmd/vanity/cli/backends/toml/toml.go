@@ -0,0 +1,170 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package toml contains the toml sub-command.
+package toml
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/cmd/vanity/cli"
+	"l7e.io/vanity/cmd/vanity/cli/backends"
+	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
+	"l7e.io/vanity/cmd/vanity/cli/log"
+	be "l7e.io/vanity/pkg/toml"
+)
+
+const (
+	file  = "file"
+	table = "table"
+	watch = "watch"
+
+	// configExt is the extension of configuration files holding vanity URLs.
+	configExt = ".toml"
+)
+
+var (
+	errUnableToGetFile = fmt.Errorf("unable to get file")
+	saved              vanity.Backend
+)
+
+func init() { //nolint:gochecknoinits
+	cli.RootCmd.AddCommand(Command)
+	helpers.RegisterBackend(Command)
+
+	flags := Command.PersistentFlags()
+	flags.StringP(file, "", "", "TOML file, the configuration file if not set, e.g. /etc/vanity/entries.toml")
+	flags.StringP(table, "", "", "dotted path of the array of tables of the vanity URLs (default \""+be.DefaultTable+"\")")
+	flags.BoolP(watch, "", false, "reload the file when it changes")
+}
+
+// Command is the vanity sub-command for a TOML backend.
+var Command = &cobra.Command{
+	Use:   "toml",
+	Short: "Use local TOML file for a vanity store",
+	Long: `Use local TOML file for a vanity store
+
+The vanity URLs are read from the array of tables of the file, which defaults
+to the configuration file.  The file is reloaded when the process receives
+SIGHUP and, if watched, whenever it changes.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Set backend w/ TOML")
+		err := viper.BindPFlags(cmd.Flags())
+		if err != nil {
+			return fmt.Errorf(unableToBind, err)
+		}
+
+		beHelp := newHelper(cmd)
+		backend, err := beHelp.getBackend()
+		if err != nil {
+			return fmt.Errorf(unableToInstantiate, err)
+		}
+
+		saved = backends.Get()
+		backends.Set(backend)
+
+		return nil
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		glog.V(log.Debug).Infoln("Clean backend of TOML")
+		defer func() {
+			backends.Set(saved)
+		}()
+		return backends.Get().Close()
+	},
+}
+
+type helper struct {
+	*cli.FlagSet
+}
+
+// newHelper wraps the Cobra command's flags with a utility wrapper to assist in
+// the creation of a TOML-based backend.
+func newHelper(cmd *cobra.Command) *helper {
+	return &helper{FlagSet: cli.Flags(cmd)}
+}
+
+// getString returns the value of a flag from the command line or, if not set,
+// the toml table of the configuration.
+func (h *helper) getString(name string) string {
+	if v, err := h.GetString(name); err == nil && v != "" {
+		return v
+	}
+
+	return viper.GetString("toml." + name)
+}
+
+// getBool returns the value of a flag from the command line or, if not set,
+// the toml table of the configuration.
+func (h *helper) getBool(name string) bool {
+	if h.Changed(name) {
+		v, _ := h.GetBool(name)
+		return v
+	}
+
+	return viper.GetBool("toml." + name)
+}
+
+// getFile returns the TOML file from the command line, the configuration or,
+// failing both, the configuration file itself if it is a TOML file, as it is
+// rewritten by changes.
+func (h *helper) getFile() (string, bool) {
+	if f := h.getString(file); f != "" {
+		return f, true
+	}
+
+	f := viper.ConfigFileUsed()
+	return f, strings.EqualFold(filepath.Ext(f), configExt)
+}
+
+// getBackend returns a TOML-based api.Backend instance, configured by the helper.
+func (h *helper) getBackend() (vanity.Backend, error) {
+	f, ok := h.getFile()
+	if !ok {
+		return nil, errUnableToGetFile
+	}
+
+	t := h.getString(table)
+	if t == "" {
+		t = be.DefaultTable
+	}
+
+	w := h.getBool(watch)
+
+	glog.V(log.Debug).Infof("TOML file: %s, table: %s, watch: %t", f, t, w)
+
+	opts := []be.Option{
+		be.FromFile(f),
+		be.InTable(strings.Split(t, ".")...),
+		be.ReloadOnSignal(syscall.SIGHUP),
+		be.OnReloadError(func(err error) {
+			glog.Errorf("Unable to reload %s: %s", f, err)
+		}),
+	}
+	if w {
+		opts = append(opts, be.WatchFile())
+	}
+
+	return be.NewTOMLBackend(opts...)
+}
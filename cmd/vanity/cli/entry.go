@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"l7e.io/vanity"
+)
+
+var errInvalidVisibility = fmt.Errorf("invalid visibility")
+
+// EntryFlags holds the metadata of a vanity URL configuration specified on
+// the command line.
+type EntryFlags struct {
+	Description    string
+	Owner          string
+	DefaultBranch  string
+	DocURL         string
+	SourceTemplate string
+	Visibility     string
+	Labels         map[string]string
+}
+
+// AddFlags adds the metadata flags to flags.
+func (f *EntryFlags) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&f.Description, "description", "", "", "description of the import path")
+	flags.StringVarP(&f.Owner, "owner", "", "", "team or person responsible for the import path")
+	flags.StringVarP(&f.DefaultBranch, "default-branch", "", "", "branch used in go-source links")
+	flags.StringVarP(&f.DocURL, "doc-url", "", "", "documentation URL, or template, of the import path")
+	flags.StringVarP(&f.SourceTemplate, "source-template", "", "",
+		"forge type whose go-source templates are used, e.g. gitlab")
+	flags.StringVarP(&f.Visibility, "visibility", "", "", "visibility of the import path: public, internal or private")
+	flags.StringToStringVarP(&f.Labels, "label", "", nil, "label of the import path, e.g. team=platform")
+}
+
+// NewEntry creates a vanity URL configuration with the metadata of the flags.
+func (f *EntryFlags) NewEntry(importPath, vcs, vcsPath string) (*vanity.Entry, error) {
+	entry := vanity.NewEntry(importPath, vcs, vcsPath)
+	entry.Description = f.Description
+	entry.Owner = f.Owner
+	entry.DefaultBranch = f.DefaultBranch
+	entry.DocURL = f.DocURL
+	entry.SourceTemplate = f.SourceTemplate
+	entry.Visibility = vanity.Visibility(f.Visibility)
+	entry.Labels = f.Labels
+
+	if !entry.Visibility.IsValid() {
+		return nil, errInvalidVisibility
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+)
+
+func TestEntryFlags(t *testing.T) {
+	var f EntryFlags
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	f.AddFlags(flags)
+
+	err := flags.Parse([]string{"--owner", "platform", "--visibility", "internal", "--label", "tier=core"})
+	assert.NoError(t, err)
+
+	entry, err := f.NewEntry("a.com/b", "git", "https://github.com/b")
+	assert.NoError(t, err)
+	assert.Equal(t, "platform", entry.Owner)
+	assert.Equal(t, vanity.VisibilityInternal, entry.Visibility)
+	assert.Equal(t, map[string]string{"tier": "core"}, entry.Labels)
+
+	f.Visibility = "secret"
+	_, err = f.NewEntry("a.com/b", "git", "https://github.com/b")
+	assert.Equal(t, errInvalidVisibility, err)
+}
@@ -19,7 +19,10 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"l7e.io/vanity"
 )
@@ -36,6 +39,20 @@ func NewJSONConsumer() vanity.Consumer {
 	return &jsonConsumer{first: true}
 }
 
+// NewPlainEntryConsumer creates an EntryConsumer that prints vanity URL
+// configurations to standard output as plainConsumer comma-delimited text.
+// Metadata is not printed.
+func NewPlainEntryConsumer() vanity.EntryConsumer {
+	return plainConsumer{}
+}
+
+// NewJSONEntryConsumer creates an EntryConsumer that prints vanity URL
+// configurations, along with their metadata, to standard output as a JSON
+// object.
+func NewJSONEntryConsumer() vanity.EntryConsumer {
+	return &jsonConsumer{first: true}
+}
+
 type plainConsumer struct{}
 
 func (p plainConsumer) OnEntry(_ context.Context, importPath, vcs, vcsPath string) {
@@ -54,3 +71,54 @@ func (j *jsonConsumer) OnEntry(_ context.Context, importPath, vcs, vcsPath strin
 	}
 	fmt.Printf("{\"importPath\": \"%s\", \"vcs\": \"%s\", \"vcsPath\": \"%s\"}", importPath, vcs, vcsPath)
 }
+
+func (p plainConsumer) Consume(ctx context.Context, e *vanity.Entry) {
+	p.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+}
+
+func (j *jsonConsumer) Consume(_ context.Context, e *vanity.Entry) {
+	if j.first {
+		j.first = false
+	} else {
+		fmt.Printf(",\n")
+	}
+
+	var fields []string
+	add := func(name string, value interface{}) {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return
+		}
+		fields = append(fields, fmt.Sprintf("%q: %s", name, b))
+	}
+	addString := func(name, value string) {
+		if value != "" {
+			add(name, value)
+		}
+	}
+	addTime := func(name string, value time.Time) {
+		if !value.IsZero() {
+			add(name, value.Format(time.RFC3339))
+		}
+	}
+
+	add("importPath", e.ImportPath)
+	add("vcs", e.VCS)
+	add("vcsPath", e.VCSPath)
+	if e.Revision != 0 {
+		add("revision", e.Revision)
+	}
+	addString("description", e.Description)
+	addString("owner", e.Owner)
+	addString("defaultBranch", e.DefaultBranch)
+	addString("docUrl", e.DocURL)
+	addString("sourceTemplate", e.SourceTemplate)
+	addString("visibility", string(e.Visibility))
+	if len(e.Labels) > 0 {
+		add("labels", e.Labels)
+	}
+	addTime("created", e.Created)
+	addTime("updated", e.Updated)
+
+	fmt.Printf("{%s}", strings.Join(fields, ", "))
+}
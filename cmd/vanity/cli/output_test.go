@@ -19,10 +19,12 @@ package cli_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/kami-zh/go-capturer"
 	"github.com/stretchr/testify/assert"
 
+	"l7e.io/vanity"
 	"l7e.io/vanity/cmd/vanity/cli"
 )
 
@@ -47,3 +49,37 @@ func TestJSONConsumer(t *testing.T) {
 
 	assert.Equal(t, "{\"importPath\": \"a\", \"vcs\": \"b\", \"vcsPath\": \"c\"},\n{\"importPath\": \"d\", \"vcs\": \"e\", \"vcsPath\": \"f\"}", out)
 }
+
+func TestPlainEntryConsumer(t *testing.T) {
+	c := cli.NewPlainEntryConsumer()
+
+	e := vanity.NewEntry("a", "b", "c")
+	e.Owner = "o"
+
+	out := capturer.CaptureOutput(func() {
+		c.Consume(context.Background(), e)
+	})
+
+	assert.Equal(t, "a,b,c\n", out)
+}
+
+func TestJSONEntryConsumer(t *testing.T) {
+	c := cli.NewJSONEntryConsumer()
+
+	e := vanity.NewEntry("d", "e", "f")
+	e.Revision = 2
+	e.Description = "say \"hi\""
+	e.Owner = "o"
+	e.Visibility = vanity.VisibilityInternal
+	e.Labels = map[string]string{"k": "v"}
+	e.Created = time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	out := capturer.CaptureOutput(func() {
+		c.Consume(context.Background(), vanity.NewEntry("a", "b", "c"))
+		c.Consume(context.Background(), e)
+	})
+
+	assert.Equal(t, "{\"importPath\": \"a\", \"vcs\": \"b\", \"vcsPath\": \"c\"},\n"+
+		"{\"importPath\": \"d\", \"vcs\": \"e\", \"vcsPath\": \"f\", \"revision\": 2, \"description\": \"say \\\"hi\\\"\", "+
+		"\"owner\": \"o\", \"visibility\": \"internal\", \"labels\": {\"k\":\"v\"}, \"created\": \"2020-06-01T10:00:00Z\"}", out)
+}
@@ -57,18 +57,18 @@ func getCmd(cmd *cobra.Command, args []string) {
 		glog.Exitf("Unable to bind viper to command line flags: %s", err)
 	}
 
-	var c vanity.Consumer
+	var c vanity.EntryConsumer
 	if outputJSON {
-		c = cli.NewJSONConsumer()
+		c = cli.NewJSONEntryConsumer()
 	} else {
-		c = cli.NewPlainConsumer()
+		c = cli.NewPlainEntryConsumer()
 	}
 
 	importPath := args[0]
-	vcs, vcsPath, err := backends.Get().Get(context.Background(), importPath)
+	entry, err := vanity.AsEntryBackend(backends.Get()).GetEntry(context.Background(), importPath)
 	if err != nil {
 		glog.Exitf("Unable to get %s: %s", importPath, err)
 	}
 
-	c.OnEntry(context.Background(), importPath, vcs, vcsPath)
+	c.Consume(context.Background(), entry)
 }
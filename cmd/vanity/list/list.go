@@ -20,6 +20,7 @@ package list
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
@@ -31,7 +32,13 @@ import (
 	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
 )
 
-var outputJSON bool
+var (
+	outputJSON bool
+	prefix     string
+	limit      int
+	pageToken  string
+	descending bool
+)
 
 func init() { //nolint:gochecknoinits
 	helpers.AddCommand(func() *cobra.Command {
@@ -45,6 +52,10 @@ func init() { //nolint:gochecknoinits
 
 		flags := cmd.Flags()
 		flags.BoolVarP(&outputJSON, "json", "", false, "output in JSON")
+		flags.StringVarP(&prefix, "prefix", "", "", "only list import paths starting with this prefix")
+		flags.IntVarP(&limit, "limit", "", 0, "maximum number of vanity URLs listed, zero for all")
+		flags.StringVarP(&pageToken, "page-token", "", "", "continue listing after the page that printed this token")
+		flags.BoolVarP(&descending, "desc", "", false, "list in descending order")
 
 		return cmd
 	})
@@ -56,15 +67,20 @@ func listCmd(cmd *cobra.Command, _ []string) {
 		glog.Exitf("Unable to bind viper to command line flags: %s", err)
 	}
 
-	var c vanity.Consumer
+	var c vanity.EntryConsumer
 	if outputJSON {
-		c = cli.NewJSONConsumer()
+		c = cli.NewJSONEntryConsumer()
 		fmt.Println("[")
 	} else {
-		c = cli.NewPlainConsumer()
+		c = cli.NewPlainEntryConsumer()
+	}
+
+	opts := vanity.ListOptions{Prefix: prefix, PageSize: limit, PageToken: pageToken}
+	if descending {
+		opts.Order = vanity.Descending
 	}
 
-	err = backends.Get().List(context.Background(), c)
+	next, err := vanity.ListPage(context.Background(), backends.Get(), opts, c)
 	if err != nil {
 		glog.Exitf("Unable to obtain list: %s", err)
 	}
@@ -72,4 +88,8 @@ func listCmd(cmd *cobra.Command, _ []string) {
 	if outputJSON {
 		fmt.Println("\n]")
 	}
+
+	if next != "" {
+		fmt.Fprintf(os.Stderr, "next page token: %s\n", next)
+	}
 }
@@ -42,3 +42,21 @@ func TestList(t *testing.T) {
 
 	assert.Equal(t, "a.com/b,vcs,vcsPath\n", out)
 }
+
+func TestList_page(t *testing.T) {
+	backends.Set(&apitest.MockBackend{Urls: map[string][]string{
+		"a.com/b": {"vcs", "vcsPath"},
+		"a.com/c": {"vcs", "vcsPath"},
+		"b.com/d": {"vcs", "vcsPath"},
+	}})
+	cmd := cmdtest.NewCommand(func(cmd *cobra.Command, args []string) {})
+
+	prefix, limit = "a.com/", 1
+	defer func() { prefix, limit = "", 0 }()
+
+	out := capturer.CaptureStdout(func() {
+		listCmd(cmd, []string{})
+	})
+
+	assert.Equal(t, "a.com/b,vcs,vcsPath\n", out)
+}
@@ -28,13 +28,30 @@ import (
 
 	_ "l7e.io/vanity/cmd/vanity/add"
 	"l7e.io/vanity/cmd/vanity/cli"
+	_ "l7e.io/vanity/cmd/vanity/cli/backends/bolt"
+	_ "l7e.io/vanity/cmd/vanity/cli/backends/dsn"
+	_ "l7e.io/vanity/cmd/vanity/cli/backends/etcd"
 	_ "l7e.io/vanity/cmd/vanity/cli/backends/gcp/datastore"
 	_ "l7e.io/vanity/cmd/vanity/cli/backends/gcp/spanner"
+	_ "l7e.io/vanity/cmd/vanity/cli/backends/json"
+	_ "l7e.io/vanity/cmd/vanity/cli/backends/kubernetes"
+	_ "l7e.io/vanity/cmd/vanity/cli/backends/memory"
+	_ "l7e.io/vanity/cmd/vanity/cli/backends/redis"
+	_ "l7e.io/vanity/cmd/vanity/cli/backends/sql"
+	_ "l7e.io/vanity/cmd/vanity/cli/backends/toml"
+	_ "l7e.io/vanity/cmd/vanity/cli/backends/yaml"
 	"l7e.io/vanity/cmd/vanity/cli/log"
 	_ "l7e.io/vanity/cmd/vanity/get"
 	_ "l7e.io/vanity/cmd/vanity/list"
 	_ "l7e.io/vanity/cmd/vanity/remove"
 	_ "l7e.io/vanity/cmd/vanity/server"
+	_ "l7e.io/vanity/cmd/vanity/update"
+	_ "l7e.io/vanity/pkg/fsdir"
+	_ "l7e.io/vanity/pkg/json"
+	_ "l7e.io/vanity/pkg/layered"
+	_ "l7e.io/vanity/pkg/memory"
+	_ "l7e.io/vanity/pkg/toml"
+	_ "l7e.io/vanity/pkg/yaml"
 )
 
 func init() { //nolint:gochecknoinits
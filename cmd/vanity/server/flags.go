@@ -29,6 +29,9 @@ import (
 	"l7e.io/vanity"
 	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
 	"l7e.io/vanity/cmd/vanity/server/interceptors"
+	"l7e.io/vanity/pkg/cache"
+	"l7e.io/vanity/pkg/replica"
+	"l7e.io/vanity/pkg/snapshot"
 )
 
 func init() { //nolint:gochecknoinits
@@ -48,11 +51,24 @@ func init() { //nolint:gochecknoinits
 }
 
 const (
-	bind    = "bind"
-	port    = "port"
-	healthz = "healthz"
-	readyz  = "readyz"
-	metrics = "prometheus"
+	bind          = "bind"
+	port          = "port"
+	healthz       = "healthz"
+	readyz        = "readyz"
+	metrics       = "prometheus"
+	goSource      = "go-source"
+	defaultBranch = "default-branch"
+	forge         = "forge"
+	docURL        = "doc-url"
+	entryDocURL   = "entry-doc-url"
+	cached        = "cache"
+	cacheSize     = "cache-size"
+	cacheTTL      = "cache-ttl"
+	cacheNegTTL   = "cache-negative-ttl"
+	replicated    = "replica"
+	replicaEvery  = "replica-interval"
+	snapshotFile  = "snapshot-file"
+	snapshotEvery = "snapshot-interval"
 )
 
 func initFlags(cmd *cobra.Command) {
@@ -62,6 +78,19 @@ func initFlags(cmd *cobra.Command) {
 	flags.Int16P(healthz, "", 8081, "port on which application health checks will listen")
 	flags.Int16P(readyz, "", 8082, "port on which application ready checks will listen")
 	flags.Int16P(metrics, "", 9100, "port on which the Prometheus will listen")
+	flags.BoolP(goSource, "", true, "add go-source meta tags linking to the source of packages")
+	flags.StringP(defaultBranch, "", vanity.DefaultBranch, "branch used in go-source links")
+	flags.StringToStringP(forge, "", nil, "forge type of repository hosts, e.g. git.example.com=gitlab")
+	flags.StringP(docURL, "", vanity.DefaultDocURL, "base URL, or template, browsers are redirected to")
+	flags.StringToStringP(entryDocURL, "", nil, "documentation URL, or template, of import paths, e.g. l7e.io/vanity=https://docs.l7e.io/{{.Subpath}}")
+	flags.BoolP(cached, "", false, "cache backend lookups")
+	flags.IntP(cacheSize, "", cache.DefaultSize, "maximum number of cached backend lookups")
+	flags.DurationP(cacheTTL, "", cache.DefaultTTL, "time found import paths are cached")
+	flags.DurationP(cacheNegTTL, "", cache.DefaultNegativeTTL, "time import paths not found are cached, zero to disable")
+	flags.BoolP(replicated, "", false, "serve from an in-memory replica of the backend, refreshed periodically")
+	flags.DurationP(replicaEvery, "", replica.DefaultInterval, "interval between the refreshes of the replica")
+	flags.StringP(snapshotFile, "", "", "file the backend is snapshotted to, and served from when the backend fails")
+	flags.DurationP(snapshotEvery, "", snapshot.DefaultInterval, "interval between the snapshots of the backend")
 }
 
 type helper struct {
@@ -82,11 +111,63 @@ func (h *helper) getHTTPServer(api vanity.Backend) *http.Server {
 	glog.Infof("port configured to listen to %s", addr)
 
 	mux := http.NewServeMux()
-	mux.Handle("/", interceptors.WrapHandler(vanity.NewVanityHandler(api)))
+	mux.Handle("/", interceptors.WrapHandler(vanity.NewVanityHandler(api, h.getHandlerOptions()...)))
 
 	return &http.Server{Addr: addr, Handler: mux}
 }
 
+// getBackend returns api, snapshotted to a file, and served from a replica or
+// decorated with a cache, if configured by the helper.
+func (h *helper) getBackend(api vanity.Backend) (vanity.Backend, error) {
+	if path := viper.GetString(snapshotFile); path != "" {
+		interval := viper.GetDuration(snapshotEvery)
+		glog.Infof("snapshotting the backend to %s every %s", path, interval)
+
+		var err error
+		if api, err = snapshot.NewSnapshotBackend(api, path, snapshot.WithInterval(interval)); err != nil {
+			return nil, err
+		}
+	}
+
+	if viper.GetBool(replicated) {
+		interval := viper.GetDuration(replicaEvery)
+		glog.Infof("serving from a replica refreshed every %s", interval)
+
+		if viper.GetBool(cached) {
+			glog.Warning("cache ignored when serving from a replica")
+		}
+
+		return replica.NewReplica(api, replica.WithInterval(interval)), nil
+	}
+
+	if !viper.GetBool(cached) {
+		return api, nil
+	}
+
+	size, ttl, negTTL := viper.GetInt(cacheSize), viper.GetDuration(cacheTTL), viper.GetDuration(cacheNegTTL)
+	glog.Infof("caching up to %d lookups, found for %s and not found for %s", size, ttl, negTTL)
+
+	return cache.NewCachingBackend(api, cache.WithSize(size), cache.WithTTL(ttl), cache.WithNegativeTTL(negTTL)), nil
+}
+
+// getHandlerOptions returns the vanity.Handler options configured by the helper.
+func (h *helper) getHandlerOptions() []vanity.HandlerOption {
+	forges := viper.GetStringMapString(forge)
+	for host, f := range forges {
+		if !vanity.IsForge(f) {
+			glog.Warningf("unknown forge %s for host %s", f, host)
+		}
+	}
+
+	return []vanity.HandlerOption{
+		vanity.WithGoSource(viper.GetBool(goSource)),
+		vanity.WithDefaultBranch(viper.GetString(defaultBranch)),
+		vanity.WithForges(forges),
+		vanity.WithDocURL(viper.GetString(docURL)),
+		vanity.WithDocURLs(viper.GetStringMapString(entryDocURL)),
+	}
+}
+
 // getHealthz returns an http.Server for healthz configured by the helper.
 func (h *helper) getHealthz(handler http.Handler) *http.Server {
 	port := viper.GetInt(healthz)
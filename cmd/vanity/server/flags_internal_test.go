@@ -17,12 +17,16 @@
 package server
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 
+	"l7e.io/vanity"
 	"l7e.io/vanity/cmd/vanity/cmdtest"
 )
 
@@ -145,3 +149,123 @@ func TestGetMetric_addr_port(t *testing.T) {
 	_, err := cmdtest.ExecuteCommand(cmd, "--bind", "127.0.1.2", "--prometheus", "1234")
 	assert.NoError(t, err)
 }
+
+func TestGetHandlerOptions_default(t *testing.T) {
+	cmd := cmdtest.NewCommand(func(cmd *cobra.Command, args []string) {
+		err := viper.BindPFlags(cmd.Flags())
+		assert.NoError(t, err)
+
+		h := newHelper(cmd)
+		handler := &vanity.Handler{}
+		for _, o := range h.getHandlerOptions() {
+			o.Apply(handler)
+		}
+		assert.True(t, handler.GoSource)
+		assert.Equal(t, vanity.DefaultBranch, handler.DefaultBranch)
+		assert.Empty(t, handler.Forges)
+		assert.Equal(t, vanity.DefaultDocURL, handler.DocURL)
+		assert.Empty(t, handler.DocURLs)
+	})
+	initFlags(cmd)
+
+	_, err := cmdtest.ExecuteCommand(cmd)
+	assert.NoError(t, err)
+}
+
+func TestGetHandlerOptions(t *testing.T) {
+	cmd := cmdtest.NewCommand(func(cmd *cobra.Command, args []string) {
+		err := viper.BindPFlags(cmd.Flags())
+		assert.NoError(t, err)
+
+		h := newHelper(cmd)
+		handler := &vanity.Handler{}
+		for _, o := range h.getHandlerOptions() {
+			o.Apply(handler)
+		}
+		assert.False(t, handler.GoSource)
+		assert.Equal(t, "main", handler.DefaultBranch)
+		assert.Equal(t, map[string]string{"git.a.com": "gitea"}, handler.Forges)
+		assert.Equal(t, "https://pkgsite.a.com/", handler.DocURL)
+		assert.Equal(t, map[string]string{"a.com/b": "https://b.a.com/{{.Subpath}}"}, handler.DocURLs)
+	})
+	initFlags(cmd)
+
+	_, err := cmdtest.ExecuteCommand(cmd, "--go-source=false", "--default-branch", "main", "--forge", "git.a.com=gitea",
+		"--doc-url", "https://pkgsite.a.com/", "--entry-doc-url", "a.com/b=https://b.a.com/{{.Subpath}}")
+	assert.NoError(t, err)
+}
+
+func TestGetBackend_cache(t *testing.T) {
+	api := &be{}
+
+	cmd := cmdtest.NewCommand(func(cmd *cobra.Command, args []string) {
+		err := viper.BindPFlags(cmd.Flags())
+		assert.NoError(t, err)
+
+		h := newHelper(cmd)
+		b, err := h.getBackend(api)
+		assert.NoError(t, err)
+		assert.Equal(t, api, b)
+	})
+	initFlags(cmd)
+
+	_, err := cmdtest.ExecuteCommand(cmd)
+	assert.NoError(t, err)
+
+	cmd = cmdtest.NewCommand(func(cmd *cobra.Command, args []string) {
+		err := viper.BindPFlags(cmd.Flags())
+		assert.NoError(t, err)
+
+		h := newHelper(cmd)
+		b, err := h.getBackend(api)
+		assert.NoError(t, err)
+		assert.NotEqual(t, api, b)
+	})
+	initFlags(cmd)
+
+	_, err = cmdtest.ExecuteCommand(cmd, "--cache", "--cache-ttl", "5m")
+	assert.NoError(t, err)
+}
+
+func TestGetBackend_replica(t *testing.T) {
+	api := &be{}
+
+	cmd := cmdtest.NewCommand(func(cmd *cobra.Command, args []string) {
+		err := viper.BindPFlags(cmd.Flags())
+		assert.NoError(t, err)
+
+		h := newHelper(cmd)
+		r, err := h.getBackend(api)
+		assert.NoError(t, err)
+		_, ok := r.(vanity.ReadinessChecker)
+		assert.True(t, ok)
+		assert.NoError(t, r.Close())
+	})
+	initFlags(cmd)
+
+	_, err := cmdtest.ExecuteCommand(cmd, "--replica", "--replica-interval", "1h")
+	assert.NoError(t, err)
+}
+
+func TestGetBackend_snapshot(t *testing.T) {
+	api := &be{}
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "snapshot.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("{"), 0600))
+
+	cmd := cmdtest.NewCommand(func(cmd *cobra.Command, args []string) {
+		err := viper.BindPFlags(cmd.Flags())
+		assert.NoError(t, err)
+
+		h := newHelper(cmd)
+		_, err = h.getBackend(api)
+		assert.Error(t, err)
+	})
+	initFlags(cmd)
+
+	_, err = cmdtest.ExecuteCommand(cmd, "--snapshot-file", path)
+	assert.NoError(t, err)
+}
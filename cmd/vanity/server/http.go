@@ -29,11 +29,18 @@ import (
 )
 
 // newHandlerCheck creates a handler instance that can be used for a healthz checkpoint.
+// Readyz checkpoints of backends implementing vanity.ReadinessChecker use
+// their Readyz method.
 func newHandlerCheck(backend vanity.Backend, kind string) http.Handler {
+	check := backend.Healthz
+	if rc, ok := backend.(vanity.ReadinessChecker); ok && kind == readyz {
+		check = rc.Readyz
+	}
+
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			ctx, _ := context.WithTimeout(r.Context(), 5*time.Second) // nolint
-			err := backend.Healthz(ctx)
+			err := check(ctx)
 			if err != nil {
 				glog.Error(err)
 				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
@@ -17,12 +17,15 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
 )
 
 var errUnhealthy = fmt.Errorf("unhealthy")
@@ -48,3 +51,27 @@ func TestNewHandlerCheck_error(t *testing.T) {
 	resp := w.Result()
 	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
 }
+
+type notReady struct {
+	be
+}
+
+func (n *notReady) Readyz(_ context.Context) error {
+	return vanity.ErrNotReady
+}
+
+func TestNewHandlerCheck_readyz(t *testing.T) {
+	for kind, expected := range map[string]int{
+		"healthz": http.StatusOK,
+		"readyz":  http.StatusServiceUnavailable,
+	} {
+		c := newHandlerCheck(&notReady{}, kind)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "http://a.com", nil)
+
+		c.ServeHTTP(w, r)
+		resp := w.Result()
+		assert.Equal(t, expected, resp.StatusCode, kind)
+	}
+}
@@ -38,10 +38,15 @@ func serverCmd(cmd *cobra.Command, _ []string) {
 
 	svrHelp := newHelper(cmd)
 
-	vanity := svrHelp.getHTTPServer(backends.Get())
+	api, err := svrHelp.getBackend(backends.Get())
+	if err != nil {
+		glog.Exitf("Unable to create backend: %s", err)
+	}
+
+	vanity := svrHelp.getHTTPServer(api)
 
-	healthz := svrHelp.getHealthz(newHandlerCheck(backends.Get(), "healthz"))
-	readyz := svrHelp.getReadyz(newHandlerCheck(backends.Get(), "readyz"))
+	healthz := svrHelp.getHealthz(newHandlerCheck(api, "healthz"))
+	readyz := svrHelp.getReadyz(newHandlerCheck(api, "readyz"))
 
 	metrics := svrHelp.getMetrics()
 	if err != nil {
@@ -51,7 +56,7 @@ func serverCmd(cmd *cobra.Command, _ []string) {
 	watcher := yama.NewWatcher(
 		yama.WatchingSignals(syscall.SIGINT, syscall.SIGTERM),
 		yama.WithTimeout(2*time.Second), // nolint
-		yama.WithClosers(backends.Get(), vanity, healthz, readyz, metrics))
+		yama.WithClosers(api, vanity, healthz, readyz, metrics))
 
 	go func() {
 		if err = metrics.ListenAndServe(); err != http.ErrServerClosed {
@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package update contains the update sub-command to update vanity URLs.
+package update
+
+import (
+	"context"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/cmd/vanity/cli"
+	"l7e.io/vanity/cmd/vanity/cli/backends"
+	"l7e.io/vanity/cmd/vanity/cli/backends/helpers"
+	"l7e.io/vanity/cmd/vanity/cli/log"
+)
+
+var (
+	entryFlags cli.EntryFlags
+	create     bool
+	ifRevision int64
+)
+
+func init() { //nolint:gochecknoinits
+	helpers.AddCommand(func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   "update <importPath> <vcs> <vcsPath>",
+			Short: "Update vanity URL",
+			Long:  "Update vanity URL, replacing its VCS, VCS path and metadata",
+			Args:  cobra.ExactArgs(3), // nolint
+			Run:   updateCmd,
+		}
+
+		flags := cmd.Flags()
+		entryFlags.AddFlags(flags)
+		flags.BoolVarP(&create, "create", "", false, "add the vanity URL if it does not exist")
+		flags.Int64VarP(&ifRevision, "if-revision", "", vanity.AnyRevision,
+			"only update the vanity URL if it is at this revision, as shown by get --json")
+
+		return cmd
+	})
+}
+
+func updateCmd(_ *cobra.Command, args []string) {
+	entry, err := entryFlags.NewEntry(args[0], args[1], args[2])
+	if err != nil {
+		glog.Exitf("Unable to update %s: %s", args[0], err)
+	}
+
+	glog.V(log.Debug).Infof("Updating %s %s %s...", entry.ImportPath, entry.VCS, entry.VCSPath)
+
+	be := vanity.AsEntryBackend(backends.Get())
+	if create && ifRevision == vanity.AnyRevision {
+		err = be.UpsertEntry(context.Background(), entry)
+	} else {
+		err = be.UpdateEntry(context.Background(), entry, ifRevision)
+	}
+	if err != nil {
+		glog.Exitf("Unable to update %s %s %s: %s", entry.ImportPath, entry.VCS, entry.VCSPath, err)
+	}
+
+	glog.V(log.Debug).Info("Updated")
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package update
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/cmd/vanity/cli/backends"
+	"l7e.io/vanity/cmd/vanity/cmdtest"
+	"l7e.io/vanity/pkg/memory"
+)
+
+func TestUpdate(t *testing.T) {
+	backends.Set(&apitest.MockBackend{Urls: map[string][]string{"a.com/b": {"vcs", "vcsPath"}}})
+	cmd := cmdtest.NewCommand(func(cmd *cobra.Command, args []string) {
+		err := viper.BindPFlags(cmd.Flags())
+		assert.NoError(t, err)
+	})
+
+	updateCmd(cmd, []string{"a.com/b", "git", "https://github.com/b"})
+	assert.Equal(t, []string{"git", "https://github.com/b"}, backends.Get().(*apitest.MockBackend).Urls["a.com/b"])
+}
+
+func TestUpdate_create(t *testing.T) {
+	defer func() { create = false }()
+
+	backends.Set(&apitest.MockBackend{Urls: make(map[string][]string)})
+	cmd := cmdtest.NewCommand(func(cmd *cobra.Command, args []string) {
+		err := viper.BindPFlags(cmd.Flags())
+		assert.NoError(t, err)
+	})
+
+	create = true
+	updateCmd(cmd, []string{"a.com/b", "git", "https://github.com/b"})
+	assert.Equal(t, []string{"git", "https://github.com/b"}, backends.Get().(*apitest.MockBackend).Urls["a.com/b"])
+}
+
+func TestUpdate_ifRevision(t *testing.T) {
+	defer func() { ifRevision = vanity.AnyRevision }()
+
+	be := memory.NewInMemoryAPI()
+	be.AddEntry("a.com/b", "vcs", "vcsPath")
+	backends.Set(be)
+	cmd := cmdtest.NewCommand(func(cmd *cobra.Command, args []string) {
+		err := viper.BindPFlags(cmd.Flags())
+		assert.NoError(t, err)
+	})
+
+	ifRevision = 1
+	updateCmd(cmd, []string{"a.com/b", "git", "https://github.com/b"})
+
+	e, err := be.GetEntry(context.Background(), "a.com/b")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), e.Revision)
+	assert.Equal(t, "https://github.com/b", e.VCSPath)
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity
+
+import (
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// docData is passed to documentation URL templates.
+type docData struct {
+	// ImportPath is the import path of the vanity URL configuration, e.g. "l7e.io/vanity".
+	ImportPath string
+
+	// Subpath is the remainder of the requested path, e.g. "pkg/toml".
+	Subpath string
+
+	// Host is the requested host, e.g. "l7e.io".
+	Host string
+}
+
+var docTemplates sync.Map // map[string]*template.Template
+
+// isDocTemplate reports whether a documentation URL is a template rather
+// than a base URL.
+func isDocTemplate(docURL string) bool {
+	return strings.Contains(docURL, "{{")
+}
+
+// docURLFor generates the documentation URL of a package.  A docURL that is
+// a text/template, e.g. "https://docs.example.com/{{.ImportPath}}/{{.Subpath}}",
+// is executed with the ImportPath, Subpath and Host fields; otherwise docURL
+// is a base URL to which the import path is appended.
+func docURLFor(docURL string, d *docData) (string, error) {
+	if !isDocTemplate(docURL) {
+		return docURL + d.ImportPath, nil
+	}
+
+	var t *template.Template
+	if v, ok := docTemplates.Load(docURL); ok {
+		t = v.(*template.Template)
+	} else {
+		parsed, err := template.New("doc").Parse(docURL)
+		if err != nil {
+			return "", err
+		}
+		v, _ = docTemplates.LoadOrStore(docURL, parsed)
+		t = v.(*template.Template)
+	}
+
+	return execute(t, d)
+}
@@ -0,0 +1,229 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// EntryVersion is the current version of the Entry model.
+	EntryVersion = 1
+
+	// AnyRevision is passed to EntryBackend.UpdateEntry for unconditional
+	// updates.
+	AnyRevision int64 = 0
+)
+
+// Visibility describes who a vanity URL configuration is intended for.
+type Visibility string
+
+// Visibilities of vanity URL configurations.
+const (
+	VisibilityPublic   Visibility = "public"
+	VisibilityInternal Visibility = "internal"
+	VisibilityPrivate  Visibility = "private"
+)
+
+// IsValid reports whether v is a known visibility or unspecified.
+func (v Visibility) IsValid() bool {
+	switch v {
+	case "", VisibilityPublic, VisibilityInternal, VisibilityPrivate:
+		return true
+	default:
+		return false
+	}
+}
+
+// Entry is a vanity URL configuration along with its metadata.
+type Entry struct {
+	// Version is the version of the Entry model the configuration was stored with.
+	Version int
+
+	// Revision is incremented whenever the configuration is stored, starting
+	// at one, and is zero for backends that do not track revisions.  It is
+	// ignored when storing configurations.
+	Revision int64
+
+	// ImportPath is the vanity import path, e.g. "l7e.io/vanity".
+	ImportPath string
+
+	// VCS is the version control system, e.g. "git".
+	VCS string
+
+	// VCSPath is the root of the version control system.
+	VCSPath string
+
+	// Description is a human readable description.
+	Description string
+
+	// Owner is the team or person responsible for the import path.
+	Owner string
+
+	// DefaultBranch is the branch used in go-source links, overriding the
+	// Handler's default branch.
+	DefaultBranch string
+
+	// DocURL is the documentation URL, or template, browsers are redirected
+	// to, overriding the Handler's DocURL.
+	DocURL string
+
+	// SourceTemplate is the forge type whose go-source templates are used,
+	// e.g. "gitlab", overriding the forge type detected from VCSPath.
+	SourceTemplate string
+
+	// Visibility describes who the import path is intended for.
+	Visibility Visibility
+
+	// Labels are arbitrary key/value pairs.
+	Labels map[string]string
+
+	// Created is when the configuration was first stored.
+	Created time.Time
+
+	// Updated is when the configuration was last stored.
+	Updated time.Time
+}
+
+// NewEntry creates an Entry, of the current version, without metadata.
+func NewEntry(importPath, vcs, vcsPath string) *Entry {
+	return &Entry{
+		Version:    EntryVersion,
+		ImportPath: importPath,
+		VCS:        vcs,
+		VCSPath:    vcsPath,
+	}
+}
+
+// Clone returns a deep copy of the entry.
+func (e *Entry) Clone() *Entry {
+	c := *e
+	if e.Labels != nil {
+		c.Labels = make(map[string]string, len(e.Labels))
+		for k, v := range e.Labels {
+			c.Labels[k] = v
+		}
+	}
+
+	return &c
+}
+
+// EntryBackend implementations provide access to a vanity URL store with
+// configuration metadata.
+//
+// Backends which do not implement this interface can be adapted using
+// AsEntryBackend.
+type EntryBackend interface {
+	Backend
+
+	// GetEntry obtains the vanity URL configuration for a given import path.
+	GetEntry(ctx context.Context, importPath string) (*Entry, error)
+
+	// InsertEntry adds a vanity URL configuration.  ErrAlreadyExists is
+	// returned if the import path already exists.
+	InsertEntry(ctx context.Context, entry *Entry) error
+
+	// UpdateEntry replaces an existing vanity URL configuration, keeping its
+	// creation time.  ErrNotFound is returned if the import path does not exist.
+	//
+	// Unless ifRevision is AnyRevision, the configuration is only replaced if
+	// it is still at revision ifRevision; ErrConflict is returned otherwise.
+	UpdateEntry(ctx context.Context, entry *Entry, ifRevision int64) error
+
+	// UpsertEntry adds a vanity URL configuration, replacing any existing
+	// configuration of the import path.
+	UpsertEntry(ctx context.Context, entry *Entry) error
+
+	// ListEntries lists all registered URL configurations, delivering them to
+	// the consumer callback.
+	ListEntries(ctx context.Context, consumer EntryConsumer) error
+}
+
+// EntryConsumer is the interface whose implementations are provided to the
+// EntryBackend.ListEntries() method which calls their Consume method with the
+// vanity entries found.
+type EntryConsumer interface {
+	Consume(ctx context.Context, entry *Entry)
+}
+
+// The EntryConsumerFunc type is an adapter to allow the use of
+// ordinary functions as entry consumers. If f is a function
+// with the appropriate signature, EntryConsumerFunc(f) is an
+// EntryConsumer that calls f.
+type EntryConsumerFunc func(ctx context.Context, entry *Entry)
+
+// Consume calls f(ctx, entry).
+func (f EntryConsumerFunc) Consume(ctx context.Context, entry *Entry) {
+	f(ctx, entry)
+}
+
+// AsEntryBackend adapts a Backend to an EntryBackend.  Backends that already
+// implement EntryBackend are returned as is; the metadata of entries passed
+// to other Backends is discarded, and their updates are not atomic, being a
+// Remove followed by an Add.  Conditional updates of other Backends return
+// ErrNotSupported, since they do not track revisions.
+func AsEntryBackend(api Backend) EntryBackend {
+	if eb, ok := api.(EntryBackend); ok {
+		return eb
+	}
+
+	return &entryAdapter{api}
+}
+
+type entryAdapter struct {
+	Backend
+}
+
+func (a *entryAdapter) GetEntry(ctx context.Context, importPath string) (*Entry, error) {
+	vcs, vcsPath, err := a.Get(ctx, importPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEntry(importPath, vcs, vcsPath), nil
+}
+
+func (a *entryAdapter) InsertEntry(ctx context.Context, entry *Entry) error {
+	return a.Add(ctx, entry.ImportPath, entry.VCS, entry.VCSPath)
+}
+
+func (a *entryAdapter) UpdateEntry(ctx context.Context, entry *Entry, ifRevision int64) error {
+	if ifRevision != AnyRevision {
+		return ErrNotSupported
+	}
+
+	if _, _, err := a.Get(ctx, entry.ImportPath); err != nil {
+		return err
+	}
+
+	return a.UpsertEntry(ctx, entry)
+}
+
+func (a *entryAdapter) UpsertEntry(ctx context.Context, entry *Entry) error {
+	if err := a.Remove(ctx, entry.ImportPath); err != nil && err != ErrNotFound {
+		return err
+	}
+
+	return a.Add(ctx, entry.ImportPath, entry.VCS, entry.VCSPath)
+}
+
+func (a *entryAdapter) ListEntries(ctx context.Context, consumer EntryConsumer) error {
+	return a.List(ctx, ConsumerFunc(func(ctx context.Context, importPath, vcs, vcsPath string) {
+		consumer.Consume(ctx, NewEntry(importPath, vcs, vcsPath))
+	}))
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/pkg/memory"
+)
+
+func TestEntry_Clone(t *testing.T) {
+	e := vanity.NewEntry("a.com/b", "git", "https://github.com/b")
+	e.Labels = map[string]string{"k": "v"}
+
+	c := e.Clone()
+	c.Labels["k"] = "changed"
+	c.Owner = "changed"
+
+	assert.Equal(t, map[string]string{"k": "v"}, e.Labels)
+	assert.Empty(t, e.Owner)
+}
+
+func TestVisibility_IsValid(t *testing.T) {
+	assert.True(t, vanity.Visibility("").IsValid())
+	assert.True(t, vanity.VisibilityPublic.IsValid())
+	assert.True(t, vanity.VisibilityInternal.IsValid())
+	assert.True(t, vanity.VisibilityPrivate.IsValid())
+	assert.False(t, vanity.Visibility("secret").IsValid())
+}
+
+func TestAsEntryBackend(t *testing.T) {
+	be := memory.NewInMemoryAPI()
+	assert.Equal(t, be, vanity.AsEntryBackend(be))
+
+	mock := &apitest.MockBackend{Urls: make(map[string][]string)}
+	eb := vanity.AsEntryBackend(mock)
+
+	e := vanity.NewEntry("a.com/b", "git", "https://github.com/b")
+	e.Owner = "dropped"
+	assert.NoError(t, eb.InsertEntry(context.Background(), e))
+	assert.Equal(t, []string{"git", "https://github.com/b"}, mock.Urls["a.com/b"])
+
+	got, err := eb.GetEntry(context.Background(), "a.com/b")
+	assert.NoError(t, err)
+	assert.Equal(t, vanity.NewEntry("a.com/b", "git", "https://github.com/b"), got)
+
+	_, err = eb.GetEntry(context.Background(), "a.com/z")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	var entries []*vanity.Entry
+	err = eb.ListEntries(context.Background(), vanity.EntryConsumerFunc(func(_ context.Context, e *vanity.Entry) {
+		entries = append(entries, e)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []*vanity.Entry{got}, entries)
+}
+
+func TestAsEntryBackend_update(t *testing.T) {
+	mock := &apitest.MockBackend{Urls: map[string][]string{"a.com/b": {"git", "https://github.com/b"}}}
+	eb := vanity.AsEntryBackend(mock)
+
+	assert.Equal(t, vanity.ErrAlreadyExists,
+		eb.InsertEntry(context.Background(), vanity.NewEntry("a.com/b", "git", "https://gitlab.com/b")))
+
+	assert.NoError(t, eb.UpdateEntry(context.Background(), vanity.NewEntry("a.com/b", "git", "https://gitlab.com/b"),
+		vanity.AnyRevision))
+	assert.Equal(t, []string{"git", "https://gitlab.com/b"}, mock.Urls["a.com/b"])
+
+	assert.Equal(t, vanity.ErrNotFound,
+		eb.UpdateEntry(context.Background(), vanity.NewEntry("a.com/c", "git", "https://gitlab.com/c"), vanity.AnyRevision))
+	assert.Equal(t, vanity.ErrNotSupported,
+		eb.UpdateEntry(context.Background(), vanity.NewEntry("a.com/b", "git", "https://gitlab.com/b"), 1))
+
+	assert.NoError(t, eb.UpsertEntry(context.Background(), vanity.NewEntry("a.com/c", "git", "https://gitlab.com/c")))
+	assert.Equal(t, []string{"git", "https://gitlab.com/c"}, mock.Urls["a.com/c"])
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// flight is an in-flight lookup; its result is set before done is closed.
+type flight struct {
+	done  chan struct{}
+	entry *Entry
+	stale bool
+	err   error
+}
+
+// flightGroup coalesces concurrent lookups of the same path, so only one of
+// them calls the backend, in the manner of golang.org/x/sync/singleflight.
+type flightGroup struct {
+	lock    sync.Mutex
+	flights map[string]*flight
+}
+
+// do returns the result of lookup for path, and whether it is stale, calling
+// it unless a lookup of path is already in flight.  The lookup is given a
+// context detached from ctx, which keeps its values but not its deadline or
+// cancellation, so a canceled request does not fail the requests coalesced
+// with it, which wait for the result until their own ctx is done.  A panic
+// in lookup is returned to every waiter as an error.
+func (g *flightGroup) do(ctx context.Context, path string, lookup func(context.Context) (*Entry, bool, error)) (*Entry, bool, error) {
+	g.lock.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+
+	f, ok := g.flights[path]
+	if ok {
+		APICoalesced.Inc()
+	} else {
+		f = &flight{done: make(chan struct{})}
+		g.flights[path] = f
+
+		go func(ctx context.Context) {
+			defer func() {
+				if r := recover(); r != nil {
+					f.entry, f.stale, f.err = nil, false, fmt.Errorf("lookup of %s panicked: %v", path, r)
+				}
+
+				g.lock.Lock()
+				delete(g.flights, path)
+				g.lock.Unlock()
+
+				close(f.done)
+			}()
+
+			f.entry, f.stale, f.err = lookup(ctx)
+		}(detached{ctx})
+	}
+	g.lock.Unlock()
+
+	select {
+	case <-f.done:
+		return f.entry, f.stale, f.err
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// detached is a context that carries the values of its parent but is never
+// canceled and has no deadline.
+type detached struct {
+	parent context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detached) Done() <-chan struct{} { return nil }
+
+func (detached) Err() error { return nil }
+
+func (d detached) Value(key interface{}) interface{} { return d.parent.Value(key) }
@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func coalesced() float64 {
+	m := &dto.Metric{}
+	_ = APICoalesced.Write(m)
+	return m.Counter.GetValue()
+}
+
+func TestFlightGroup(t *testing.T) {
+	const n = 10
+
+	var g flightGroup
+	var calls int32
+	release := make(chan struct{})
+	start := coalesced()
+
+	lookup := func(context.Context) (*Entry, bool, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return NewEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity"), true, nil
+	}
+
+	var wg sync.WaitGroup
+	entries := make([]*Entry, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			e, stale, err := g.do(context.Background(), "l7e.io/vanity/cmd", lookup)
+			assert.NoError(t, err)
+			assert.True(t, stale)
+			entries[i] = e
+		}(i)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for coalesced()-start < n-1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, float64(n-1), coalesced()-start)
+	for _, e := range entries {
+		assert.Equal(t, "l7e.io/vanity", e.ImportPath)
+	}
+
+	// completed lookups are not reused
+	_, _, err := g.do(context.Background(), "l7e.io/vanity/cmd", func(context.Context) (*Entry, bool, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, false, ErrNotFound
+	})
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestFlightGroup_canceled(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := g.do(ctx, "l7e.io/vanity", func(context.Context) (*Entry, bool, error) {
+		<-release
+		return nil, false, ErrNotFound
+	})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestFlightGroup_detached(t *testing.T) {
+	type key struct{}
+
+	var g flightGroup
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	defer cancel()
+
+	_, _, err := g.do(ctx, "l7e.io/vanity", func(ctx context.Context) (*Entry, bool, error) {
+		cancel()
+		assert.Equal(t, "value", ctx.Value(key{}))
+		assert.NoError(t, ctx.Err())
+		return nil, false, ErrNotFound
+	})
+	assert.Error(t, err)
+}
+
+func TestFlightGroup_panic(t *testing.T) {
+	var g flightGroup
+
+	_, _, err := g.do(context.Background(), "l7e.io/vanity", func(context.Context) (*Entry, bool, error) {
+		panic("boom")
+	})
+	assert.EqualError(t, err, "lookup of l7e.io/vanity panicked: boom")
+
+	// the panicked lookup is not reused
+	_, _, err = g.do(context.Background(), "l7e.io/vanity", func(context.Context) (*Entry, bool, error) {
+		return nil, false, ErrNotFound
+	})
+	assert.Equal(t, ErrNotFound, err)
+}
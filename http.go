@@ -31,6 +31,10 @@ import (
 const (
 	xForwardedHost = "X-Forwarded-Host"
 
+	// staleWarning is the Warning header of responses served from stale
+	// configurations, see RFC 7234 section 5.5.1.
+	staleWarning = `110 - "Response is Stale"`
+
 	// DefaultDocURL is the default Go doc URL.
 	// It can MockBackend replaced with https://https://godoc.org/.
 	DefaultDocURL = "https://pkg.go.dev/"
@@ -40,6 +44,7 @@ type data struct {
 	ImportRoot string
 	VCS        string
 	VCSRoot    string
+	Source     *sourceLinks
 }
 
 var tmpl = template.Must(template.New("main").Parse(`<!DOCTYPE html>
@@ -47,7 +52,9 @@ var tmpl = template.Must(template.New("main").Parse(`<!DOCTYPE html>
 <head>
   <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
   <meta name="go-import" content="{{.ImportRoot}} {{.VCS}} {{.VCSRoot}}">
-  <meta name="go-source" content="{{.ImportRoot}} {{.VCSRoot}} {{.VCSRoot}}/tree/master{/dir} {{.VCSRoot}}/blob/master{/dir}/{file}#L{line}">
+{{- if .Source}}
+  <meta name="go-source" content="{{.ImportRoot}} {{.Source.Home}} {{.Source.Directory}} {{.Source.File}}">
+{{- end}}
 </head>
 </html>
 `))
@@ -93,6 +100,24 @@ var (
 		Help:      "The total templating errors",
 	})
 
+	// APICoalesced is a Prometheus counter that tracks the total vanity Backend
+	// calls coalesced with an identical call in flight.
+	APICoalesced = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "api",
+		Name:      "coalesced_total",
+		Help:      "The total vanity Backend calls coalesced with an identical call in flight",
+	})
+
+	// APIStale is a Prometheus counter that tracks the total responses served
+	// from vanity URL configurations that may be out of date.
+	APIStale = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "api",
+		Name:      "stale_total",
+		Help:      "The total responses served from stale vanity Backend configurations",
+	})
+
 	// SummaryVec is a Prometheus histogram to track the Backend duration in seconds.
 	SummaryVec = promauto.NewHistogram(prometheus.HistogramOpts{
 		Namespace: "vanity",
@@ -108,21 +133,114 @@ type Handler struct {
 	api Backend
 
 	// DocURL is the base URL browsers are redirected to - default is https://pkg.go.dev/
+	//
+	// DocURL can also be a text/template, e.g. "https://docs.example.com/{{.ImportPath}}",
+	// executed with the fields ImportPath, the import path of the vanity URL
+	// configuration, Subpath, the remainder of the requested path, and Host.
 	DocURL string
 
+	// DocURLs maps import paths to documentation URLs, base URLs or templates
+	// like DocURL, which are used instead of DocURL for those import paths.
+	DocURLs map[string]string
+
 	// Duration is the timeout duration for the calls to the backend implementation.
 	// Default is five seconds.
 	Duration time.Duration
+
+	// GoSource enables the go-source meta tag - default is true.
+	GoSource bool
+
+	// DefaultBranch is the branch used in go-source links - default is master.
+	DefaultBranch string
+
+	// Forges maps the hosts of repositories to their forge type, e.g.
+	// "git.example.com" to "gitlab", for hosts whose forge type cannot be
+	// detected.
+	Forges map[string]string
+
+	flights flightGroup
+}
+
+// A HandlerOption is an option for a vanity Handler.
+type HandlerOption interface {
+	Apply(*Handler)
+}
+
+// WithGoSource enables or disables the go-source meta tag.
+func WithGoSource(enabled bool) HandlerOption {
+	return withGoSource{enabled}
+}
+
+type withGoSource struct{ enabled bool }
+
+func (w withGoSource) Apply(h *Handler) {
+	h.GoSource = w.enabled
+}
+
+// WithDefaultBranch configures the branch used in go-source links; default is
+// "master".
+func WithDefaultBranch(branch string) HandlerOption {
+	return withDefaultBranch{branch}
+}
+
+type withDefaultBranch struct{ branch string }
+
+func (w withDefaultBranch) Apply(h *Handler) {
+	h.DefaultBranch = w.branch
+}
+
+// WithForges configures the forge types of repository hosts, e.g.
+// "git.example.com" to "gitlab".
+func WithForges(forges map[string]string) HandlerOption {
+	return withForges{forges}
+}
+
+type withForges struct{ forges map[string]string }
+
+func (w withForges) Apply(h *Handler) {
+	h.Forges = w.forges
+}
+
+// WithDocURL configures the base URL, or template, browsers are redirected
+// to; default is https://pkg.go.dev/.
+func WithDocURL(docURL string) HandlerOption {
+	return withDocURL{docURL}
+}
+
+type withDocURL struct{ docURL string }
+
+func (w withDocURL) Apply(h *Handler) {
+	h.DocURL = w.docURL
+}
+
+// WithDocURLs configures documentation URLs, base URLs or templates, for
+// specific import paths.
+func WithDocURLs(docURLs map[string]string) HandlerOption {
+	return withDocURLs{docURLs}
+}
+
+type withDocURLs struct{ docURLs map[string]string }
+
+func (w withDocURLs) Apply(h *Handler) {
+	h.DocURLs = w.docURLs
 }
 
 // NewVanityHandler creates a new http.Handler that services vanity URLs using api
 // as a backend service.
-func NewVanityHandler(api Backend) http.Handler {
-	return &Handler{
-		api:      api,
-		DocURL:   DefaultDocURL,
-		Duration: 5 * time.Second, // nolint
+func NewVanityHandler(api Backend, opts ...HandlerOption) http.Handler {
+	h := &Handler{
+		api:           api,
+		DocURL:        DefaultDocURL,
+		Duration:      5 * time.Second, // nolint
+		GoSource:      true,
+		DefaultBranch: DefaultBranch,
+	}
+
+	for _, o := range opts {
+		o.Apply(h)
 	}
+
+	return h
 }
 
 func (s *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -138,21 +256,19 @@ func (s *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	ctx, _ := context.WithTimeout(r.Context(), s.Duration) // nolint
 
-	root := r.URL.Path
-	paths := strings.FieldsFunc(root, func(c rune) bool { return c == '/' })
-	if len(paths) > 0 {
-		root = "/" + paths[0]
-	}
-
-	importPath := host(r) + root
+	path := host(r) + r.URL.Path
 
-	vcs, repoRoot, err := s.timedGet(ctx, importPath)
+	entry, stale, err := s.timedGet(ctx, path)
+	if stale {
+		APIStale.Inc()
+		w.Header().Set("Warning", staleWarning)
+	}
 	if err != nil {
 		if err == ErrNotFound {
 			APINotFound.Inc()
 			http.NotFound(w, r)
 		} else {
-			logger.Printf("Unable to get %s: %s", importPath, err)
+			logger.Printf("Unable to get %s: %s", path, err)
 			APIErrors.Inc()
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
@@ -160,17 +276,46 @@ func (s *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vcsRoot := repoRoot + root
+	importPath := entry.ImportPath
+	vcsRoot := entry.VCSPath + strings.TrimPrefix(importPath, host(r))
 
 	if r.FormValue("go-get") != "1" {
+		url, err := s.docURL(entry, path, host(r))
+		if err != nil {
+			logger.Printf("Unable to generate doc URL for %s: %s", importPath, err)
+			APIErrTemplates.Inc()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
 		APIDocRedirects.Inc()
-		url := "https://pkg.go.dev/" + importPath
 		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 
 		return
 	}
 
-	body, err := templatize(host(r)+r.URL.Path, vcs, vcsRoot)
+	var source *sourceLinks
+	if s.GoSource {
+		forge := entry.SourceTemplate
+		if forge == "" {
+			forge = DetectForge(vcsRoot, s.Forges)
+		}
+		branch := entry.DefaultBranch
+		if branch == "" {
+			branch = s.DefaultBranch
+		}
+		source, err = sourceFor(forge, vcsRoot, branch)
+		if err != nil {
+			logger.Printf("Unable to generate go-source for %s: %s", importPath, err)
+			APIErrTemplates.Inc()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	body, err := templatize(importPath, entry.VCS, vcsRoot, source)
 	if err != nil {
 		logger.Printf("Unable to templatize %s: %s", importPath, err)
 		APIErrTemplates.Inc()
@@ -187,13 +332,44 @@ func (s *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Handler) timedGet(ctx context.Context, importPath string) (vcs, vcsPath string, err error) {
-	start := time.Now()
-	defer SummaryVec.Observe(time.Since(start).Seconds())
+// docURL generates the URL browsers are redirected to for path, which is
+// served by the vanity URL configuration entry.  The entry's DocURL takes
+// precedence over DocURLs, which takes precedence over DocURL.
+func (s *Handler) docURL(entry *Entry, path, host string) (string, error) {
+	importPath := entry.ImportPath
+
+	docURL := entry.DocURL
+	if docURL == "" {
+		var ok bool
+		if docURL, ok = s.DocURLs[importPath]; !ok {
+			docURL = s.DocURL
+		}
+	}
 
-	vcs, vcsPath, err = s.api.Get(ctx, importPath)
+	var subpath string
+	if prefixes := Prefixes(path); len(prefixes) > 0 {
+		subpath = strings.TrimPrefix(strings.TrimPrefix(prefixes[0], importPath), "/")
+	}
 
-	return
+	return docURLFor(docURL, &docData{ImportPath: importPath, Subpath: subpath, Host: host})
+}
+
+// timedGet obtains the vanity URL configuration whose import path is the
+// longest prefix of path, and whether the backend reported it stale.
+// Concurrent calls for the same path are coalesced into a single backend call.
+func (s *Handler) timedGet(ctx context.Context, path string) (*Entry, bool, error) {
+	return s.flights.do(ctx, path, func(ctx context.Context) (*Entry, bool, error) {
+		start := time.Now()
+		defer func() { SummaryVec.Observe(time.Since(start).Seconds()) }()
+
+		ctx, cancel := context.WithTimeout(ctx, s.Duration)
+		defer cancel()
+
+		ctx, stale := WithStaleReport(ctx)
+		e, err := GetPrefix(ctx, s.api, path)
+
+		return e, stale(), err
+	})
 }
 
 func host(r *http.Request) string {
@@ -204,12 +380,13 @@ func host(r *http.Request) string {
 	return r.Header.Get(xForwardedHost)
 }
 
-func templatize(importRoot, vcs, vcsRoot string) (body []byte, err error) {
+func templatize(importRoot, vcs, vcsRoot string, source *sourceLinks) (body []byte, err error) {
 	logger.Printf("%s %s %s", importRoot, vcs, vcsRoot)
 	d := &data{
 		ImportRoot: importRoot,
 		VCS:        vcs,
 		VCSRoot:    vcsRoot,
+		Source:     source,
 	}
 	var buf bytes.Buffer
 	err = tmpl.Execute(&buf, d)
@@ -29,7 +29,24 @@ func TestTemplatize(t *testing.T) {
 </head>
 </html>
 `
-	body, err := templatize("a", "b", "c")
+	source, err := sourceFor(ForgeGitHub, "c", DefaultBranch)
+	assert.NoError(t, err)
+
+	body, err := templatize("a", "b", "c", source)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(body))
+}
+
+func TestTemplatize_no_source(t *testing.T) {
+	expected := `<!DOCTYPE html>
+<html>
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="go-import" content="a b c">
+</head>
+</html>
+`
+	body, err := templatize("a", "b", "c", nil)
 	assert.NoError(t, err)
 	assert.Equal(t, expected, string(body))
 }
@@ -34,6 +34,7 @@ func prometheusReset() {
 	prometheusSnapshot(vanity.APINotFound)
 	prometheusSnapshot(vanity.APIDocRedirects)
 	prometheusSnapshot(vanity.APIErrTemplates)
+	prometheusSnapshot(vanity.APIStale)
 }
 
 func prometheusSnapshot(c prometheus.Counter) {
@@ -17,6 +17,7 @@
 package vanity_test
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -28,6 +29,7 @@ import (
 
 	"l7e.io/vanity"
 	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/pkg/memory"
 )
 
 var errNotHealthy = fmt.Errorf("not healthy")
@@ -125,6 +127,40 @@ func TestHandler_ServeHTTP_get(t *testing.T) {
 	prometheusCheck(t, 1, 0, 0, 0, 0)
 }
 
+type staleBackend struct {
+	apitest.MockBackend
+}
+
+func (b *staleBackend) Get(ctx context.Context, importPath string) (string, string, error) {
+	vanity.MarkStale(ctx)
+	return b.MockBackend.Get(ctx, importPath)
+}
+
+func TestHandler_ServeHTTP_get_stale(t *testing.T) {
+	prometheusReset()
+
+	h := vanity.NewVanityHandler(&staleBackend{apitest.MockBackend{Urls: map[string][]string{"a.com/b": {"vcs", "vcsPath"}}}})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "https://a.com/b?go-get=1", nil)
+	h.ServeHTTP(w, r)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `110 - "Response is Stale"`, resp.Header.Get("Warning"))
+
+	prometheusCheck(t, 1, 0, 0, 0, 0)
+	prometheusCheckMetric(t, vanity.APIStale, 1)
+
+	h = vanity.NewVanityHandler(&apitest.MockBackend{Urls: map[string][]string{"a.com/b": {"vcs", "vcsPath"}}})
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	resp = w.Result()
+	assert.Equal(t, "", resp.Header.Get("Warning"))
+}
+
 func TestHandler_ServeHTTP_get_extendedPath(t *testing.T) {
 	prometheusReset()
 
@@ -132,8 +168,8 @@ func TestHandler_ServeHTTP_get_extendedPath(t *testing.T) {
 <html>
 <head>
   <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
-  <meta name="go-import" content="a.com/b/v1 vcs vcsPath/b">
-  <meta name="go-source" content="a.com/b/v1 vcsPath/b vcsPath/b/tree/master{/dir} vcsPath/b/blob/master{/dir}/{file}#L{line}">
+  <meta name="go-import" content="a.com/b vcs vcsPath/b">
+  <meta name="go-source" content="a.com/b vcsPath/b vcsPath/b/tree/master{/dir} vcsPath/b/blob/master{/dir}/{file}#L{line}">
 </head>
 </html>
 `
@@ -152,3 +188,215 @@ func TestHandler_ServeHTTP_get_extendedPath(t *testing.T) {
 
 	prometheusCheck(t, 1, 0, 0, 0, 0)
 }
+
+func TestHandler_ServeHTTP_get_longestPrefix(t *testing.T) {
+	prometheusReset()
+
+	expected := `<!DOCTYPE html>
+<html>
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="go-import" content="a.com/org/tool vcs vcsPath/org/tool">
+  <meta name="go-source" content="a.com/org/tool vcsPath/org/tool vcsPath/org/tool/tree/master{/dir} vcsPath/org/tool/blob/master{/dir}/{file}#L{line}">
+</head>
+</html>
+`
+
+	h := vanity.NewVanityHandler(&apitest.MockBackend{Urls: map[string][]string{
+		"a.com/org":      {"vcs", "otherPath"},
+		"a.com/org/tool": {"vcs", "vcsPath"},
+	}})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "https://a.com/org/tool/cmd/x?go-get=1", nil)
+	h.ServeHTTP(w, r)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(body))
+
+	prometheusCheck(t, 1, 0, 0, 0, 0)
+}
+
+func TestHandler_ServeHTTP_get_gitlab(t *testing.T) {
+	prometheusReset()
+
+	expected := `<!DOCTYPE html>
+<html>
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="go-import" content="a.com/b git https://gitlab.com/org/b">
+  <meta name="go-source" content="a.com/b https://gitlab.com/org/b https://gitlab.com/org/b/-/tree/main{/dir} https://gitlab.com/org/b/-/blob/main{/dir}/{file}#L{line}">
+</head>
+</html>
+`
+
+	h := vanity.NewVanityHandler(&apitest.MockBackend{Urls: map[string][]string{"a.com/b": {"git", "https://gitlab.com/org"}}},
+		vanity.WithDefaultBranch("main"))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "https://a.com/b?go-get=1", nil)
+	h.ServeHTTP(w, r)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(body))
+
+	prometheusCheck(t, 1, 0, 0, 0, 0)
+}
+
+func TestHandler_ServeHTTP_get_forges(t *testing.T) {
+	prometheusReset()
+
+	expected := `<!DOCTYPE html>
+<html>
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="go-import" content="a.com/b git https://git.a.com/org/b">
+  <meta name="go-source" content="a.com/b https://git.a.com/org/b https://git.a.com/org/b/src/branch/master{/dir} https://git.a.com/org/b/src/branch/master{/dir}/{file}#L{line}">
+</head>
+</html>
+`
+
+	h := vanity.NewVanityHandler(&apitest.MockBackend{Urls: map[string][]string{"a.com/b": {"git", "https://git.a.com/org"}}},
+		vanity.WithForges(map[string]string{"git.a.com": vanity.ForgeGitea}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "https://a.com/b?go-get=1", nil)
+	h.ServeHTTP(w, r)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(body))
+
+	prometheusCheck(t, 1, 0, 0, 0, 0)
+}
+
+func TestHandler_ServeHTTP_get_no_go_source(t *testing.T) {
+	prometheusReset()
+
+	expected := `<!DOCTYPE html>
+<html>
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="go-import" content="a.com/b vcs vcsPath/b">
+</head>
+</html>
+`
+
+	h := vanity.NewVanityHandler(&apitest.MockBackend{Urls: map[string][]string{"a.com/b": {"vcs", "vcsPath"}}},
+		vanity.WithGoSource(false))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "https://a.com/b?go-get=1", nil)
+	h.ServeHTTP(w, r)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(body))
+
+	prometheusCheck(t, 1, 0, 0, 0, 0)
+}
+
+func TestHandler_ServeHTTP_get_doc_url(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []vanity.HandlerOption
+		path     string
+		expected string
+	}{
+		{"base", []vanity.HandlerOption{vanity.WithDocURL("https://pkgsite.a.com/")},
+			"https://a.com/b/c", "https://pkgsite.a.com/a.com/b"},
+		{"template", []vanity.HandlerOption{vanity.WithDocURL("https://docs.{{.Host}}/{{.ImportPath}}?sub={{.Subpath}}")},
+			"https://a.com/b/c/d", "https://docs.a.com/a.com/b?sub=c/d"},
+		{"override", []vanity.HandlerOption{vanity.WithDocURLs(map[string]string{"a.com/b": "https://b.a.com/{{.Subpath}}"})},
+			"https://a.com/b/c", "https://b.a.com/c"},
+		{"other override", []vanity.HandlerOption{vanity.WithDocURLs(map[string]string{"a.com/z": "https://z.a.com/"})},
+			"https://a.com/b/c", "https://pkg.go.dev/a.com/b"},
+	}
+
+	for _, tt := range tests {
+		prometheusReset()
+
+		h := vanity.NewVanityHandler(&apitest.MockBackend{Urls: map[string][]string{"a.com/b": {"vcs", "vcsPath"}}}, tt.opts...)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", tt.path, nil)
+		h.ServeHTTP(w, r)
+
+		resp := w.Result()
+		assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode, tt.name)
+		assert.Equal(t, tt.expected, resp.Header.Get("Location"), tt.name)
+
+		prometheusCheck(t, 1, 0, 0, 1, 0)
+	}
+}
+
+func TestHandler_ServeHTTP_get_bad_doc_url(t *testing.T) {
+	prometheusReset()
+
+	h := vanity.NewVanityHandler(&apitest.MockBackend{Urls: map[string][]string{"a.com/b": {"vcs", "vcsPath"}}},
+		vanity.WithDocURL("https://docs.a.com/{{.Nope}}"))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "https://a.com/b", nil)
+	h.ServeHTTP(w, r)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	prometheusCheck(t, 1, 0, 0, 0, 1)
+}
+
+func TestHandler_ServeHTTP_get_entry_metadata(t *testing.T) {
+	expected := `<!DOCTYPE html>
+<html>
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="go-import" content="a.com/b git https://git.a.com/org/b">
+  <meta name="go-source" content="a.com/b https://git.a.com/org/b https://git.a.com/org/b/-/tree/main{/dir} https://git.a.com/org/b/-/blob/main{/dir}/{file}#L{line}">
+</head>
+</html>
+`
+
+	be := memory.NewInMemoryAPI()
+	entry := vanity.NewEntry("a.com/b", "git", "https://git.a.com/org")
+	entry.DefaultBranch = "main"
+	entry.SourceTemplate = vanity.ForgeGitLab
+	entry.DocURL = "https://docs.a.com/{{.Subpath}}"
+	assert.NoError(t, be.InsertEntry(context.Background(), entry))
+
+	h := vanity.NewVanityHandler(be,
+		vanity.WithForges(map[string]string{"git.a.com": vanity.ForgeGitea}),
+		vanity.WithDocURLs(map[string]string{"a.com/b": "https://b.a.com/"}))
+
+	prometheusReset()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "https://a.com/b?go-get=1", nil)
+	h.ServeHTTP(w, r)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(body))
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "https://a.com/b/c", nil)
+	h.ServeHTTP(w, r)
+
+	resp = w.Result()
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	assert.Equal(t, "https://docs.a.com/c", resp.Header.Get("Location"))
+
+	prometheusCheck(t, 2, 0, 0, 1, 0)
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortOrder is the order, by import path, of listed vanity URL configurations.
+type SortOrder int
+
+// Sort orders.
+const (
+	Ascending SortOrder = iota
+	Descending
+)
+
+// ErrInvalidPageToken is returned if a page token cannot be decoded.
+var ErrInvalidPageToken = fmt.Errorf("invalid page token")
+
+// ListOptions select a page of vanity URL configurations.
+type ListOptions struct {
+	// Prefix only selects configurations whose import path starts with it.
+	Prefix string
+
+	// PageSize is the maximum number of configurations in the page; zero
+	// selects all of them.
+	PageSize int
+
+	// PageToken continues the listing after the previous page; the token
+	// is returned by the call that listed the previous page.
+	PageToken string
+
+	// Order is the order of the configurations.
+	Order SortOrder
+}
+
+// Pager is an optional interface implemented by Backend implementations that
+// can natively filter and page vanity URL configurations.
+type Pager interface {
+	// ListPage lists a page of vanity URL configurations, delivering them to
+	// the consumer callback.  The token of the next page is returned, which
+	// is empty if there are no more configurations.
+	ListPage(ctx context.Context, opts ListOptions, consumer EntryConsumer) (nextPageToken string, err error)
+}
+
+// ListPage lists a page of the vanity URL configurations of api.  If api
+// implements Pager, its ListPage method is used; otherwise all the
+// configurations are listed and then filtered and paged using PageEntries.
+func ListPage(ctx context.Context, api Backend, opts ListOptions, consumer EntryConsumer) (string, error) {
+	if p, ok := api.(Pager); ok {
+		return p.ListPage(ctx, opts, consumer)
+	}
+
+	var entries []*Entry
+	err := AsEntryBackend(api).ListEntries(ctx, EntryConsumerFunc(func(_ context.Context, e *Entry) {
+		entries = append(entries, e)
+	}))
+	if err != nil {
+		return "", err
+	}
+
+	page, next, err := PageEntries(entries, opts)
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range page {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		consumer.Consume(ctx, e)
+	}
+
+	return next, nil
+}
+
+// PageEntries is a helper for Pager implementations that keep their
+// configurations in memory, which selects a page of entries.  The page tokens
+// are those of EncodePageToken.
+func PageEntries(entries []*Entry, opts ListOptions) (page []*Entry, nextPageToken string, err error) {
+	after, err := DecodePageToken(opts.PageToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, e := range entries {
+		if !strings.HasPrefix(e.ImportPath, opts.Prefix) {
+			continue
+		}
+		if after != "" && !isAfter(e.ImportPath, after, opts.Order) {
+			continue
+		}
+		page = append(page, e)
+	}
+
+	sort.Slice(page, func(i, j int) bool {
+		return isAfter(page[j].ImportPath, page[i].ImportPath, opts.Order)
+	})
+
+	if opts.PageSize > 0 && len(page) > opts.PageSize {
+		page = page[:opts.PageSize]
+		nextPageToken = EncodePageToken(page[len(page)-1].ImportPath)
+	}
+
+	return page, nextPageToken, nil
+}
+
+// isAfter reports whether importPath is listed after other in order.
+func isAfter(importPath, other string, order SortOrder) bool {
+	if order == Descending {
+		return importPath < other
+	}
+
+	return importPath > other
+}
+
+// EncodePageToken encodes the import path of the last configuration of a
+// page as the token of the next page.
+func EncodePageToken(importPath string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(importPath))
+}
+
+// DecodePageToken decodes a token created by EncodePageToken, returning the
+// import path of the last configuration of the previous page.
+// ErrInvalidPageToken is returned if the token cannot be decoded.
+func DecodePageToken(token string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", ErrInvalidPageToken
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+)
+
+func importPaths(entries []*vanity.Entry) []string {
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.ImportPath)
+	}
+
+	return paths
+}
+
+func TestPageEntries(t *testing.T) {
+	entries := []*vanity.Entry{
+		vanity.NewEntry("a.com/d", "git", "https://github.com/d"),
+		vanity.NewEntry("a.com/b", "git", "https://github.com/b"),
+		vanity.NewEntry("b.com/a", "git", "https://github.com/a"),
+		vanity.NewEntry("a.com/c", "git", "https://github.com/c"),
+	}
+
+	page, next, err := vanity.PageEntries(entries, vanity.ListOptions{Prefix: "a.com/", PageSize: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.com/b", "a.com/c"}, importPaths(page))
+	assert.NotEmpty(t, next)
+
+	page, next, err = vanity.PageEntries(entries, vanity.ListOptions{Prefix: "a.com/", PageSize: 2, PageToken: next})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.com/d"}, importPaths(page))
+	assert.Empty(t, next)
+
+	page, next, err = vanity.PageEntries(entries, vanity.ListOptions{Order: vanity.Descending, PageSize: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b.com/a", "a.com/d", "a.com/c"}, importPaths(page))
+
+	page, next, err = vanity.PageEntries(entries, vanity.ListOptions{Order: vanity.Descending, PageToken: next})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.com/b"}, importPaths(page))
+	assert.Empty(t, next)
+
+	_, _, err = vanity.PageEntries(entries, vanity.ListOptions{PageToken: "!"})
+	assert.Equal(t, vanity.ErrInvalidPageToken, err)
+}
+
+func TestListPage_fallback(t *testing.T) {
+	mock := &apitest.MockBackend{Urls: map[string][]string{
+		"a.com/b": {"git", "https://github.com/b"},
+		"a.com/c": {"git", "https://github.com/c"},
+		"b.com/d": {"git", "https://github.com/d"},
+	}}
+
+	var paths []string
+	consumer := vanity.EntryConsumerFunc(func(_ context.Context, e *vanity.Entry) {
+		paths = append(paths, e.ImportPath)
+	})
+
+	next, err := vanity.ListPage(context.Background(), mock, vanity.ListOptions{Prefix: "a.com/", PageSize: 1}, consumer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.com/b"}, paths)
+
+	next, err = vanity.ListPage(context.Background(), mock, vanity.ListOptions{Prefix: "a.com/", PageSize: 1, PageToken: next}, consumer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.com/b", "a.com/c"}, paths)
+	assert.Empty(t, next)
+}
@@ -0,0 +1,439 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package bolt contains the Backend storing entries in a local bbolt file.
+package bolt // import "l7e.io/vanity/pkg/bolt"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bbolt "go.etcd.io/bbolt"
+
+	"l7e.io/vanity"
+)
+
+var errUnknownRecordVersion = fmt.Errorf("unknown record version")
+
+// record is a stored entry.
+type record struct {
+	Version        int               `json:"version"`
+	Revision       int64             `json:"revision"`
+	VCS            string            `json:"vcs"`
+	VCSPath        string            `json:"vcs_path"`
+	Description    string            `json:"description,omitempty"`
+	Owner          string            `json:"owner,omitempty"`
+	DefaultBranch  string            `json:"default_branch,omitempty"`
+	DocURL         string            `json:"doc_url,omitempty"`
+	SourceTemplate string            `json:"source_template,omitempty"`
+	Visibility     string            `json:"visibility,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Created        time.Time         `json:"created"`
+	Updated        time.Time         `json:"updated"`
+}
+
+func encodeEntry(e *vanity.Entry) ([]byte, error) {
+	return json.Marshal(&record{
+		Version:        e.Version,
+		Revision:       e.Revision,
+		VCS:            e.VCS,
+		VCSPath:        e.VCSPath,
+		Description:    e.Description,
+		Owner:          e.Owner,
+		DefaultBranch:  e.DefaultBranch,
+		DocURL:         e.DocURL,
+		SourceTemplate: e.SourceTemplate,
+		Visibility:     string(e.Visibility),
+		Labels:         e.Labels,
+		Created:        e.Created,
+		Updated:        e.Updated,
+	})
+}
+
+func decodeEntry(importPath, b []byte) (*vanity.Entry, error) {
+	var r record
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf(unableToExtractEntry, importPath, err)
+	}
+	if r.Version != vanity.EntryVersion {
+		return nil, fmt.Errorf(unableToExtractEntry, importPath, errUnknownRecordVersion)
+	}
+
+	return &vanity.Entry{
+		Version:        r.Version,
+		Revision:       r.Revision,
+		ImportPath:     string(importPath),
+		VCS:            r.VCS,
+		VCSPath:        r.VCSPath,
+		Description:    r.Description,
+		Owner:          r.Owner,
+		DefaultBranch:  r.DefaultBranch,
+		DocURL:         r.DocURL,
+		SourceTemplate: r.SourceTemplate,
+		Visibility:     vanity.Visibility(r.Visibility),
+		Labels:         r.Labels,
+		Created:        r.Created,
+		Updated:        r.Updated,
+	}, nil
+}
+
+type boltDB struct {
+	bucket []byte
+	events *vanity.Broadcaster
+
+	lock sync.RWMutex
+	db   *bbolt.DB
+}
+
+// NewBackend creates a Backend storing entries in the bbolt file at path,
+// which is created if it does not exist.  As the file is locked while the
+// Backend is open, the changes of other processes need not be watched for, and
+// watchers are notified of every change.
+func NewBackend(path string, opts ...BackendOption) (vanity.EntryBackend, error) {
+	s := &backendSettings{bucket: DefaultBucket, timeout: DefaultTimeout}
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: s.timeout})
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := []byte(s.bucket)
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &boltDB{
+		bucket: bucket,
+		events: vanity.NewBroadcaster(vanity.DefaultRetainedEvents),
+		db:     db,
+	}, nil
+}
+
+// view runs f in a read-only transaction, unless the Backend is closed.
+func (s *boltDB) view(f func(b *bbolt.Bucket) error) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.db == nil {
+		return vanity.ErrAlreadyClosed
+	}
+
+	return s.db.View(func(tx *bbolt.Tx) error {
+		return f(tx.Bucket(s.bucket))
+	})
+}
+
+func (s *boltDB) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+
+	db := s.db
+	s.db = nil
+	s.events.Close()
+
+	return db.Close()
+}
+
+func (s *boltDB) Watch(ctx context.Context, position string) (<-chan vanity.Event, error) {
+	return s.events.Watch(ctx, position)
+}
+
+// Healthz checks that the file is open.
+func (s *boltDB) Healthz(_ context.Context) error {
+	return s.view(func(*bbolt.Bucket) error { return nil })
+}
+
+func (s *boltDB) Get(ctx context.Context, importPath string) (vcs, vcsPath string, err error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *boltDB) GetEntry(_ context.Context, importPath string) (*vanity.Entry, error) {
+	var e *vanity.Entry
+	err := s.view(func(b *bbolt.Bucket) error {
+		v := b.Get([]byte(importPath))
+		if v == nil {
+			return vanity.ErrNotFound
+		}
+
+		var err error
+		e, err = decodeEntry([]byte(importPath), v)
+		return err
+	})
+
+	return e, err
+}
+
+func (s *boltDB) GetPrefix(_ context.Context, path string) (*vanity.Entry, error) {
+	var e *vanity.Entry
+	err := s.view(func(b *bbolt.Bucket) error {
+		for _, candidate := range vanity.Prefixes(path) {
+			if v := b.Get([]byte(candidate)); v != nil {
+				var err error
+				e, err = decodeEntry([]byte(candidate), v)
+				return err
+			}
+		}
+
+		return vanity.ErrNotFound
+	})
+
+	return e, err
+}
+
+func (s *boltDB) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return s.InsertEntry(ctx, vanity.NewEntry(importPath, vcs, vcsPath))
+}
+
+func (s *boltDB) InsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.single(ctx, vanity.Mutation{Op: vanity.MutationInsert, Entry: entry})
+}
+
+func (s *boltDB) UpdateEntry(ctx context.Context, entry *vanity.Entry, ifRevision int64) error {
+	return s.single(ctx, vanity.Mutation{Op: vanity.MutationUpdate, Entry: entry, IfRevision: ifRevision})
+}
+
+func (s *boltDB) UpsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.single(ctx, vanity.Mutation{Op: vanity.MutationUpsert, Entry: entry})
+}
+
+func (s *boltDB) Remove(ctx context.Context, importPath string) error {
+	return s.single(ctx, vanity.Mutation{Op: vanity.MutationRemove, Entry: &vanity.Entry{ImportPath: importPath}})
+}
+
+// single applies a single mutation, returning the reason it failed.
+func (s *boltDB) single(ctx context.Context, m vanity.Mutation) error {
+	err := s.Apply(ctx, []vanity.Mutation{m})
+	if me, ok := err.(*vanity.MutationError); ok {
+		return me.Err
+	}
+
+	return err
+}
+
+// Apply applies the mutations in a read-write transaction, notifying watchers
+// of the changes once it is committed.
+func (s *boltDB) Apply(_ context.Context, mutations []vanity.Mutation) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.db == nil {
+		return vanity.ErrAlreadyClosed
+	}
+
+	var events []vanity.Event
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+
+		entries := make(map[string]*vanity.Entry)
+		existed := make(map[string]bool)
+		for _, m := range mutations {
+			if m.Entry == nil {
+				continue
+			}
+			importPath := m.Entry.ImportPath
+			if v := b.Get([]byte(importPath)); v != nil {
+				e, err := decodeEntry([]byte(importPath), v)
+				if err != nil {
+					return err
+				}
+				entries[importPath] = e
+				existed[importPath] = true
+			}
+		}
+
+		if err := vanity.ApplyMutations(entries, mutations, time.Now().UTC()); err != nil {
+			return err
+		}
+
+		importPaths := make([]string, 0, len(entries))
+		for importPath := range entries {
+			importPaths = append(importPaths, importPath)
+		}
+		sort.Strings(importPaths)
+
+		for _, importPath := range importPaths {
+			e := entries[importPath]
+			switch {
+			case e == nil && !existed[importPath]:
+				continue
+			case e == nil:
+				if err := b.Delete([]byte(importPath)); err != nil {
+					return err
+				}
+				events = append(events, vanity.Event{Type: vanity.EventRemoved, ImportPath: importPath})
+				continue
+			case existed[importPath]:
+				events = append(events, vanity.Event{Type: vanity.EventUpdated, ImportPath: importPath, Entry: e})
+			default:
+				events = append(events, vanity.Event{Type: vanity.EventAdded, ImportPath: importPath, Entry: e})
+			}
+
+			v, err := encodeEntry(e)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(importPath), v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		s.events.Publish(e.Type, e.ImportPath, e.Entry)
+	}
+
+	return nil
+}
+
+func (s *boltDB) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+// ListEntries lists the entries in import path order.
+func (s *boltDB) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	_, err := s.ListPage(ctx, vanity.ListOptions{}, consumer)
+
+	return err
+}
+
+// ListPage lists a page of entries with an ordered scan of the keys, starting
+// at the prefix or the page token.
+func (s *boltDB) ListPage(ctx context.Context, opts vanity.ListOptions, consumer vanity.EntryConsumer) (string, error) {
+	after, err := vanity.DecodePageToken(opts.PageToken)
+	if err != nil {
+		return "", err
+	}
+
+	var page []*vanity.Entry
+	err = s.view(func(b *bbolt.Bucket) error {
+		prefix := []byte(opts.Prefix)
+		c := b.Cursor()
+
+		var k, v []byte
+		next := c.Next
+		if opts.Order == vanity.Descending {
+			k, v = seekBefore(c, upperBound(prefix, after))
+			next = c.Prev
+		} else {
+			k, v = c.Seek(prefix)
+			if after != "" && bytes.Compare(k, []byte(after)) <= 0 {
+				k, v = c.Seek([]byte(after))
+				if k != nil && string(k) == after {
+					k, v = c.Next()
+				}
+			}
+		}
+
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, v = next() {
+			if opts.PageSize > 0 && len(page) > opts.PageSize {
+				break
+			}
+
+			e, err := decodeEntry(k, v)
+			if err != nil {
+				return err
+			}
+			page = append(page, e)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	token := ""
+	if opts.PageSize > 0 && len(page) > opts.PageSize {
+		// the extra entry shows there is another page
+		page = page[:opts.PageSize]
+		token = vanity.EncodePageToken(page[len(page)-1].ImportPath)
+	}
+
+	for _, e := range page {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		consumer.Consume(ctx, e)
+	}
+
+	return token, nil
+}
+
+// upperBound returns the exclusive upper bound of the keys of a descending
+// scan of the keys with prefix that come before after; nil if unbounded.
+func upperBound(prefix []byte, after string) []byte {
+	var bound []byte
+
+	// the successor of the keys with prefix
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			bound = append(append([]byte{}, prefix[:i]...), prefix[i]+1)
+			break
+		}
+	}
+
+	if after != "" && (bound == nil || bytes.Compare([]byte(after), bound) < 0) {
+		bound = []byte(after)
+	}
+
+	return bound
+}
+
+// seekBefore moves the cursor to the last key before bound, or the last key
+// if bound is nil.
+func seekBefore(c *bbolt.Cursor, bound []byte) ([]byte, []byte) {
+	if bound == nil {
+		return c.Last()
+	}
+
+	if k, _ := c.Seek(bound); k == nil {
+		return c.Last()
+	}
+
+	return c.Prev()
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bolt_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/pkg/bolt"
+)
+
+func newBolt(t *testing.T) (vanity.EntryBackend, string, func()) {
+	dir, err := ioutil.TempDir("", "bolt")
+	assert.NoError(t, err)
+
+	path := filepath.Join(dir, "vanity.db")
+	be, err := bolt.NewBackend(path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return be, path, func() {
+		_ = be.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func factory(t *testing.T) (vanity.EntryBackend, func()) {
+	be, _, done := newBolt(t)
+	return be, done
+}
+
+func TestBolt(t *testing.T) {
+	apitest.TestEntryBackend(t, factory)
+}
+
+func TestBolt_Watch(t *testing.T) {
+	apitest.TestWatcher(t, factory)
+}
+
+func TestBolt_persistence(t *testing.T) {
+	be, path, done := newBolt(t)
+	defer done()
+
+	ctx := context.Background()
+
+	in := vanity.NewEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
+	in.Labels = map[string]string{"team": "platform"}
+	assert.NoError(t, be.InsertEntry(ctx, in))
+
+	e, err := be.GetEntry(ctx, "l7e.io/vanity")
+	assert.NoError(t, err)
+	e.VCSPath = "https://gitlab.com/livetribe/vanity"
+	assert.NoError(t, be.UpdateEntry(ctx, e, 1))
+
+	// the entries are kept in the file
+	assert.NoError(t, be.Close())
+	be, err = bolt.NewBackend(path)
+	assert.NoError(t, err)
+	defer func() { _ = be.Close() }()
+
+	updated, err := be.GetEntry(ctx, "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Revision)
+	assert.Equal(t, "https://gitlab.com/livetribe/vanity", updated.VCSPath)
+	assert.Equal(t, map[string]string{"team": "platform"}, updated.Labels)
+	assert.True(t, e.Created.Equal(updated.Created))
+}
+
+func TestBolt_locked(t *testing.T) {
+	be, path, done := newBolt(t)
+	defer done()
+
+	assert.NoError(t, be.Add(context.Background(), "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+
+	// the file is locked by the open backend
+	_, err := bolt.NewBackend(path, bolt.WithTimeout(10*time.Millisecond))
+	assert.Error(t, err)
+
+	assert.NoError(t, be.Close())
+
+	opened, err := vanity.Open(context.Background(), "bolt://"+path+"?bucket=urls")
+	assert.NoError(t, err)
+	defer func() { _ = opened.Close() }()
+
+	_, _, err = opened.Get(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+
+	_, err = vanity.Open(context.Background(), "bolt://")
+	assert.Equal(t, vanity.ErrInvalidDSN, err)
+}
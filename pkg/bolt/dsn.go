@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bolt
+
+import (
+	"context"
+	"net/url"
+
+	"l7e.io/vanity"
+)
+
+func init() { //nolint:gochecknoinits
+	vanity.RegisterBackend("bolt", openDSN)
+}
+
+// openDSN creates a bbolt-based Backend from a data source name such as
+// "bolt:///var/lib/vanity/db?bucket=urls".
+func openDSN(_ context.Context, dsn *url.URL) (vanity.Backend, error) {
+	path := dsn.Opaque
+	if path == "" {
+		path = dsn.Host + dsn.Path
+	}
+	if path == "" {
+		return nil, vanity.ErrInvalidDSN
+	}
+
+	var opts []BackendOption
+	if b := dsn.Query().Get("bucket"); b != "" {
+		opts = append(opts, WithBucket(b))
+	}
+
+	return NewBackend(path, opts...)
+}
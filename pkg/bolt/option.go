@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bolt
+
+import "time"
+
+const (
+	// DefaultBucket is the default bucket of the entries.
+	DefaultBucket = "urls"
+
+	// DefaultTimeout is the default time waited for the lock of the file,
+	// which is held by a single process at a time.
+	DefaultTimeout = time.Second
+)
+
+type backendSettings struct {
+	bucket  string
+	timeout time.Duration
+}
+
+// A BackendOption is an option for a bbolt-based backend.
+type BackendOption interface {
+	Apply(*backendSettings)
+}
+
+// WithBucket configures the bucket of the entries; default is "urls".
+func WithBucket(b string) BackendOption {
+	return withBucket{b}
+}
+
+type withBucket struct{ b string }
+
+func (w withBucket) Apply(o *backendSettings) {
+	o.bucket = w.b
+}
+
+// WithTimeout configures the time waited for the lock of the file; default
+// is DefaultTimeout.
+func WithTimeout(d time.Duration) BackendOption {
+	return withTimeout{d}
+}
+
+type withTimeout struct{ d time.Duration }
+
+func (w withTimeout) Apply(o *backendSettings) {
+	o.timeout = w.d
+}
@@ -0,0 +1,358 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"l7e.io/vanity"
+)
+
+const (
+	// DefaultSize is the default maximum number of cached lookups.
+	DefaultSize = 10000
+
+	// DefaultTTL is the default time found configurations are cached.
+	DefaultTTL = time.Minute
+
+	// DefaultNegativeTTL is the default time import paths that are not found
+	// are cached.
+	DefaultNegativeTTL = 10 * time.Second
+)
+
+var (
+	// Hits is a Prometheus counter that tracks the total cache hits, found or not.
+	Hits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "cache",
+		Name:      "hits_total",
+		Help:      "The total cache hits",
+	})
+
+	// NegativeHits is a Prometheus counter that tracks the total cache hits of
+	// import paths that are not found.
+	NegativeHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "cache",
+		Name:      "negative_hits_total",
+		Help:      "The total cache hits of import paths not found",
+	})
+
+	// Misses is a Prometheus counter that tracks the total cache misses.
+	Misses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "cache",
+		Name:      "misses_total",
+		Help:      "The total cache misses",
+	})
+
+	// Evictions is a Prometheus counter that tracks the total lookups evicted
+	// to bound the cache size.
+	Evictions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "cache",
+		Name:      "evictions_total",
+		Help:      "The total cache evictions",
+	})
+)
+
+type settings struct {
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+// An Option is an option for a caching Backend.
+type Option interface {
+	Apply(*settings)
+}
+
+// WithSize configures the maximum number of cached lookups; default is
+// DefaultSize.
+func WithSize(size int) Option {
+	return sizeOption{size: size}
+}
+
+type sizeOption struct{ size int }
+
+func (s sizeOption) Apply(o *settings) {
+	o.size = s.size
+}
+
+// WithTTL configures the time found configurations are cached; default is
+// DefaultTTL.
+func WithTTL(ttl time.Duration) Option {
+	return ttlOption{ttl: ttl}
+}
+
+type ttlOption struct{ ttl time.Duration }
+
+func (t ttlOption) Apply(o *settings) {
+	o.ttl = t.ttl
+}
+
+// WithNegativeTTL configures the time import paths that are not found are
+// cached; default is DefaultNegativeTTL.  A zero TTL disables negative caching.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return negativeTTLOption{ttl: ttl}
+}
+
+type negativeTTLOption struct{ ttl time.Duration }
+
+func (t negativeTTLOption) Apply(o *settings) {
+	o.negativeTTL = t.ttl
+}
+
+type cachingBE struct {
+	be          vanity.EntryBackend
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	lock sync.Mutex
+	// generation is incremented by every change, so lookups racing a change
+	// are not cached
+	generation uint64
+	entries    *lru
+	prefixes   *lru
+}
+
+// NewCachingBackend creates a Backend decorating be with a read-through cache
+// of its lookups, using the specified options.
+//
+// Configurations are cached by import path; those changed through the
+// caching Backend are invalidated, while those changed elsewhere are served
+// from the cache until their TTL expires.
+func NewCachingBackend(be vanity.Backend, options ...Option) vanity.EntryBackend {
+	s := settings{
+		size:        DefaultSize,
+		ttl:         DefaultTTL,
+		negativeTTL: DefaultNegativeTTL,
+	}
+	for _, o := range options {
+		o.Apply(&s)
+	}
+
+	c := &cachingBE{
+		be:          vanity.AsEntryBackend(be),
+		ttl:         s.ttl,
+		negativeTTL: s.negativeTTL,
+		now:         time.Now,
+		entries:     newLRU(s.size),
+		prefixes:    newLRU(s.size),
+	}
+
+	w, watches := be.(vanity.Watcher)
+	rc, checksReadiness := be.(vanity.ReadinessChecker)
+	switch {
+	case watches && checksReadiness:
+		return &watchingReadinessCheckingBE{cachingBE: c, Watcher: w, ReadinessChecker: rc}
+	case watches:
+		return &watchingBE{cachingBE: c, Watcher: w}
+	case checksReadiness:
+		return &readinessCheckingBE{cachingBE: c, ReadinessChecker: rc}
+	default:
+		return c
+	}
+}
+
+// watchingBE forwards the changes watched on the decorated backend.
+type watchingBE struct {
+	*cachingBE
+	vanity.Watcher
+}
+
+// readinessCheckingBE forwards the readiness of the decorated backend.
+type readinessCheckingBE struct {
+	*cachingBE
+	vanity.ReadinessChecker
+}
+
+// watchingReadinessCheckingBE forwards both the changes watched on, and the
+// readiness of, the decorated backend.
+type watchingReadinessCheckingBE struct {
+	*cachingBE
+	vanity.Watcher
+	vanity.ReadinessChecker
+}
+
+// lookup returns the cached lookup of key, or calls get and caches its result.
+// Results the decorated backend reports stale are passed on, marking ctx
+// stale, but not cached.
+func (s *cachingBE) lookup(ctx context.Context, c *lru, key string, get func(context.Context) (*vanity.Entry, error)) (*vanity.Entry, error) {
+	s.lock.Lock()
+	it, ok := c.get(key, s.now())
+	generation := s.generation
+	s.lock.Unlock()
+
+	if ok {
+		Hits.Inc()
+		if it.entry == nil {
+			NegativeHits.Inc()
+			return nil, vanity.ErrNotFound
+		}
+		return it.entry.Clone(), nil
+	}
+
+	Misses.Inc()
+
+	getCtx, stale := vanity.WithStaleReport(ctx)
+	e, err := get(getCtx)
+	if err != nil && err != vanity.ErrNotFound {
+		return nil, err
+	}
+	if stale() {
+		vanity.MarkStale(ctx)
+		return e, err
+	}
+
+	ttl := s.ttl
+	if e == nil {
+		ttl = s.negativeTTL
+	}
+
+	if ttl > 0 {
+		s.lock.Lock()
+		if generation == s.generation {
+			it := &item{key: key, expires: s.now().Add(ttl)}
+			if e != nil {
+				it.entry = e.Clone()
+			}
+			Evictions.Add(float64(c.add(it)))
+		}
+		s.lock.Unlock()
+	}
+
+	return e, err
+}
+
+// invalidate removes the lookups of importPaths; as a new configuration may
+// be a longer prefix of any path, all prefix lookups are removed.
+func (s *cachingBE) invalidate(importPaths ...string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.generation++
+	for _, importPath := range importPaths {
+		s.entries.remove(importPath)
+	}
+	s.prefixes.purge()
+}
+
+func (s *cachingBE) Close() error {
+	s.lock.Lock()
+	s.entries.purge()
+	s.prefixes.purge()
+	s.lock.Unlock()
+
+	return s.be.Close()
+}
+
+func (s *cachingBE) Get(ctx context.Context, importPath string) (string, string, error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *cachingBE) GetEntry(ctx context.Context, importPath string) (*vanity.Entry, error) {
+	return s.lookup(ctx, s.entries, importPath, func(ctx context.Context) (*vanity.Entry, error) {
+		return s.be.GetEntry(ctx, importPath)
+	})
+}
+
+// GetPrefix caches the lookups of backends implementing vanity.PrefixGetter
+// by path; the prefixes of other backends are walked through GetEntry, and so
+// cached by import path.
+func (s *cachingBE) GetPrefix(ctx context.Context, path string) (*vanity.Entry, error) {
+	pg, ok := s.be.(vanity.PrefixGetter)
+	if !ok {
+		for _, candidate := range vanity.Prefixes(path) {
+			e, err := s.GetEntry(ctx, candidate)
+			if err == vanity.ErrNotFound {
+				continue
+			}
+
+			return e, err
+		}
+
+		return nil, vanity.ErrNotFound
+	}
+
+	return s.lookup(ctx, s.prefixes, path, func(ctx context.Context) (*vanity.Entry, error) {
+		return pg.GetPrefix(ctx, path)
+	})
+}
+
+func (s *cachingBE) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	defer s.invalidate(importPath)
+	return s.be.Add(ctx, importPath, vcs, vcsPath)
+}
+
+func (s *cachingBE) InsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	defer s.invalidate(entry.ImportPath)
+	return s.be.InsertEntry(ctx, entry)
+}
+
+func (s *cachingBE) UpdateEntry(ctx context.Context, entry *vanity.Entry, ifRevision int64) error {
+	defer s.invalidate(entry.ImportPath)
+	return s.be.UpdateEntry(ctx, entry, ifRevision)
+}
+
+func (s *cachingBE) UpsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	defer s.invalidate(entry.ImportPath)
+	return s.be.UpsertEntry(ctx, entry)
+}
+
+func (s *cachingBE) Apply(ctx context.Context, mutations []vanity.Mutation) error {
+	importPaths := make([]string, 0, len(mutations))
+	for _, m := range mutations {
+		if m.Entry != nil {
+			importPaths = append(importPaths, m.Entry.ImportPath)
+		}
+	}
+
+	defer s.invalidate(importPaths...)
+	return vanity.Apply(ctx, s.be, mutations)
+}
+
+func (s *cachingBE) Remove(ctx context.Context, importPath string) error {
+	defer s.invalidate(importPath)
+	return s.be.Remove(ctx, importPath)
+}
+
+func (s *cachingBE) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.be.List(ctx, consumer)
+}
+
+func (s *cachingBE) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	return s.be.ListEntries(ctx, consumer)
+}
+
+func (s *cachingBE) ListPage(ctx context.Context, opts vanity.ListOptions, consumer vanity.EntryConsumer) (string, error) {
+	return vanity.ListPage(ctx, s.be, opts, consumer)
+}
+
+func (s *cachingBE) Healthz(ctx context.Context) error {
+	return s.be.Healthz(ctx)
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+)
+
+// countingBackend counts the lookups reaching the decorated backend.
+type countingBackend struct {
+	*apitest.MockBackend
+	gets  int
+	stale bool
+}
+
+func (c *countingBackend) Get(ctx context.Context, importPath string) (string, string, error) {
+	c.gets++
+	if c.stale {
+		vanity.MarkStale(ctx)
+	}
+	return c.MockBackend.Get(ctx, importPath)
+}
+
+// watchingBackend is a countingBackend implementing vanity.Watcher and
+// vanity.ReadinessChecker.
+type watchingBackend struct {
+	*countingBackend
+	events *vanity.Broadcaster
+}
+
+func (w *watchingBackend) Watch(ctx context.Context, position string) (<-chan vanity.Event, error) {
+	return w.events.Watch(ctx, position)
+}
+
+func (w *watchingBackend) Readyz(_ context.Context) error {
+	return vanity.ErrNotReady
+}
+
+func counter(c prometheus.Counter) float64 {
+	m := &dto.Metric{}
+	_ = c.Write(m)
+	return m.Counter.GetValue()
+}
+
+func newTestBackend(options ...Option) (*countingBackend, *cachingBE, *time.Time) {
+	inner := &countingBackend{MockBackend: &apitest.MockBackend{Urls: map[string][]string{
+		"l7e.io/vanity": {"git", "https://github.com/livetribe/vanity"},
+	}}}
+
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	be := NewCachingBackend(inner, options...).(*cachingBE)
+	be.now = func() time.Time { return now }
+
+	return inner, be, &now
+}
+
+func TestCache_hits(t *testing.T) {
+	inner, be, now := newTestBackend(WithTTL(time.Minute), WithNegativeTTL(10*time.Second))
+	hits, misses, negative := counter(Hits), counter(Misses), counter(NegativeHits)
+
+	for i := 0; i < 3; i++ {
+		_, vcsPath, err := be.Get(context.Background(), "l7e.io/vanity")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://github.com/livetribe/vanity", vcsPath)
+
+		_, _, err = be.Get(context.Background(), "l7e.io/unknown")
+		assert.Equal(t, vanity.ErrNotFound, err)
+	}
+
+	assert.Equal(t, 2, inner.gets)
+	assert.Equal(t, float64(4), counter(Hits)-hits)
+	assert.Equal(t, float64(2), counter(NegativeHits)-negative)
+	assert.Equal(t, float64(2), counter(Misses)-misses)
+
+	// the negative TTL expires first
+	*now = now.Add(30 * time.Second)
+	_, _, _ = be.Get(context.Background(), "l7e.io/vanity")
+	_, _, _ = be.Get(context.Background(), "l7e.io/unknown")
+	assert.Equal(t, 3, inner.gets)
+
+	*now = now.Add(time.Minute)
+	_, _, _ = be.Get(context.Background(), "l7e.io/vanity")
+	assert.Equal(t, 4, inner.gets)
+}
+
+func TestCache_errors(t *testing.T) {
+	inner, be, _ := newTestBackend()
+	failure := fmt.Errorf("unavailable")
+	inner.Healthy = failure
+
+	_, _, err := be.Get(context.Background(), "l7e.io/vanity")
+	assert.Equal(t, failure, err)
+
+	inner.Healthy = nil
+	_, _, err = be.Get(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, inner.gets)
+}
+
+func TestCache_invalidation(t *testing.T) {
+	inner, be, _ := newTestBackend()
+
+	_, _, err := be.Get(context.Background(), "l7e.io/yama")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	assert.NoError(t, be.Add(context.Background(), "l7e.io/yama", "git", "https://github.com/livetribe/yama"))
+	_, vcsPath, err := be.Get(context.Background(), "l7e.io/yama")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/livetribe/yama", vcsPath)
+
+	assert.NoError(t, be.Remove(context.Background(), "l7e.io/yama"))
+	_, _, err = be.Get(context.Background(), "l7e.io/yama")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	assert.Equal(t, 3, inner.gets)
+}
+
+func TestCache_prefix(t *testing.T) {
+	inner, be, _ := newTestBackend()
+
+	for i := 0; i < 2; i++ {
+		e, err := vanity.GetPrefix(context.Background(), be, "l7e.io/vanity/cmd/vanity")
+		assert.NoError(t, err)
+		assert.Equal(t, "l7e.io/vanity", e.ImportPath)
+	}
+
+	// l7e.io/vanity/cmd/vanity, l7e.io/vanity/cmd and l7e.io/vanity
+	assert.Equal(t, 3, inner.gets)
+}
+
+func TestCache_eviction(t *testing.T) {
+	_, be, _ := newTestBackend(WithSize(2))
+	evictions := counter(Evictions)
+
+	for _, importPath := range []string{"a.com/a", "a.com/b", "a.com/c"} {
+		_, _, _ = be.Get(context.Background(), importPath)
+	}
+
+	assert.Equal(t, 2, be.entries.len())
+	assert.Equal(t, float64(1), counter(Evictions)-evictions)
+}
+
+func TestCache_stale(t *testing.T) {
+	inner, be, _ := newTestBackend()
+	inner.stale = true
+
+	for i := 0; i < 2; i++ {
+		ctx, stale := vanity.WithStaleReport(context.Background())
+		_, _, err := be.Get(ctx, "l7e.io/vanity")
+		assert.NoError(t, err)
+		assert.True(t, stale())
+	}
+	assert.Equal(t, 2, inner.gets)
+
+	inner.stale = false
+	for i := 0; i < 2; i++ {
+		ctx, stale := vanity.WithStaleReport(context.Background())
+		_, _, err := be.Get(ctx, "l7e.io/vanity")
+		assert.NoError(t, err)
+		assert.False(t, stale())
+	}
+	assert.Equal(t, 3, inner.gets)
+}
+
+func TestCache_forwarding(t *testing.T) {
+	inner, _, _ := newTestBackend()
+
+	be := NewCachingBackend(inner)
+	_, ok := be.(vanity.Watcher)
+	assert.False(t, ok)
+	_, ok = be.(vanity.ReadinessChecker)
+	assert.False(t, ok)
+
+	b := vanity.NewBroadcaster(0)
+	defer b.Close()
+
+	be = NewCachingBackend(&watchingBackend{countingBackend: inner, events: b})
+	assert.Equal(t, vanity.ErrNotReady, be.(vanity.ReadinessChecker).Readyz(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := be.(vanity.Watcher).Watch(ctx, "")
+	assert.NoError(t, err)
+
+	b.Publish(vanity.EventAdded, "l7e.io/yama", vanity.NewEntry("l7e.io/yama", "git", "https://github.com/livetribe/yama"))
+	e := <-events
+	assert.Equal(t, vanity.EventAdded, e.Type)
+	assert.Equal(t, "l7e.io/yama", e.ImportPath)
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+/*
+Package cache provides a Backend decorator with a read-through cache of
+lookups.
+
+The cache is bounded, evicting the least recently used lookups, and caches
+found configurations and import paths that are not found for separate TTLs.
+Changes made through the decorator invalidate the cached lookups of the
+changed import paths.  Lookups the decorated backend reports stale, through
+vanity.MarkStale, are reported stale in turn but not cached.
+
+The decorator implements vanity.Watcher and vanity.ReadinessChecker when the
+decorated backend does, forwarding to it.
+
+
+Creating a Caching Backend
+
+To cache the lookups of a Spanner-based backend:
+
+    be, err := spanner.NewClient(ctx, "projects/p/instances/i/databases/d")
+    if err != nil {
+        return err
+    }
+
+    cached := cache.NewCachingBackend(be,
+        cache.WithSize(1000),
+        cache.WithTTL(5*time.Minute),
+        cache.WithNegativeTTL(30*time.Second))
+    defer cached.Close()
+
+Closing the caching backend closes the decorated backend.
+
+
+Metrics
+
+The hits, of which negative hits, misses and evictions are tracked by the
+Prometheus counters Hits, NegativeHits, Misses and Evictions.
+
+*/
+package cache // import "l7e.io/vanity/pkg/cache"
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"container/list"
+	"time"
+
+	"l7e.io/vanity"
+)
+
+// item is a cached lookup; a nil entry caches vanity.ErrNotFound.
+type item struct {
+	key     string
+	entry   *vanity.Entry
+	expires time.Time
+}
+
+// lru is a bounded, least recently used, map of lookups.  It is not safe for
+// concurrent use.
+type lru struct {
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get returns the unexpired item of key.
+func (c *lru) get(key string, now time.Time) (*item, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	it := el.Value.(*item)
+	if !now.Before(it.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+
+	return it, true
+}
+
+// add stores an item, returning the number of items evicted to make room.
+func (c *lru) add(it *item) (evicted int) {
+	if el, ok := c.items[it.key]; ok {
+		el.Value = it
+		c.order.MoveToFront(el)
+
+		return 0
+	}
+
+	c.items[it.key] = c.order.PushFront(it)
+
+	for c.order.Len() > c.size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.items, el.Value.(*item).key)
+		evicted++
+	}
+
+	return evicted
+}
+
+func (c *lru) remove(key string) {
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+func (c *lru) purge() {
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+func (c *lru) len() int {
+	return c.order.Len()
+}
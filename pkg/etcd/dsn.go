@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcd
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"l7e.io/vanity"
+)
+
+func init() { //nolint:gochecknoinits
+	vanity.RegisterBackend("etcd", openDSN)
+}
+
+// openDSN creates an etcd-based Backend from a data source name such as
+// "etcd://host1:2379,host2:2379/vanity/", whose path is the key prefix.
+func openDSN(_ context.Context, dsn *url.URL) (vanity.Backend, error) {
+	config, opts, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(config, opts...)
+}
+
+// parseDSN obtains the client configuration of a data source name, whose
+// host lists the endpoints, along with the options set by its path.
+func parseDSN(dsn *url.URL) (clientv3.Config, []BackendOption, error) {
+	if dsn.Host == "" {
+		return clientv3.Config{}, nil, vanity.ErrInvalidDSN
+	}
+
+	config := clientv3.Config{
+		Endpoints:   strings.Split(dsn.Host, ","),
+		DialTimeout: DefaultDialTimeout,
+	}
+	if dsn.User != nil {
+		config.Username = dsn.User.Username()
+		config.Password, _ = dsn.User.Password()
+	}
+
+	var opts []BackendOption
+	if dsn.Path != "" {
+		opts = append(opts, WithKeyPrefix(dsn.Path))
+	}
+
+	return config, opts, nil
+}
@@ -0,0 +1,540 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package etcd contains the etcd v3 Backend, storing entries under a key
+// prefix.
+package etcd // import "l7e.io/vanity/pkg/etcd"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"l7e.io/vanity"
+)
+
+const (
+	// DefaultDialTimeout is the default time allowed to connect to an endpoint.
+	DefaultDialTimeout = 5 * time.Second
+
+	// maxAttempts is the number of times a transaction is attempted when its
+	// keys are changed concurrently.
+	maxAttempts = 10
+)
+
+var errUnknownRecordVersion = fmt.Errorf("unknown record version")
+
+// record is a stored entry.
+type record struct {
+	Version        int               `json:"version"`
+	Revision       int64             `json:"revision"`
+	VCS            string            `json:"vcs"`
+	VCSPath        string            `json:"vcs_path"`
+	Description    string            `json:"description,omitempty"`
+	Owner          string            `json:"owner,omitempty"`
+	DefaultBranch  string            `json:"default_branch,omitempty"`
+	DocURL         string            `json:"doc_url,omitempty"`
+	SourceTemplate string            `json:"source_template,omitempty"`
+	Visibility     string            `json:"visibility,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Created        time.Time         `json:"created"`
+	Updated        time.Time         `json:"updated"`
+}
+
+func encodeEntry(e *vanity.Entry) (string, error) {
+	b, err := json.Marshal(&record{
+		Version:        e.Version,
+		Revision:       e.Revision,
+		VCS:            e.VCS,
+		VCSPath:        e.VCSPath,
+		Description:    e.Description,
+		Owner:          e.Owner,
+		DefaultBranch:  e.DefaultBranch,
+		DocURL:         e.DocURL,
+		SourceTemplate: e.SourceTemplate,
+		Visibility:     string(e.Visibility),
+		Labels:         e.Labels,
+		Created:        e.Created,
+		Updated:        e.Updated,
+	})
+
+	return string(b), err
+}
+
+func decodeEntry(importPath string, b []byte) (*vanity.Entry, error) {
+	var r record
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf(unableToExtractEntry, importPath, err)
+	}
+	if r.Version != vanity.EntryVersion {
+		return nil, fmt.Errorf(unableToExtractEntry, importPath, errUnknownRecordVersion)
+	}
+
+	return &vanity.Entry{
+		Version:        r.Version,
+		Revision:       r.Revision,
+		ImportPath:     importPath,
+		VCS:            r.VCS,
+		VCSPath:        r.VCSPath,
+		Description:    r.Description,
+		Owner:          r.Owner,
+		DefaultBranch:  r.DefaultBranch,
+		DocURL:         r.DocURL,
+		SourceTemplate: r.SourceTemplate,
+		Visibility:     vanity.Visibility(r.Visibility),
+		Labels:         r.Labels,
+		Created:        r.Created,
+		Updated:        r.Updated,
+	}, nil
+}
+
+type etcdBE struct {
+	client    *clientv3.Client
+	keyPrefix string
+
+	lock   sync.RWMutex
+	closed bool
+}
+
+// NewClient creates a Backend storing entries in the etcd cluster of the
+// client configuration, as JSON values whose keys are the import paths with a
+// prefix.
+func NewClient(config clientv3.Config, opts ...BackendOption) (vanity.EntryBackend, error) {
+	s := &backendSettings{keyPrefix: DefaultKeyPrefix}
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	client, err := clientv3.New(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &etcdBE{client: client, keyPrefix: s.keyPrefix}, nil
+}
+
+func (s *etcdBE) key(importPath string) string {
+	return s.keyPrefix + importPath
+}
+
+func (s *etcdBE) importPath(kv *mvccpb.KeyValue) string {
+	return string(kv.Key[len(s.keyPrefix):])
+}
+
+// checkClosed read-locks the backend, which is unlocked by the returned
+// function, unless it is closed.
+func (s *etcdBE) checkClosed() (func(), error) {
+	s.lock.RLock()
+	if s.closed {
+		s.lock.RUnlock()
+		return nil, vanity.ErrAlreadyClosed
+	}
+
+	return s.lock.RUnlock, nil
+}
+
+// read fetches the entries of importPaths in a single transaction, along with
+// the revisions their keys were modified at; those not found are missing from
+// the returned maps.
+func (s *etcdBE) read(ctx context.Context, importPaths []string) (map[string]*vanity.Entry, map[string]int64, error) {
+	ops := make([]clientv3.Op, len(importPaths))
+	for i, importPath := range importPaths {
+		ops[i] = clientv3.OpGet(s.key(importPath))
+	}
+
+	resp, err := s.client.Txn(ctx).Then(ops...).Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries := make(map[string]*vanity.Entry, len(importPaths))
+	revisions := make(map[string]int64, len(importPaths))
+	for i, r := range resp.Responses {
+		kvs := r.GetResponseRange().Kvs
+		if len(kvs) == 0 {
+			continue
+		}
+
+		e, err := decodeEntry(importPaths[i], kvs[0].Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries[importPaths[i]] = e
+		revisions[importPaths[i]] = kvs[0].ModRevision
+	}
+
+	return entries, revisions, nil
+}
+
+func (s *etcdBE) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	return s.client.Close()
+}
+
+// Healthz checks the status of the endpoints, which is healthy if any of
+// them is reachable and reports no errors.
+func (s *etcdBE) Healthz(ctx context.Context) error {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, endpoint := range s.client.Endpoints() {
+		var resp *clientv3.StatusResponse
+		resp, err = s.client.Status(ctx, endpoint)
+		if err != nil {
+			continue
+		}
+		if len(resp.Errors) > 0 {
+			err = fmt.Errorf(unhealthyEndpoint, endpoint, resp.Errors[0])
+			continue
+		}
+
+		return nil
+	}
+
+	return err
+}
+
+func (s *etcdBE) Get(ctx context.Context, importPath string) (vcs, vcsPath string, err error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *etcdBE) GetEntry(ctx context.Context, importPath string) (*vanity.Entry, error) {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	resp, err := s.client.Get(ctx, s.key(importPath))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, vanity.ErrNotFound
+	}
+
+	return decodeEntry(importPath, resp.Kvs[0].Value)
+}
+
+// GetPrefix reads all the prefixes of path in a single transaction.
+func (s *etcdBE) GetPrefix(ctx context.Context, path string) (*vanity.Entry, error) {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	prefixes := vanity.Prefixes(path)
+	entries, _, err := s.read(ctx, prefixes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range prefixes {
+		if e, ok := entries[candidate]; ok {
+			return e, nil
+		}
+	}
+
+	return nil, vanity.ErrNotFound
+}
+
+func (s *etcdBE) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return s.InsertEntry(ctx, vanity.NewEntry(importPath, vcs, vcsPath))
+}
+
+func (s *etcdBE) InsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.single(ctx, vanity.InsertMutation(entry))
+}
+
+func (s *etcdBE) UpdateEntry(ctx context.Context, entry *vanity.Entry, ifRevision int64) error {
+	return s.single(ctx, vanity.UpdateMutation(entry, ifRevision))
+}
+
+func (s *etcdBE) UpsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.single(ctx, vanity.UpsertMutation(entry))
+}
+
+func (s *etcdBE) Remove(ctx context.Context, importPath string) error {
+	return s.single(ctx, vanity.RemoveMutation(importPath))
+}
+
+// single applies a single mutation, returning the reason it failed.
+func (s *etcdBE) single(ctx context.Context, m vanity.Mutation) error {
+	err := s.Apply(ctx, []vanity.Mutation{m})
+	if me, ok := err.(*vanity.MutationError); ok {
+		return me.Err
+	}
+
+	return err
+}
+
+// Apply applies the mutations in a transaction that only succeeds if the keys
+// of the mutations were not modified since they were read, which is retried
+// otherwise; ErrConflict is returned if they keep changing.
+func (s *etcdBE) Apply(ctx context.Context, mutations []vanity.Mutation) error {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var importPaths []string
+	seen := make(map[string]bool)
+	for _, m := range mutations {
+		if m.Entry == nil || seen[m.Entry.ImportPath] {
+			continue
+		}
+		seen[m.Entry.ImportPath] = true
+		importPaths = append(importPaths, m.Entry.ImportPath)
+	}
+
+	for i := 0; i < maxAttempts; i++ {
+		applied, err := s.apply(ctx, importPaths, mutations)
+		if err != nil || applied {
+			return err
+		}
+	}
+
+	return vanity.ErrConflict
+}
+
+func (s *etcdBE) apply(ctx context.Context, importPaths []string, mutations []vanity.Mutation) (bool, error) {
+	entries, revisions, err := s.read(ctx, importPaths)
+	if err != nil {
+		return false, err
+	}
+
+	if err := vanity.ApplyMutations(entries, mutations, time.Now().UTC()); err != nil {
+		return false, err
+	}
+
+	var cmps []clientv3.Cmp
+	var ops []clientv3.Op
+	for _, importPath := range importPaths {
+		key := s.key(importPath)
+
+		// a zero revision compares as not existing
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", revisions[importPath]))
+
+		e := entries[importPath]
+		if e == nil {
+			if revisions[importPath] != 0 {
+				ops = append(ops, clientv3.OpDelete(key))
+			}
+			continue
+		}
+
+		v, err := encodeEntry(e)
+		if err != nil {
+			return false, err
+		}
+		ops = append(ops, clientv3.OpPut(key, v))
+	}
+
+	resp, err := s.client.Txn(ctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return resp.Succeeded, nil
+}
+
+func (s *etcdBE) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+// ListEntries lists the entries in import path order, using a single range
+// read of the keys with the prefix.
+func (s *etcdBE) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	_, err := s.ListPage(ctx, vanity.ListOptions{}, consumer)
+
+	return err
+}
+
+// ListPage lists a page of entries using a range read, starting at the prefix
+// or the page token.
+func (s *etcdBE) ListPage(ctx context.Context, opts vanity.ListOptions, consumer vanity.EntryConsumer) (string, error) {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	after, err := vanity.DecodePageToken(opts.PageToken)
+	if err != nil {
+		return "", err
+	}
+
+	start := s.key(opts.Prefix)
+	end := clientv3.GetPrefixRangeEnd(start)
+	order := clientv3.SortAscend
+	if opts.Order == vanity.Descending {
+		order = clientv3.SortDescend
+		if after != "" && s.key(after) < end {
+			end = s.key(after)
+		}
+	} else if after != "" && s.key(after) >= start {
+		start = s.key(after) + "\x00"
+	}
+
+	options := []clientv3.OpOption{clientv3.WithRange(end), clientv3.WithSort(clientv3.SortByKey, order)}
+	if opts.PageSize > 0 {
+		// the extra entry shows there is another page
+		options = append(options, clientv3.WithLimit(int64(opts.PageSize)+1))
+	}
+
+	resp, err := s.client.Get(ctx, start, options...)
+	if err != nil {
+		return "", err
+	}
+
+	kvs := resp.Kvs
+	token := ""
+	if opts.PageSize > 0 && len(kvs) > opts.PageSize {
+		kvs = kvs[:opts.PageSize]
+		token = vanity.EncodePageToken(s.importPath(kvs[len(kvs)-1]))
+	}
+
+	page := make([]*vanity.Entry, len(kvs))
+	for i, kv := range kvs {
+		if page[i], err = decodeEntry(s.importPath(kv), kv.Value); err != nil {
+			return "", err
+		}
+	}
+
+	for _, e := range page {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		consumer.Consume(ctx, e)
+	}
+
+	return token, nil
+}
+
+// Watch uses etcd's watch of the keys with the prefix.  Positions are the
+// etcd revisions of the changes, which are shared by the events of a single
+// transaction; ErrInvalidPosition is returned if the revision of position was
+// compacted.
+func (s *etcdBE) Watch(ctx context.Context, position string) (<-chan vanity.Event, error) {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	options := []clientv3.OpOption{clientv3.WithPrefix()}
+	if position != "" {
+		rev, err := strconv.ParseInt(position, 10, 64)
+		if err != nil || rev <= 0 {
+			return nil, vanity.ErrInvalidPosition
+		}
+
+		// the watch fails if the revision following position was compacted
+		_, err = s.client.Get(ctx, s.keyPrefix, clientv3.WithRev(rev), clientv3.WithCountOnly())
+		switch err {
+		case nil:
+		case rpctypes.ErrCompacted, rpctypes.ErrFutureRev:
+			return nil, vanity.ErrInvalidPosition
+		default:
+			return nil, err
+		}
+
+		options = append(options, clientv3.WithRev(rev+1))
+	}
+
+	wc := s.client.Watch(clientv3.WithRequireLeader(ctx), s.keyPrefix, options...)
+
+	c := make(chan vanity.Event)
+	go func() {
+		defer close(c)
+
+		for resp := range wc {
+			if resp.Err() != nil {
+				return
+			}
+
+			for _, ev := range resp.Events {
+				if !s.send(ctx, c, ev) {
+					return
+				}
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+// send delivers the event of a watched change, unless ctx is done first.
+func (s *etcdBE) send(ctx context.Context, c chan<- vanity.Event, ev *clientv3.Event) bool {
+	event := vanity.Event{
+		ImportPath: s.importPath(ev.Kv),
+		Position:   strconv.FormatInt(ev.Kv.ModRevision, 10),
+	}
+
+	switch {
+	case ev.Type == mvccpb.DELETE:
+		event.Type = vanity.EventRemoved
+	case ev.IsCreate():
+		event.Type = vanity.EventAdded
+	default:
+		event.Type = vanity.EventUpdated
+	}
+
+	if event.Type != vanity.EventRemoved {
+		e, err := decodeEntry(event.ImportPath, ev.Kv.Value)
+		if err != nil {
+			// not an entry, so not a change of the configurations
+			return true
+		}
+		event.Entry = e
+	}
+
+	select {
+	case c <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package etcd_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/pkg/etcd"
+)
+
+// startEtcd starts an embedded etcd server, returning its client endpoint.
+func startEtcd(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "etcd")
+	assert.NoError(t, err)
+
+	cfg := embed.NewConfig()
+	cfg.Dir = dir
+	cfg.LogLevel = "error"
+	cfg.LCUrls = []url.URL{{Scheme: "http", Host: "127.0.0.1:0"}}
+	cfg.LPUrls = []url.URL{{Scheme: "http", Host: "127.0.0.1:0"}}
+
+	e, err := embed.StartEtcd(cfg)
+	if !assert.NoError(t, err) {
+		_ = os.RemoveAll(dir)
+		t.FailNow()
+	}
+
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		e.Close()
+		_ = os.RemoveAll(dir)
+		t.Fatal("etcd not ready in time")
+	}
+
+	return e.Clients[0].Addr().String(), func() {
+		e.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func newEtcd(t *testing.T, opts ...etcd.BackendOption) (vanity.EntryBackend, string, func()) {
+	endpoint, stop := startEtcd(t)
+
+	be, err := etcd.NewClient(clientv3.Config{Endpoints: []string{endpoint}, DialTimeout: 5 * time.Second}, opts...)
+	if !assert.NoError(t, err) {
+		stop()
+		t.FailNow()
+	}
+
+	return be, endpoint, func() {
+		_ = be.Close()
+		stop()
+	}
+}
+
+func factory(t *testing.T) (vanity.EntryBackend, func()) {
+	be, _, done := newEtcd(t, etcd.WithKeyPrefix("/urls/"))
+	return be, done
+}
+
+func TestEtcd(t *testing.T) {
+	apitest.TestEntryBackend(t, factory)
+}
+
+func TestEtcd_Watch(t *testing.T) {
+	apitest.TestWatcher(t, factory)
+}
+
+func TestEtcd_resume(t *testing.T) {
+	be, _, done := newEtcd(t)
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := be.(vanity.Watcher)
+	events, err := w.Watch(ctx, "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, be.Add(ctx, "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+	assert.NoError(t, be.UpsertEntry(ctx, vanity.NewEntry("l7e.io/vanity", "git", "https://gitlab.com/livetribe/vanity")))
+
+	added := <-events
+	assert.Equal(t, vanity.EventAdded, added.Type)
+
+	// resume after the addition
+	resumed, err := w.Watch(ctx, added.Position)
+	assert.NoError(t, err)
+	e := <-resumed
+	assert.Equal(t, vanity.EventUpdated, e.Type)
+	assert.Equal(t, "https://gitlab.com/livetribe/vanity", e.Entry.VCSPath)
+
+	_, err = w.Watch(ctx, "1000000")
+	assert.Equal(t, vanity.ErrInvalidPosition, err)
+}
+
+func TestEtcd_Open(t *testing.T) {
+	endpoint, stop := startEtcd(t)
+	defer stop()
+
+	be, err := vanity.Open(context.Background(), "etcd://"+endpoint+"/urls/")
+	assert.NoError(t, err)
+	defer func() { _ = be.Close() }()
+
+	assert.NoError(t, be.Add(context.Background(), "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+
+	other, err := etcd.NewClient(clientv3.Config{Endpoints: []string{endpoint}}, etcd.WithKeyPrefix("/urls/"))
+	assert.NoError(t, err)
+	defer func() { _ = other.Close() }()
+
+	_, _, err = other.Get(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+
+	_, err = vanity.Open(context.Background(), "etcd:///urls/")
+	assert.Equal(t, vanity.ErrInvalidDSN, err)
+}
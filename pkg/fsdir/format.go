@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fsdir
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+
+	"l7e.io/vanity"
+)
+
+// record is the content of an entry file; the import path is that of the
+// file, and may be omitted.
+type record struct {
+	Version        int       `toml:"version,omitempty" yaml:"version,omitempty" json:"version,omitempty"`
+	Revision       int64     `toml:"revision,omitempty" yaml:"revision,omitempty" json:"revision,omitempty"`
+	ImportPath     string    `toml:"import_path,omitempty" yaml:"import_path,omitempty" json:"import_path,omitempty"`
+	VCS            string    `toml:"vcs" yaml:"vcs" json:"vcs"`
+	VCSPath        string    `toml:"vcs_path" yaml:"vcs_path" json:"vcs_path"`
+	Description    string    `toml:"description,omitempty" yaml:"description,omitempty" json:"description,omitempty"`
+	Owner          string    `toml:"owner,omitempty" yaml:"owner,omitempty" json:"owner,omitempty"`
+	DefaultBranch  string    `toml:"default_branch,omitempty" yaml:"default_branch,omitempty" json:"default_branch,omitempty"`
+	DocURL         string    `toml:"doc_url,omitempty" yaml:"doc_url,omitempty" json:"doc_url,omitempty"`
+	SourceTemplate string    `toml:"source_template,omitempty" yaml:"source_template,omitempty" json:"source_template,omitempty"`
+	Visibility     string    `toml:"visibility,omitempty" yaml:"visibility,omitempty" json:"visibility,omitempty"`
+	Created        time.Time `toml:"created,omitempty" yaml:"created,omitempty" json:"created"`
+	Updated        time.Time `toml:"updated,omitempty" yaml:"updated,omitempty" json:"updated"`
+
+	// Labels are last, as TOML tables follow the keys of their parent table
+	Labels map[string]string `toml:"labels,omitempty" yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+func newRecord(e *vanity.Entry) *record {
+	return &record{
+		Version:        e.Version,
+		Revision:       e.Revision,
+		VCS:            e.VCS,
+		VCSPath:        e.VCSPath,
+		Description:    e.Description,
+		Owner:          e.Owner,
+		DefaultBranch:  e.DefaultBranch,
+		DocURL:         e.DocURL,
+		SourceTemplate: e.SourceTemplate,
+		Visibility:     string(e.Visibility),
+		Labels:         e.Labels,
+		Created:        e.Created,
+		Updated:        e.Updated,
+	}
+}
+
+func (r *record) toEntry(importPath string) *vanity.Entry {
+	return &vanity.Entry{
+		Version:        r.Version,
+		Revision:       r.Revision,
+		ImportPath:     importPath,
+		VCS:            r.VCS,
+		VCSPath:        r.VCSPath,
+		Description:    r.Description,
+		Owner:          r.Owner,
+		DefaultBranch:  r.DefaultBranch,
+		DocURL:         r.DocURL,
+		SourceTemplate: r.SourceTemplate,
+		Visibility:     vanity.Visibility(r.Visibility),
+		Labels:         r.Labels,
+		Created:        r.Created,
+		Updated:        r.Updated,
+	}
+}
+
+// codec encodes and decodes the records of a file format.
+type codec struct {
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(b []byte, v interface{}) error
+}
+
+// Formats of entry files.
+const (
+	FormatTOML = "toml"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+var (
+	codecs = map[string]codec{
+		FormatTOML: {marshalTOML, toml.Unmarshal},
+		FormatYAML: {yaml.Marshal, yaml.Unmarshal},
+		FormatJSON: {marshalJSON, json.Unmarshal},
+	}
+
+	// formats are the formats of the file extensions.
+	formats = map[string]string{
+		".toml": FormatTOML,
+		".yaml": FormatYAML,
+		".yml":  FormatYAML,
+		".json": FormatJSON,
+	}
+)
+
+// marshalTOML keeps the keys in record order.
+func marshalTOML(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	err := toml.NewEncoder(&b).Order(toml.OrderPreserve).Encode(v)
+
+	return b.Bytes(), err
+}
+
+func marshalJSON(v interface{}) ([]byte, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
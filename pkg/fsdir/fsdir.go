@@ -0,0 +1,590 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fsdir contains the directory-based Backend, where every entry is a
+// TOML, YAML or JSON file in a directory tree mirroring its import path, so
+// each entry can be owned separately in a configuration repository.  The
+// directory is watched, and its changes applied without a restart.
+package fsdir // import "l7e.io/vanity/pkg/fsdir"
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"l7e.io/vanity"
+)
+
+var (
+	// InvalidFiles is a Prometheus gauge that tracks the number of invalid
+	// files found by the latest reload of a directory.
+	InvalidFiles = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "vanity",
+		Subsystem: "fsdir",
+		Name:      "invalid_files",
+		Help:      "The number of invalid entry files",
+	})
+
+	// Reloads is a Prometheus counter that tracks the total reloads of
+	// directories.
+	Reloads = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "fsdir",
+		Name:      "reloads_total",
+		Help:      "The total directory reloads",
+	})
+
+	errUnknownFormat        = fmt.Errorf("unknown format")
+	errUnknownRecordVersion = fmt.Errorf("unknown record version")
+	errImportPathMismatch   = fmt.Errorf("import_path does not match the file path")
+	errVcsNotSpecified      = fmt.Errorf("vcs not specified")
+	errVcsPathNotSpecified  = fmt.Errorf("vcs_path not specified")
+	errInvalidVisibility    = fmt.Errorf("invalid visibility")
+	errDuplicateImportPath  = fmt.Errorf("import path read from several files")
+	errInvalidImportPath    = fmt.Errorf("import path cannot be stored in a file")
+)
+
+// loaded is an entry along with the file it was read from.
+type loaded struct {
+	file  string
+	entry *vanity.Entry
+}
+
+type fsdirBE struct {
+	root    string
+	format  string
+	delay   time.Duration
+	onError func(path string, err error)
+	events  *vanity.Broadcaster
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+
+	// reloadLock serializes reloads and changes
+	reloadLock sync.Mutex
+
+	lock sync.RWMutex
+	// entries are replaced, never modified, by reloads
+	entries map[string]*loaded
+	closed  bool
+}
+
+// NewBackend creates a Backend reading the entries of the files under root,
+// whose paths relative to root, without extension, are their import paths,
+// e.g. "l7e.io/vanity.toml".  Files are read according to their extension,
+// one of .toml, .yaml, .yml or .json; other files, and those whose name
+// starts with a dot, are ignored.
+//
+// The directory is watched, and reloaded whenever it changes; the entries
+// are replaced at once, and watchers notified of the changes.  Invalid files
+// are reported and skipped, keeping the entries last read from them.
+//
+// Changes write or delete the files of the entries, which are replaced
+// atomically; changes to several entries are not atomic.
+func NewBackend(root string, opts ...BackendOption) (vanity.EntryBackend, error) {
+	s := &backendSettings{format: DefaultFormat, delay: DefaultDelay}
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	if _, ok := codecs[s.format]; !ok {
+		return nil, errUnknownFormat
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	be := &fsdirBE{
+		root:    filepath.Clean(root),
+		format:  s.format,
+		delay:   s.delay,
+		onError: s.onError,
+		events:  vanity.NewBroadcaster(vanity.DefaultRetainedEvents),
+		watcher: watcher,
+		done:    make(chan struct{}),
+	}
+
+	if be.entries, err = be.scan(nil); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	go be.run()
+
+	return be, nil
+}
+
+func (s *fsdirBE) report(path string, err error) {
+	if s.onError != nil {
+		s.onError(path, err)
+	}
+}
+
+// importPath returns the import path of a file, which is relative to root.
+func (s *fsdirBE) importPath(file string) string {
+	rel, _ := filepath.Rel(s.root, file)
+
+	return filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))
+}
+
+// file returns the path of the file of an import path in the default format.
+func (s *fsdirBE) file(importPath string) (string, error) {
+	if importPath == "" || path.IsAbs(importPath) || path.Clean(importPath) != importPath {
+		return "", errInvalidImportPath
+	}
+	for _, element := range strings.Split(importPath, "/") {
+		if strings.HasPrefix(element, ".") {
+			return "", errInvalidImportPath
+		}
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(importPath)) + "." + s.format, nil
+}
+
+// read reads the entry of a file, whose times default to its modification
+// time.
+func (s *fsdirBE) read(file string, info os.FileInfo, c codec) (*vanity.Entry, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var r record
+	if err = c.unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf(unableToReadEntry, file, err)
+	}
+
+	importPath := s.importPath(file)
+	switch {
+	case r.ImportPath != "" && r.ImportPath != importPath:
+		return nil, errImportPathMismatch
+	case r.Version != 0 && r.Version != vanity.EntryVersion:
+		return nil, errUnknownRecordVersion
+	case r.VCS == "":
+		return nil, errVcsNotSpecified
+	case r.VCSPath == "":
+		return nil, errVcsPathNotSpecified
+	case !vanity.Visibility(r.Visibility).IsValid():
+		return nil, errInvalidVisibility
+	}
+
+	e := r.toEntry(importPath)
+	e.Version = vanity.EntryVersion
+	if e.Created.IsZero() {
+		e.Created = info.ModTime().UTC()
+	}
+	if e.Updated.IsZero() {
+		e.Updated = e.Created
+	}
+
+	return e, nil
+}
+
+// scan reads the entries of the files under root, watching its directories.
+// Invalid files are reported, and keep the entries they were known with.
+func (s *fsdirBE) scan(known map[string]*loaded) (map[string]*loaded, error) {
+	byFile := make(map[string]*loaded, len(known))
+	for _, l := range known {
+		byFile[l.file] = l
+	}
+
+	entries := make(map[string]*loaded, len(known))
+	invalid := 0
+
+	err := filepath.Walk(s.root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if file == s.root {
+				return err
+			}
+			s.report(file, err)
+			return nil
+		}
+
+		if file != s.root && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			if err := s.watcher.Add(file); err != nil {
+				s.report(file, err)
+			}
+			return nil
+		}
+
+		format, ok := formats[filepath.Ext(file)]
+		if !ok {
+			return nil
+		}
+
+		e, err := s.read(file, info, codecs[format])
+		if err == nil {
+			if _, dup := entries[e.ImportPath]; dup {
+				err = errDuplicateImportPath
+			}
+		}
+		if err != nil {
+			invalid++
+			s.report(file, err)
+			if l, ok := byFile[file]; ok {
+				if _, dup := entries[l.entry.ImportPath]; !dup {
+					entries[l.entry.ImportPath] = l
+				}
+			}
+			return nil
+		}
+
+		entries[e.ImportPath] = &loaded{file: file, entry: e}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	InvalidFiles.Set(float64(invalid))
+	Reloads.Inc()
+
+	return entries, nil
+}
+
+// reload rescans the directory, replacing the entries and publishing their
+// changes; reloadLock must be held.
+func (s *fsdirBE) reload() {
+	s.lock.RLock()
+	known := s.entries
+	s.lock.RUnlock()
+
+	current, err := s.scan(known)
+	if err != nil {
+		s.report(s.root, err)
+		return
+	}
+
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return
+	}
+	s.entries = current
+	s.lock.Unlock()
+
+	importPaths := make([]string, 0, len(current)+len(known))
+	for importPath := range current {
+		importPaths = append(importPaths, importPath)
+	}
+	for importPath := range known {
+		if _, found := current[importPath]; !found {
+			importPaths = append(importPaths, importPath)
+		}
+	}
+	sort.Strings(importPaths)
+
+	for _, importPath := range importPaths {
+		old, wasKnown := known[importPath]
+		l, found := current[importPath]
+		switch {
+		case !found:
+			s.events.Publish(vanity.EventRemoved, importPath, nil)
+		case !wasKnown:
+			s.events.Publish(vanity.EventAdded, importPath, l.entry)
+		case !reflect.DeepEqual(old.entry, l.entry):
+			s.events.Publish(vanity.EventUpdated, importPath, l.entry)
+		}
+	}
+}
+
+// run reloads the directory once it stops changing for the delay.
+func (s *fsdirBE) run() {
+	defer close(s.done)
+
+	timer := time.NewTimer(s.delay)
+	if !timer.Stop() {
+		<-timer.C
+	}
+
+	for {
+		select {
+		case ev, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			if ev.Op == fsnotify.Chmod || strings.HasPrefix(filepath.Base(ev.Name), ".") {
+				continue
+			}
+			timer.Reset(s.delay)
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			s.report(s.root, err)
+		case <-timer.C:
+			s.reloadLock.Lock()
+			s.reload()
+			s.reloadLock.Unlock()
+		}
+	}
+}
+
+func (s *fsdirBE) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.entries = nil
+	s.lock.Unlock()
+
+	err := s.watcher.Close()
+	<-s.done
+	s.events.Close()
+
+	return err
+}
+
+// snapshot returns the current entries.
+func (s *fsdirBE) snapshot() (map[string]*loaded, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return nil, vanity.ErrAlreadyClosed
+	}
+
+	return s.entries, nil
+}
+
+// Healthz checks that the directory still exists.
+func (s *fsdirBE) Healthz(context.Context) error {
+	if _, err := s.snapshot(); err != nil {
+		return err
+	}
+
+	_, err := os.Stat(s.root)
+
+	return err
+}
+
+func (s *fsdirBE) Get(ctx context.Context, importPath string) (string, string, error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *fsdirBE) GetEntry(_ context.Context, importPath string) (*vanity.Entry, error) {
+	entries, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	l, found := entries[importPath]
+	if !found {
+		return nil, vanity.ErrNotFound
+	}
+	return l.entry.Clone(), nil
+}
+
+func (s *fsdirBE) GetPrefix(_ context.Context, path string) (*vanity.Entry, error) {
+	entries, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, importPath := range vanity.Prefixes(path) {
+		if l, found := entries[importPath]; found {
+			return l.entry.Clone(), nil
+		}
+	}
+	return nil, vanity.ErrNotFound
+}
+
+func (s *fsdirBE) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return s.InsertEntry(ctx, vanity.NewEntry(importPath, vcs, vcsPath))
+}
+
+func (s *fsdirBE) InsertEntry(_ context.Context, entry *vanity.Entry) error {
+	return s.mutate(vanity.InsertMutation(entry))
+}
+
+func (s *fsdirBE) UpdateEntry(_ context.Context, entry *vanity.Entry, ifRevision int64) error {
+	return s.mutate(vanity.UpdateMutation(entry, ifRevision))
+}
+
+func (s *fsdirBE) UpsertEntry(_ context.Context, entry *vanity.Entry) error {
+	return s.mutate(vanity.UpsertMutation(entry))
+}
+
+func (s *fsdirBE) Remove(_ context.Context, importPath string) error {
+	return s.mutate(vanity.RemoveMutation(importPath))
+}
+
+// mutate applies a mutation to the file of its import path, then reloads
+// the directory so the change is read back.
+func (s *fsdirBE) mutate(m vanity.Mutation) error {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+
+	known, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+
+	importPath := m.Entry.ImportPath
+	file, err := s.file(importPath)
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string]*vanity.Entry, 1)
+	current, found := known[importPath]
+	if found {
+		file = current.file
+		entries[importPath] = current.entry.Clone()
+	}
+
+	if err = vanity.ApplyMutations(entries, []vanity.Mutation{m}, time.Now().UTC()); err != nil {
+		if me, ok := err.(*vanity.MutationError); ok {
+			return me.Err
+		}
+		return err
+	}
+
+	if e := entries[importPath]; e != nil {
+		err = s.write(file, e)
+	} else {
+		err = s.remove(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.reload()
+
+	return nil
+}
+
+// write replaces the file of an entry by renaming a temporary file, which is
+// ignored by the watch as its name starts with a dot.
+func (s *fsdirBE) write(file string, e *vanity.Entry) error {
+	b, err := codecs[formats[filepath.Ext(file)]].marshal(newRecord(e))
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(file)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(file)+".")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	_, err = tmp.Write(b)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
+
+// remove deletes the file of an entry, along with the directories left empty.
+func (s *fsdirBE) remove(file string) error {
+	if err := os.Remove(file); err != nil {
+		return err
+	}
+
+	for dir := filepath.Dir(file); dir != s.root && strings.HasPrefix(dir, s.root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *fsdirBE) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+func (s *fsdirBE) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	_, err := s.ListPage(ctx, vanity.ListOptions{}, consumer)
+
+	return err
+}
+
+func (s *fsdirBE) ListPage(ctx context.Context, opts vanity.ListOptions, consumer vanity.EntryConsumer) (string, error) {
+	entries, err := s.snapshot()
+	if err != nil {
+		return "", err
+	}
+
+	c := make([]*vanity.Entry, 0, len(entries))
+	for _, l := range entries {
+		c = append(c, l.entry)
+	}
+
+	page, next, err := vanity.PageEntries(c, opts)
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range page {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		consumer.Consume(ctx, e.Clone())
+	}
+
+	return next, nil
+}
+
+// Watch delivers the changes of the reloads; positions are the sequence
+// numbers of the changes.
+func (s *fsdirBE) Watch(ctx context.Context, position string) (<-chan vanity.Event, error) {
+	return s.events.Watch(ctx, position)
+}
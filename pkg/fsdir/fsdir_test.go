@@ -0,0 +1,278 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fsdir_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/pkg/fsdir"
+)
+
+// errors collects the reported invalid files.
+type errors struct {
+	lock  sync.Mutex
+	files map[string]error
+}
+
+func (e *errors) report(path string, err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.files[filepath.Base(path)] = err
+}
+
+func (e *errors) get(name string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.files[name]
+}
+
+func writeFile(t *testing.T, root, name, content string) {
+	file := filepath.Join(root, filepath.FromSlash(name))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	assert.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+}
+
+func newDirectory(t *testing.T) (string, func()) {
+	root, err := ioutil.TempDir("", "fsdir")
+	assert.NoError(t, err)
+
+	writeFile(t, root, "l7e.io/vanity.toml", `
+vcs = "git"
+vcs_path = "https://github.com/livetribe/vanity"
+description = "Vanity URLs"
+created = 2020-07-01T12:00:00Z
+
+[labels]
+team = "platform"
+`)
+	writeFile(t, root, "l7e.io/yama.yaml", `
+vcs: git
+vcs_path: https://github.com/livetribe/yama
+visibility: internal
+`)
+	writeFile(t, root, "m4o.io/pbf.json", `{"vcs": "git", "vcs_path": "https://github.com/magurl/pbf"}`)
+	writeFile(t, root, "m4o.io/invalid.toml", `vcs = "git"`)
+	writeFile(t, root, "m4o.io/README.md", `# Entries`)
+	writeFile(t, root, ".git/config.toml", `not = toml = at all`)
+
+	return root, func() { _ = os.RemoveAll(root) }
+}
+
+func newFSDir(t *testing.T, root string, opts ...fsdir.BackendOption) (vanity.EntryBackend, *errors) {
+	errs := &errors{files: make(map[string]error)}
+	opts = append(opts, fsdir.WithDelay(10*time.Millisecond), fsdir.WithErrorHandler(errs.report))
+
+	be, err := fsdir.NewBackend(root, opts...)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return be, errs
+}
+
+func factory(t *testing.T) (vanity.EntryBackend, func()) {
+	root, err := ioutil.TempDir("", "fsdir")
+	assert.NoError(t, err)
+
+	be, _ := newFSDir(t, root)
+	return be, func() {
+		_ = be.Close()
+		_ = os.RemoveAll(root)
+	}
+}
+
+func TestFSDir(t *testing.T) {
+	apitest.TestEntryBackend(t, factory)
+}
+
+func TestFSDir_Watch(t *testing.T) {
+	apitest.TestWatcher(t, factory)
+}
+
+func TestFSDir_files(t *testing.T) {
+	root, done := newDirectory(t)
+	defer done()
+
+	be, errs := newFSDir(t, root)
+	defer func() { _ = be.Close() }()
+
+	ctx := context.Background()
+
+	e, err := be.GetEntry(ctx, "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/livetribe/vanity", e.VCSPath)
+	assert.Equal(t, "Vanity URLs", e.Description)
+	assert.Equal(t, map[string]string{"team": "platform"}, e.Labels)
+	assert.Equal(t, time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC), e.Created)
+	assert.Equal(t, e.Created, e.Updated)
+
+	e, err = be.GetEntry(ctx, "l7e.io/yama")
+	assert.NoError(t, err)
+	assert.Equal(t, vanity.VisibilityInternal, e.Visibility)
+	assert.False(t, e.Created.IsZero())
+
+	_, vcsPath, err := be.Get(ctx, "m4o.io/pbf")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/magurl/pbf", vcsPath)
+
+	e, err = vanity.GetPrefix(ctx, be, "l7e.io/vanity/cmd/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, "l7e.io/vanity", e.ImportPath)
+
+	// invalid files are reported and skipped
+	_, _, err = be.Get(ctx, "m4o.io/invalid")
+	assert.Equal(t, vanity.ErrNotFound, err)
+	assert.Error(t, errs.get("invalid.toml"))
+	assert.NoError(t, errs.get("config.toml"))
+
+	var paths []string
+	err = be.List(ctx, vanity.ConsumerFunc(func(_ context.Context, importPath, _, _ string) {
+		paths = append(paths, importPath)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"l7e.io/vanity", "l7e.io/yama", "m4o.io/pbf"}, paths)
+}
+
+func TestFSDir_changes(t *testing.T) {
+	root, done := newDirectory(t)
+	defer done()
+
+	be, _ := newFSDir(t, root, fsdir.WithFormat(fsdir.FormatYAML))
+	defer func() { _ = be.Close() }()
+
+	ctx := context.Background()
+
+	assert.NoError(t, be.Add(ctx, "example.com/tools/lint", "git", "https://github.com/example/lint"))
+	assert.FileExists(t, filepath.Join(root, "example.com", "tools", "lint.yaml"))
+
+	// changed entries keep the format of their file
+	e, err := be.GetEntry(ctx, "l7e.io/vanity")
+	assert.NoError(t, err)
+	e.VCSPath = "https://gitlab.com/livetribe/vanity"
+	assert.NoError(t, be.UpdateEntry(ctx, e, vanity.AnyRevision))
+	assert.Equal(t, vanity.ErrConflict, be.UpdateEntry(ctx, e, 5))
+
+	b, err := ioutil.ReadFile(filepath.Join(root, "l7e.io", "vanity.toml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `vcs_path = "https://gitlab.com/livetribe/vanity"`)
+
+	updated, err := be.GetEntry(ctx, "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), updated.Revision)
+	assert.Equal(t, e.Created, updated.Created)
+	assert.Equal(t, map[string]string{"team": "platform"}, updated.Labels)
+
+	// empty directories are removed with the last entry
+	assert.NoError(t, be.Remove(ctx, "example.com/tools/lint"))
+	_, err = os.Stat(filepath.Join(root, "example.com"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Error(t, be.Add(ctx, "../escape", "git", "https://github.com/example/escape"))
+	assert.Error(t, be.Add(ctx, "example.com/.hidden", "git", "https://github.com/example/hidden"))
+}
+
+func TestFSDir_watchFiles(t *testing.T) {
+	root, done := newDirectory(t)
+	defer done()
+
+	be, errs := newFSDir(t, root)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := be.(vanity.Watcher).Watch(ctx, "")
+	assert.NoError(t, err)
+
+	next := func() vanity.Event {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+			return vanity.Event{}
+		}
+	}
+
+	writeFile(t, root, "example.com/tool.json", `{"vcs": "git", "vcs_path": "https://github.com/example/tool"}`)
+	e := next()
+	assert.Equal(t, vanity.EventAdded, e.Type)
+	assert.Equal(t, "example.com/tool", e.ImportPath)
+
+	_, _, err = be.Get(ctx, "example.com/tool")
+	assert.NoError(t, err)
+
+	writeFile(t, root, "m4o.io/invalid.toml", `
+vcs = "git"
+vcs_path = "https://github.com/magurl/invalid"
+`)
+	e = next()
+	assert.Equal(t, vanity.EventAdded, e.Type)
+	assert.Equal(t, "m4o.io/invalid", e.ImportPath)
+
+	// invalid changes keep the entries last read
+	writeFile(t, root, "example.com/tool.json", `{"vcs": "git"`)
+	assert.Eventually(t, func() bool {
+		return errs.get("tool.json") != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, vcsPath, err := be.Get(ctx, "example.com/tool")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/example/tool", vcsPath)
+
+	assert.NoError(t, os.Remove(filepath.Join(root, "l7e.io", "yama.yaml")))
+	e = next()
+	assert.Equal(t, vanity.EventRemoved, e.Type)
+	assert.Equal(t, "l7e.io/yama", e.ImportPath)
+
+	assert.NoError(t, be.Close())
+
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+func TestFSDir_Open(t *testing.T) {
+	root, done := newDirectory(t)
+	defer done()
+
+	be, err := vanity.Open(context.Background(), "fsdir://"+filepath.ToSlash(root)+"?format=json")
+	assert.NoError(t, err)
+	defer func() { _ = be.Close() }()
+
+	assert.NoError(t, be.Add(context.Background(), "example.com/tool", "git", "https://github.com/example/tool"))
+	assert.FileExists(t, filepath.Join(root, "example.com", "tool.json"))
+
+	_, err = vanity.Open(context.Background(), "fsdir://"+filepath.ToSlash(root)+"?format=ini")
+	assert.Error(t, err)
+
+	_, err = vanity.Open(context.Background(), "fsdir://")
+	assert.Equal(t, vanity.ErrInvalidDSN, err)
+
+	_, err = vanity.Open(context.Background(), "fsdir:///does/not/exist")
+	assert.Error(t, err)
+}
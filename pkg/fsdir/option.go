@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fsdir
+
+import "time"
+
+const (
+	// DefaultFormat is the default format of the files of added entries.
+	DefaultFormat = FormatTOML
+
+	// DefaultDelay is the default time changes to the directory are collected
+	// before they are applied.
+	DefaultDelay = 100 * time.Millisecond
+)
+
+type backendSettings struct {
+	format  string
+	delay   time.Duration
+	onError func(path string, err error)
+}
+
+// A BackendOption is an option for a directory-based backend.
+type BackendOption interface {
+	Apply(*backendSettings)
+}
+
+// WithFormat configures the format of the files of added entries, one of
+// FormatTOML, FormatYAML or FormatJSON; default is DefaultFormat.  Changed
+// entries keep the format of their file.
+func WithFormat(format string) BackendOption {
+	return withFormat{format}
+}
+
+type withFormat struct{ format string }
+
+func (w withFormat) Apply(o *backendSettings) {
+	o.format = w.format
+}
+
+// WithDelay configures the time changes to the directory are collected
+// before they are applied, so files being written are read once complete;
+// default is DefaultDelay.
+func WithDelay(d time.Duration) BackendOption {
+	return withDelay{d}
+}
+
+type withDelay struct{ d time.Duration }
+
+func (w withDelay) Apply(o *backendSettings) {
+	o.delay = w.d
+}
+
+// WithErrorHandler configures the function reporting invalid files, and
+// errors watching the directory, which are otherwise only counted by the
+// InvalidFiles gauge.
+func WithErrorHandler(h func(path string, err error)) BackendOption {
+	return withErrorHandler{h}
+}
+
+type withErrorHandler struct{ h func(path string, err error) }
+
+func (w withErrorHandler) Apply(o *backendSettings) {
+	o.onError = w.h
+}
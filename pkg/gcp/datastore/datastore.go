@@ -20,9 +20,10 @@ package datastore // import "l7e.io/vanity/pkg/gcp/datastore"
 import (
 	"context"
 	"sync"
+	"time"
 
 	"cloud.google.com/go/datastore"
-	"google.golang.org/api/option"
+	"google.golang.org/api/iterator"
 
 	"l7e.io/vanity"
 )
@@ -33,24 +34,29 @@ const (
 )
 
 type datastoreClient struct {
-	client *datastore.Client
-	lock   sync.RWMutex
-}
-
-// NewClient creates a new Client for a given dataset.  If the project ID is
-// empty, it is derived from the DATASTORE_PROJECT_ID environment variable.
-// If the DATASTORE_EMULATOR_HOST environment variable is set, client will use
-// its value to connect to a locally-running datastore emulator.
-// DetectProjectID can be passed as the projectID argument to instruct
-// NewClient to detect the project ID from the credentials.
-func NewClient(projectID string, opts ...option.ClientOption) (vanity.Backend, error) {
-	client, err := datastore.NewClient(context.Background(), projectID, opts...)
+	client       *datastore.Client
+	pollInterval time.Duration
+	lock         sync.RWMutex
+}
+
+// NewClient creates a new Client for a given dataset, using the specified
+// options.  If the project ID is empty, it is derived from the
+// DATASTORE_PROJECT_ID environment variable.  If the DATASTORE_EMULATOR_HOST
+// environment variable is set, client will use its value to connect to a
+// locally-running datastore emulator.  DetectProjectID can be passed as the
+// projectID argument to instruct NewClient to detect the project ID from the
+// credentials.
+func NewClient(projectID string, opts ...BackendOption) (vanity.EntryBackend, error) {
+	bs := collectSettings(opts...)
+
+	client, err := datastore.NewClient(context.Background(), projectID, bs.options...)
 	if err != nil {
 		return nil, err
 	}
 
 	return &datastoreClient{
-		client: client,
+		client:       client,
+		pollInterval: bs.pollInterval,
 	}, nil
 }
 
@@ -65,8 +71,20 @@ func (d *datastoreClient) checkClosed() error {
 	return nil
 }
 
+// Watch polls the entities for changes every poll interval, using their
+// Updated property for positions.
+func (d *datastoreClient) Watch(ctx context.Context, position string) (<-chan vanity.Event, error) {
+	if err := d.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	return vanity.Poll(ctx, d, d.pollInterval, position)
+}
+
 func (d *datastoreClient) Healthz(ctx context.Context) error {
-	return d.List(ctx, nil)
+	_, err := d.ListPage(ctx, vanity.ListOptions{PageSize: 1}, vanity.EntryConsumerFunc(func(context.Context, *vanity.Entry) {}))
+
+	return err
 }
 
 func (d *datastoreClient) Close() error {
@@ -84,8 +102,17 @@ func (d *datastoreClient) Close() error {
 }
 
 func (d *datastoreClient) Get(ctx context.Context, importPath string) (vcs, vcsPath string, err error) {
-	if err = d.checkClosed(); err != nil {
-		return
+	e, err := d.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return e.VCS, e.VCSPath, nil
+}
+
+func (d *datastoreClient) GetEntry(ctx context.Context, importPath string) (*vanity.Entry, error) {
+	if err := d.checkClosed(); err != nil {
+		return nil, err
 	}
 
 	key := datastore.NameKey(kind, importPath, nil)
@@ -94,29 +121,215 @@ func (d *datastoreClient) Get(ctx context.Context, importPath string) (vcs, vcsP
 
 	if err := d.client.Get(ctx, key, &e); err != nil {
 		if err == datastore.ErrNoSuchEntity {
-			return "", "", vanity.ErrNotFound
+			return nil, vanity.ErrNotFound
 		}
 
-		return "", "", err
+		return nil, err
 	}
 
-	return e.Vcs, e.VcsRoot, nil
+	return e.toEntry(), nil
+}
+
+func (d *datastoreClient) GetPrefix(ctx context.Context, path string) (*vanity.Entry, error) {
+	if err := d.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	candidates := vanity.Prefixes(path)
+	if len(candidates) == 0 {
+		return nil, vanity.ErrNotFound
+	}
+
+	keys := make([]*datastore.Key, len(candidates))
+	for i, candidate := range candidates {
+		keys[i] = datastore.NameKey(kind, candidate, nil)
+	}
+
+	entries := make([]*Entry, len(keys))
+	for i := range entries {
+		entries[i] = &Entry{}
+	}
+
+	err := d.client.GetMulti(ctx, keys, entries)
+	if err == nil {
+		// candidates are ordered longest first
+		return entries[0].toEntry(), nil
+	}
+
+	me, ok := err.(datastore.MultiError)
+	if !ok {
+		return nil, err
+	}
+
+	for i, e := range me {
+		switch e {
+		case nil:
+			return entries[i].toEntry(), nil
+		case datastore.ErrNoSuchEntity:
+		default:
+			return nil, e
+		}
+	}
+
+	return nil, vanity.ErrNotFound
 }
 
 func (d *datastoreClient) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return d.InsertEntry(ctx, vanity.NewEntry(importPath, vcs, vcsPath))
+}
+
+// Write modes of put.
+const (
+	insert = iota
+	update
+	upsert
+)
+
+func (d *datastoreClient) InsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return d.put(ctx, entry, insert, vanity.AnyRevision)
+}
+
+func (d *datastoreClient) UpdateEntry(ctx context.Context, entry *vanity.Entry, ifRevision int64) error {
+	return d.put(ctx, entry, update, ifRevision)
+}
+
+func (d *datastoreClient) UpsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return d.put(ctx, entry, upsert, vanity.AnyRevision)
+}
+
+// put stores entry in a transaction that checks whether the import path
+// exists, as required by mode, and its revision unless ifRevision is
+// AnyRevision.  The creation time of an existing entity is kept.
+func (d *datastoreClient) put(ctx context.Context, entry *vanity.Entry, mode int, ifRevision int64) error {
 	if err := d.checkClosed(); err != nil {
 		return err
 	}
 
-	var add = &Entry{ImportPath: importPath, Vcs: vcs, VcsRoot: vcsPath}
+	_, err := d.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		key := datastore.NameKey(kind, entry.ImportPath, nil)
+
+		e := entry.Clone()
+		now := time.Now().UTC()
+
+		var old Entry
+		err := tx.Get(key, &old)
+		switch {
+		case err == datastore.ErrNoSuchEntity:
+			if mode == update {
+				return vanity.ErrNotFound
+			}
+			e.Revision = 1
+			if e.Created.IsZero() {
+				e.Created = now
+			}
+		case err != nil:
+			return err
+		default:
+			if mode == insert {
+				return vanity.ErrAlreadyExists
+			}
+			current := old.toEntry()
+			if ifRevision != vanity.AnyRevision && ifRevision != current.Revision {
+				return vanity.ErrConflict
+			}
+			e.Revision = current.Revision + 1
+			e.Created = current.Created
+		}
+		e.Updated = now
+
+		put, err := fromEntry(e)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Put(key, put); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// Apply applies the mutations in a single transaction, which is limited to
+// 500 entities.
+func (d *datastoreClient) Apply(ctx context.Context, mutations []vanity.Mutation) error {
+	if err := d.checkClosed(); err != nil {
+		return err
+	}
+
+	var keys []*datastore.Key
+	seen := make(map[string]bool)
+	for _, m := range mutations {
+		if m.Entry != nil && !seen[m.Entry.ImportPath] {
+			seen[m.Entry.ImportPath] = true
+			keys = append(keys, datastore.NameKey(kind, m.Entry.ImportPath, nil))
+		}
+	}
 
 	_, err := d.client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
-		key := datastore.NameKey(kind, importPath, nil)
+		entries := make(map[string]*vanity.Entry)
+		existed := make(map[string]bool)
+
+		if len(keys) > 0 {
+			olds := make([]*Entry, len(keys))
+			for i := range olds {
+				olds[i] = &Entry{}
+			}
+
+			err := tx.GetMulti(keys, olds)
+			me, _ := err.(datastore.MultiError)
+			if err != nil && me == nil {
+				return err
+			}
+
+			for i, key := range keys {
+				if me != nil && me[i] != nil {
+					if me[i] == datastore.ErrNoSuchEntity {
+						continue
+					}
+					return me[i]
+				}
+				entries[key.Name] = olds[i].toEntry()
+				existed[key.Name] = true
+			}
+		}
 
-		if _, err := tx.Put(key, add); err != nil {
+		if err := vanity.ApplyMutations(entries, mutations, time.Now().UTC()); err != nil {
 			return err
 		}
 
+		var puts, deletes []*datastore.Key
+		var src []*Entry
+		for _, key := range keys {
+			e := entries[key.Name]
+
+			if e == nil {
+				if existed[key.Name] {
+					deletes = append(deletes, key)
+				}
+				continue
+			}
+
+			put, err := fromEntry(e)
+			if err != nil {
+				return err
+			}
+			puts = append(puts, key)
+			src = append(src, put)
+		}
+
+		if len(puts) > 0 {
+			if _, err := tx.PutMulti(puts, src); err != nil {
+				return err
+			}
+		}
+
+		if len(deletes) > 0 {
+			return tx.DeleteMulti(deletes)
+		}
+
 		return nil
 	})
 
@@ -142,31 +355,99 @@ func (d *datastoreClient) Remove(ctx context.Context, importPath string) error {
 }
 
 func (d *datastoreClient) List(ctx context.Context, consumer vanity.Consumer) error {
-	if err := d.checkClosed(); err != nil {
-		return err
+	var ec vanity.EntryConsumer
+	if consumer != nil {
+		ec = vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+			consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+		})
+	}
+
+	return d.ListEntries(ctx, ec)
+}
+
+func (d *datastoreClient) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	if consumer == nil {
+		consumer = vanity.EntryConsumerFunc(func(context.Context, *vanity.Entry) {})
 	}
 
-	query := datastore.NewQuery(kind).Order(key)
+	_, err := d.ListPage(ctx, vanity.ListOptions{}, consumer)
 
-	var all []*Entry
+	return err
+}
 
-	if _, err := d.client.GetAll(ctx, query, &all); err != nil {
-		return err
+// ListPage lists a page of entries with a query whose page tokens are
+// datastore cursors.
+func (d *datastoreClient) ListPage(ctx context.Context, opts vanity.ListOptions, consumer vanity.EntryConsumer) (string, error) {
+	if err := d.checkClosed(); err != nil {
+		return "", err
 	}
 
-	if consumer == nil {
-		return nil
+	query, err := pageQuery(opts)
+	if err != nil {
+		return "", err
 	}
 
-	for _, e := range all {
+	it := d.client.Run(ctx, query)
+
+	for n := 0; ; n++ {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return "", ctx.Err()
 		default:
 		}
 
-		consumer.OnEntry(ctx, e.ImportPath, e.Vcs, e.VcsRoot)
+		var cursor datastore.Cursor
+		if opts.PageSize > 0 && n == opts.PageSize {
+			if cursor, err = it.Cursor(); err != nil {
+				return "", err
+			}
+		}
+
+		var e Entry
+		_, err := it.Next(&e)
+
+		switch {
+		case err == iterator.Done:
+			return "", nil
+		case err != nil:
+			return "", err
+		}
+
+		if opts.PageSize > 0 && n == opts.PageSize {
+			// the extra entity shows there is another page
+			return cursor.String(), nil
+		}
+
+		consumer.Consume(ctx, e.toEntry())
 	}
+}
 
-	return nil
+// pageQuery creates the query of a page of entries.  One more entity than the
+// size of the page is selected to determine whether there is another page.
+func pageQuery(opts vanity.ListOptions) (*datastore.Query, error) {
+	query := datastore.NewQuery(kind)
+
+	if opts.Prefix != "" {
+		query = query.Filter(key+" >=", opts.Prefix).Filter(key+" <", opts.Prefix+"\U0010FFFF")
+	}
+
+	if opts.Order == vanity.Descending {
+		query = query.Order("-" + key)
+	} else {
+		query = query.Order(key)
+	}
+
+	if opts.PageToken != "" {
+		cursor, err := datastore.DecodeCursor(opts.PageToken)
+		if err != nil {
+			return nil, vanity.ErrInvalidPageToken
+		}
+		query = query.Start(cursor)
+	}
+
+	if opts.PageSize > 0 {
+		query = query.Limit(opts.PageSize + 1)
+	}
+
+	return query, nil
 }
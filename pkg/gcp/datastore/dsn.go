@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package datastore
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"google.golang.org/api/option"
+	"l7e.io/vanity"
+)
+
+func init() { //nolint:gochecknoinits
+	vanity.RegisterBackend("datastore", openDSN)
+}
+
+// openDSN creates a Datastore-based Backend from a data source name such as
+// "datastore://my-project".  Without a project, the project is obtained from
+// the DATASTORE_PROJECT_ID environment variable.
+func openDSN(_ context.Context, dsn *url.URL) (vanity.Backend, error) {
+	projectID, opts, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(projectID, opts...)
+}
+
+// parseDSN obtains the project, and the options set by the poll-interval and
+// credentials-file query parameters, of a data source name.
+func parseDSN(dsn *url.URL) (string, []BackendOption, error) {
+	var opts []BackendOption
+	q := dsn.Query()
+
+	if p := q.Get("poll-interval"); p != "" {
+		d, err := time.ParseDuration(p)
+		if err != nil {
+			return "", nil, vanity.ErrInvalidDSN
+		}
+		opts = append(opts, WithPollInterval(d))
+	}
+
+	if f := q.Get("credentials-file"); f != "" {
+		opts = append(opts, WithClientOptions([]option.ClientOption{option.WithCredentialsFile(f)}))
+	}
+
+	return dsn.Host, opts, nil
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package datastore
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+)
+
+func TestParseDSN(t *testing.T) {
+	u, _ := url.Parse("datastore://my-project?credentials-file=/etc/vanity/sa.json&poll-interval=1m")
+	projectID, opts, err := parseDSN(u)
+	assert.NoError(t, err)
+	assert.Equal(t, "my-project", projectID)
+	assert.Len(t, opts, 2)
+
+	bs := collectSettings(opts...)
+	assert.Equal(t, time.Minute, bs.pollInterval)
+	assert.Len(t, bs.options, 1)
+
+	u, _ = url.Parse("datastore:")
+	projectID, opts, err = parseDSN(u)
+	assert.NoError(t, err)
+	assert.Empty(t, projectID)
+	assert.Empty(t, opts)
+	assert.Equal(t, vanity.DefaultPollInterval, collectSettings(opts...).pollInterval)
+
+	u, _ = url.Parse("datastore://my-project?poll-interval=often")
+	_, _, err = parseDSN(u)
+	assert.Equal(t, vanity.ErrInvalidDSN, err)
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright (c) 2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package datastore
+
+import (
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"l7e.io/vanity"
+)
+
+// Datastore property names of an Entry.
+const (
+	importPathProperty     = "ImportPath"
+	vcsProperty            = "Vcs"
+	vcsRootProperty        = "VcsRoot"
+	versionProperty        = "Version"
+	revisionProperty       = "Revision"
+	descriptionProperty    = "Description"
+	ownerProperty          = "Owner"
+	defaultBranchProperty  = "DefaultBranch"
+	docURLProperty         = "DocUrl"
+	sourceTemplateProperty = "SourceTemplate"
+	visibilityProperty     = "Visibility"
+	labelsProperty         = "Labels"
+	createdProperty        = "Created"
+	updatedProperty        = "Updated"
+)
+
+// Save implements datastore.PropertyLoadSaver.  The labels are saved as a
+// nested entity and the timestamps as datastore timestamps.
+func (m *Entry) Save() ([]datastore.Property, error) {
+	props := []datastore.Property{
+		{Name: importPathProperty, Value: m.ImportPath},
+		{Name: vcsProperty, Value: m.Vcs},
+		{Name: vcsRootProperty, Value: m.VcsRoot},
+		{Name: versionProperty, Value: int64(m.Version)},
+		{Name: revisionProperty, Value: m.Revision},
+		{Name: descriptionProperty, Value: m.Description, NoIndex: true},
+		{Name: ownerProperty, Value: m.Owner},
+		{Name: defaultBranchProperty, Value: m.DefaultBranch, NoIndex: true},
+		{Name: docURLProperty, Value: m.DocUrl, NoIndex: true},
+		{Name: sourceTemplateProperty, Value: m.SourceTemplate, NoIndex: true},
+		{Name: visibilityProperty, Value: m.Visibility},
+	}
+
+	if len(m.Labels) > 0 {
+		labels := &datastore.Entity{}
+		for k, v := range m.Labels {
+			labels.Properties = append(labels.Properties, datastore.Property{Name: k, Value: v})
+		}
+		props = append(props, datastore.Property{Name: labelsProperty, Value: labels})
+	}
+
+	if m.Created != nil {
+		t, err := ptypes.Timestamp(m.Created)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, datastore.Property{Name: createdProperty, Value: t})
+	}
+
+	if m.Updated != nil {
+		t, err := ptypes.Timestamp(m.Updated)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, datastore.Property{Name: updatedProperty, Value: t})
+	}
+
+	return props, nil
+}
+
+// Load implements datastore.PropertyLoadSaver.  Unknown properties are
+// ignored, and missing properties, e.g. of entities stored before entries
+// carried metadata, are left empty.
+func (m *Entry) Load(props []datastore.Property) error {
+	*m = Entry{}
+
+	for _, p := range props {
+		var ok bool
+
+		switch p.Name {
+		case importPathProperty:
+			m.ImportPath, ok = p.Value.(string)
+		case vcsProperty:
+			m.Vcs, ok = p.Value.(string)
+		case vcsRootProperty:
+			m.VcsRoot, ok = p.Value.(string)
+		case versionProperty:
+			var v int64
+			v, ok = p.Value.(int64)
+			m.Version = int32(v)
+		case revisionProperty:
+			m.Revision, ok = p.Value.(int64)
+		case descriptionProperty:
+			m.Description, ok = p.Value.(string)
+		case ownerProperty:
+			m.Owner, ok = p.Value.(string)
+		case defaultBranchProperty:
+			m.DefaultBranch, ok = p.Value.(string)
+		case docURLProperty:
+			m.DocUrl, ok = p.Value.(string)
+		case sourceTemplateProperty:
+			m.SourceTemplate, ok = p.Value.(string)
+		case visibilityProperty:
+			m.Visibility, ok = p.Value.(string)
+		case labelsProperty:
+			var labels *datastore.Entity
+			if labels, ok = p.Value.(*datastore.Entity); ok {
+				m.Labels = make(map[string]string, len(labels.Properties))
+				for _, l := range labels.Properties {
+					m.Labels[l.Name], _ = l.Value.(string)
+				}
+			}
+		case createdProperty:
+			m.Created, ok = timestampOf(p.Value)
+		case updatedProperty:
+			m.Updated, ok = timestampOf(p.Value)
+		default:
+			ok = true
+		}
+
+		if !ok && p.Value != nil {
+			return fmt.Errorf("unexpected type %T of property %s", p.Value, p.Name)
+		}
+	}
+
+	return nil
+}
+
+func timestampOf(v interface{}) (*timestamp.Timestamp, bool) {
+	t, ok := v.(time.Time)
+	if !ok {
+		return nil, false
+	}
+
+	ts, err := ptypes.TimestampProto(t)
+	if err != nil {
+		return nil, false
+	}
+
+	return ts, true
+}
+
+// toEntry converts a stored entity into a vanity.Entry.
+func (m *Entry) toEntry() *vanity.Entry {
+	e := &vanity.Entry{
+		Version:        int(m.Version),
+		Revision:       m.Revision,
+		ImportPath:     m.ImportPath,
+		VCS:            m.Vcs,
+		VCSPath:        m.VcsRoot,
+		Description:    m.Description,
+		Owner:          m.Owner,
+		DefaultBranch:  m.DefaultBranch,
+		DocURL:         m.DocUrl,
+		SourceTemplate: m.SourceTemplate,
+		Visibility:     vanity.Visibility(m.Visibility),
+		Labels:         m.Labels,
+	}
+
+	if e.Version == 0 {
+		e.Version = vanity.EntryVersion
+	}
+	if e.Revision == 0 {
+		// entities stored before entries carried revisions
+		e.Revision = 1
+	}
+	if m.Created != nil {
+		e.Created, _ = ptypes.Timestamp(m.Created)
+	}
+	if m.Updated != nil {
+		e.Updated, _ = ptypes.Timestamp(m.Updated)
+	}
+
+	return e
+}
+
+// fromEntry converts a vanity.Entry into an entity to be stored.
+func fromEntry(e *vanity.Entry) (*Entry, error) {
+	m := &Entry{
+		Version:        int32(e.Version),
+		Revision:       e.Revision,
+		ImportPath:     e.ImportPath,
+		Vcs:            e.VCS,
+		VcsRoot:        e.VCSPath,
+		Description:    e.Description,
+		Owner:          e.Owner,
+		DefaultBranch:  e.DefaultBranch,
+		DocUrl:         e.DocURL,
+		SourceTemplate: e.SourceTemplate,
+		Visibility:     string(e.Visibility),
+		Labels:         e.Labels,
+	}
+
+	if m.Version == 0 {
+		m.Version = vanity.EntryVersion
+	}
+
+	var err error
+	if !e.Created.IsZero() {
+		if m.Created, err = ptypes.TimestampProto(e.Created); err != nil {
+			return nil, err
+		}
+	}
+	if !e.Updated.IsZero() {
+		if m.Updated, err = ptypes.TimestampProto(e.Updated); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
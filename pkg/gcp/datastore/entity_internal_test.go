@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package datastore
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+)
+
+func TestEntry_roundTrip(t *testing.T) {
+	e := vanity.NewEntry("a.com/b", "git", "https://github.com/b")
+	e.Revision = 3
+	e.Description = "b"
+	e.Owner = "platform"
+	e.DefaultBranch = "main"
+	e.DocURL = "https://docs.a.com/"
+	e.SourceTemplate = vanity.ForgeGitHub
+	e.Visibility = vanity.VisibilityPrivate
+	e.Labels = map[string]string{"tier": "core"}
+	e.Created = time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	e.Updated = time.Date(2020, 6, 2, 10, 0, 0, 0, time.UTC)
+
+	m, err := fromEntry(e)
+	assert.NoError(t, err)
+
+	props, err := m.Save()
+	assert.NoError(t, err)
+
+	var loaded Entry
+	assert.NoError(t, loaded.Load(props))
+	assert.Equal(t, e, loaded.toEntry())
+}
+
+func TestEntry_Load_legacy(t *testing.T) {
+	var loaded Entry
+	err := loaded.Load([]datastore.Property{
+		{Name: importPathProperty, Value: "a.com/b"},
+		{Name: vcsProperty, Value: "git"},
+		{Name: vcsRootProperty, Value: "https://github.com/b"},
+		{Name: "Unknown", Value: int64(1)},
+	})
+	assert.NoError(t, err)
+
+	expected := vanity.NewEntry("a.com/b", "git", "https://github.com/b")
+	expected.Revision = 1
+	assert.Equal(t, expected, loaded.toEntry())
+
+	err = loaded.Load([]datastore.Property{{Name: vcsProperty, Value: int64(1)}})
+	assert.Error(t, err)
+}
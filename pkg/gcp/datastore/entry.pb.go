@@ -6,6 +6,7 @@ package datastore
 import (
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
 	math "math"
 )
 
@@ -21,12 +22,23 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type Entry struct {
-	ImportPath           string   `protobuf:"bytes,1,opt,name=importPath,proto3" json:"importPath,omitempty"`
-	Vcs                  string   `protobuf:"bytes,2,opt,name=vcs,proto3" json:"vcs,omitempty"`
-	VcsRoot              string   `protobuf:"bytes,3,opt,name=vcsRoot,proto3" json:"vcsRoot,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	ImportPath           string               `protobuf:"bytes,1,opt,name=importPath,proto3" json:"importPath,omitempty"`
+	Vcs                  string               `protobuf:"bytes,2,opt,name=vcs,proto3" json:"vcs,omitempty"`
+	VcsRoot              string               `protobuf:"bytes,3,opt,name=vcsRoot,proto3" json:"vcsRoot,omitempty"`
+	Version              int32                `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"`
+	Description          string               `protobuf:"bytes,5,opt,name=description,proto3" json:"description,omitempty"`
+	Owner                string               `protobuf:"bytes,6,opt,name=owner,proto3" json:"owner,omitempty"`
+	DefaultBranch        string               `protobuf:"bytes,7,opt,name=defaultBranch,proto3" json:"defaultBranch,omitempty"`
+	DocUrl               string               `protobuf:"bytes,8,opt,name=docUrl,proto3" json:"docUrl,omitempty"`
+	SourceTemplate       string               `protobuf:"bytes,9,opt,name=sourceTemplate,proto3" json:"sourceTemplate,omitempty"`
+	Visibility           string               `protobuf:"bytes,10,opt,name=visibility,proto3" json:"visibility,omitempty"`
+	Labels               map[string]string    `protobuf:"bytes,11,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Created              *timestamp.Timestamp `protobuf:"bytes,12,opt,name=created,proto3" json:"created,omitempty"`
+	Updated              *timestamp.Timestamp `protobuf:"bytes,13,opt,name=updated,proto3" json:"updated,omitempty"`
+	Revision             int64                `protobuf:"varint,14,opt,name=revision,proto3" json:"revision,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
 }
 
 func (m *Entry) Reset()         { *m = Entry{} }
@@ -75,23 +87,115 @@ func (m *Entry) GetVcsRoot() string {
 	return ""
 }
 
+func (m *Entry) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Entry) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *Entry) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *Entry) GetDefaultBranch() string {
+	if m != nil {
+		return m.DefaultBranch
+	}
+	return ""
+}
+
+func (m *Entry) GetDocUrl() string {
+	if m != nil {
+		return m.DocUrl
+	}
+	return ""
+}
+
+func (m *Entry) GetSourceTemplate() string {
+	if m != nil {
+		return m.SourceTemplate
+	}
+	return ""
+}
+
+func (m *Entry) GetVisibility() string {
+	if m != nil {
+		return m.Visibility
+	}
+	return ""
+}
+
+func (m *Entry) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *Entry) GetCreated() *timestamp.Timestamp {
+	if m != nil {
+		return m.Created
+	}
+	return nil
+}
+
+func (m *Entry) GetUpdated() *timestamp.Timestamp {
+	if m != nil {
+		return m.Updated
+	}
+	return nil
+}
+
+func (m *Entry) GetRevision() int64 {
+	if m != nil {
+		return m.Revision
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Entry)(nil), "livetribe.vanity.gcp.datastore.Entry")
+	proto.RegisterMapType((map[string]string)(nil), "livetribe.vanity.gcp.datastore.Entry.LabelsEntry")
 }
 
 func init() { proto.RegisterFile("entry.proto", fileDescriptor_daa6c5b6c627940f) }
 
 var fileDescriptor_daa6c5b6c627940f = []byte{
-	// 162 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x4e, 0xcd, 0x2b, 0x29,
-	0xaa, 0xd4, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x52, 0xca, 0xc9, 0x2c, 0x4b, 0x2d, 0x29, 0xca,
-	0x4c, 0x4a, 0xd5, 0x4b, 0x2d, 0x4e, 0x2e, 0x2d, 0x49, 0xce, 0x48, 0xcd, 0xcf, 0xd3, 0x4b, 0x4f,
-	0x2e, 0xd0, 0x4b, 0x49, 0x2c, 0x49, 0x2c, 0x2e, 0xc9, 0x2f, 0x4a, 0x55, 0x0a, 0xe6, 0x62, 0x75,
-	0x05, 0x69, 0x11, 0x92, 0xe3, 0xe2, 0xca, 0xcc, 0x2d, 0xc8, 0x2f, 0x2a, 0x09, 0x48, 0x2c, 0xc9,
-	0x90, 0x60, 0x54, 0x60, 0xd4, 0xe0, 0x0c, 0x42, 0x12, 0x11, 0x12, 0xe0, 0x62, 0x2e, 0x4b, 0x2e,
-	0x96, 0x60, 0x02, 0x4b, 0x80, 0x98, 0x42, 0x12, 0x5c, 0xec, 0x65, 0xc9, 0xc5, 0x41, 0xf9, 0xf9,
-	0x25, 0x12, 0xcc, 0x60, 0x51, 0x18, 0xd7, 0x49, 0x35, 0x4a, 0x39, 0xc7, 0x3c, 0x55, 0x2f, 0x33,
-	0x5f, 0x1f, 0x61, 0xaf, 0x7e, 0x41, 0x76, 0xba, 0x7e, 0x7a, 0x72, 0x81, 0x3e, 0xdc, 0xee, 0x24,
-	0x36, 0xb0, 0x33, 0x8d, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0x14, 0x2c, 0xfa, 0x01, 0xb5, 0x00,
-	0x00, 0x00,
+	// 400 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x51, 0x4d, 0x6f, 0xd4, 0x30,
+	0x10, 0x55, 0xba, 0xdd, 0xdd, 0xd6, 0xa1, 0x15, 0xb2, 0x10, 0xb2, 0x72, 0x68, 0x03, 0x42, 0x28,
+	0x27, 0x47, 0x14, 0x24, 0x3e, 0x8e, 0x95, 0x38, 0x20, 0x71, 0x40, 0x51, 0xb9, 0x70, 0x73, 0x9c,
+	0x69, 0x6a, 0xd5, 0x89, 0x2d, 0x7b, 0x12, 0x94, 0x9f, 0xc6, 0xbf, 0x43, 0x76, 0x92, 0xb2, 0x70,
+	0xa0, 0xb7, 0x79, 0xef, 0xcd, 0x1b, 0xcd, 0xcc, 0x23, 0x29, 0xf4, 0xe8, 0x26, 0x6e, 0x9d, 0x41,
+	0x43, 0x2f, 0xb4, 0x1a, 0x01, 0x9d, 0xaa, 0x81, 0x8f, 0xa2, 0x57, 0x38, 0xf1, 0x56, 0x5a, 0xde,
+	0x08, 0x14, 0x1e, 0x8d, 0x83, 0xec, 0xb2, 0x35, 0xa6, 0xd5, 0x50, 0xc6, 0xee, 0x7a, 0xb8, 0x2d,
+	0x51, 0x75, 0xe0, 0x51, 0x74, 0x76, 0x1e, 0xf0, 0xf2, 0xd7, 0x31, 0xd9, 0x7e, 0x0e, 0x03, 0xe9,
+	0x05, 0x21, 0xaa, 0xb3, 0xc6, 0xe1, 0x37, 0x81, 0x77, 0x2c, 0xc9, 0x93, 0xe2, 0xb4, 0x3a, 0x60,
+	0xe8, 0x53, 0xb2, 0x19, 0xa5, 0x67, 0x47, 0x51, 0x08, 0x25, 0x65, 0x64, 0x3f, 0x4a, 0x5f, 0x19,
+	0x83, 0x6c, 0x13, 0xd9, 0x15, 0x46, 0x05, 0x9c, 0x57, 0xa6, 0x67, 0xc7, 0x79, 0x52, 0x6c, 0xab,
+	0x15, 0xd2, 0x9c, 0xa4, 0x0d, 0x78, 0xe9, 0x94, 0xc5, 0xa0, 0x6e, 0xa3, 0xef, 0x90, 0xa2, 0xcf,
+	0xc8, 0xd6, 0xfc, 0xec, 0xc1, 0xb1, 0x5d, 0xd4, 0x66, 0x40, 0x5f, 0x91, 0xb3, 0x06, 0x6e, 0xc5,
+	0xa0, 0xf1, 0xda, 0x89, 0x5e, 0xde, 0xb1, 0x7d, 0x54, 0xff, 0x26, 0xe9, 0x73, 0xb2, 0x6b, 0x8c,
+	0xfc, 0xee, 0x34, 0x3b, 0x89, 0xf2, 0x82, 0xe8, 0x6b, 0x72, 0xee, 0xcd, 0xe0, 0x24, 0xdc, 0x40,
+	0x67, 0xb5, 0x40, 0x60, 0xa7, 0x51, 0xff, 0x87, 0x0d, 0x3f, 0x18, 0x95, 0x57, 0xb5, 0xd2, 0x0a,
+	0x27, 0x46, 0xe6, 0x1f, 0xfc, 0x61, 0xe8, 0x17, 0xb2, 0xd3, 0xa2, 0x06, 0xed, 0x59, 0x9a, 0x6f,
+	0x8a, 0xf4, 0xea, 0x0d, 0xff, 0xff, 0xff, 0x79, 0x7c, 0x2d, 0xff, 0x1a, 0x3d, 0xb1, 0xae, 0x96,
+	0x01, 0xf4, 0x1d, 0xd9, 0x4b, 0x07, 0x02, 0xa1, 0x61, 0x4f, 0xf2, 0xa4, 0x48, 0xaf, 0x32, 0x3e,
+	0x67, 0xc5, 0xd7, 0xac, 0xf8, 0xcd, 0x9a, 0x55, 0xb5, 0xb6, 0x06, 0xd7, 0x60, 0x9b, 0xe8, 0x3a,
+	0x7b, 0xdc, 0xb5, 0xb4, 0xd2, 0x8c, 0x9c, 0x38, 0x08, 0x67, 0x98, 0x9e, 0x9d, 0xe7, 0x49, 0xb1,
+	0xa9, 0x1e, 0x70, 0xf6, 0x91, 0xa4, 0x07, 0xeb, 0x85, 0x94, 0xef, 0x61, 0x5a, 0xe2, 0x0f, 0x65,
+	0xc8, 0x63, 0x14, 0x7a, 0x80, 0x25, 0xf9, 0x19, 0x7c, 0x3a, 0xfa, 0x90, 0x5c, 0xbf, 0xf8, 0x71,
+	0xa9, 0xdf, 0x03, 0x57, 0xa6, 0x9c, 0x6f, 0x2f, 0xed, 0x7d, 0x5b, 0xb6, 0xd2, 0x96, 0x0f, 0xf7,
+	0xd7, 0xbb, 0xb8, 0xd6, 0xdb, 0xdf, 0x03, 0x00, 0x92, 0x4b, 0x7e, 0x45, 0xb5, 0x02, 0x00, 0x00,
 }
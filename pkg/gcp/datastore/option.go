@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package datastore
+
+import (
+	"time"
+
+	"google.golang.org/api/option"
+
+	"l7e.io/vanity"
+)
+
+type backendSettings struct {
+	pollInterval time.Duration
+	options      []option.ClientOption
+}
+
+// A BackendOption is an option for a Datastore-based backend.
+type BackendOption interface {
+	Apply(*backendSettings)
+}
+
+// WithClientOptions returns a BackendOption that specifies Google API client
+// configurations for the Datastore client.
+func WithClientOptions(o []option.ClientOption) BackendOption {
+	return withClientOptions{o}
+}
+
+type withClientOptions struct{ o []option.ClientOption }
+
+func (w withClientOptions) Apply(o *backendSettings) {
+	o.options = w.o
+}
+
+// WithPollInterval configures the interval between the reads of the entities
+// when watching for changes; default is vanity.DefaultPollInterval.
+func WithPollInterval(d time.Duration) BackendOption {
+	return withPollInterval{d}
+}
+
+type withPollInterval struct{ d time.Duration }
+
+func (w withPollInterval) Apply(o *backendSettings) {
+	o.pollInterval = w.d
+}
+
+func collectSettings(opts ...BackendOption) *backendSettings {
+	bs := &backendSettings{
+		pollInterval: vanity.DefaultPollInterval,
+	}
+
+	for _, o := range opts {
+		o.Apply(bs)
+	}
+
+	return bs
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spanner
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/api/option"
+	"l7e.io/vanity"
+)
+
+func init() { //nolint:gochecknoinits
+	vanity.RegisterBackend("spanner", openDSN)
+}
+
+// openDSN creates a Spanner-based Backend from a data source name such as
+// "spanner://projects/p/instances/i/databases/d?table=urls".
+func openDSN(ctx context.Context, dsn *url.URL) (vanity.Backend, error) {
+	database, opts, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(ctx, database, opts...)
+}
+
+// parseDSN obtains the database, and the options set by the table,
+// poll-interval and credentials-file query parameters, of a data source name.
+func parseDSN(dsn *url.URL) (string, []BackendOption, error) {
+	database := strings.TrimSuffix(dsn.Host+dsn.Path, "/")
+	if !strings.HasPrefix(database, "projects/") {
+		return "", nil, vanity.ErrInvalidDSN
+	}
+
+	var opts []BackendOption
+	q := dsn.Query()
+
+	if t := q.Get("table"); t != "" {
+		opts = append(opts, WithTable(t))
+	}
+
+	if p := q.Get("poll-interval"); p != "" {
+		d, err := time.ParseDuration(p)
+		if err != nil {
+			return "", nil, vanity.ErrInvalidDSN
+		}
+		opts = append(opts, WithPollInterval(d))
+	}
+
+	if f := q.Get("credentials-file"); f != "" {
+		opts = append(opts, WithClientOptions([]option.ClientOption{option.WithCredentialsFile(f)}))
+	}
+
+	return database, opts, nil
+}
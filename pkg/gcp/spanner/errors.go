@@ -17,7 +17,6 @@
 package spanner
 
 const (
-	unableToRetrieve       = "unable to retrieve record for %s: %s"
-	unableToExtractVcs     = "unable to extract vcs for %s: %s"
-	unableToExtractVcsPath = "unable to extract vcsPath for %s: %s"
+	unableToRetrieve     = "unable to retrieve record for %s: %s"
+	unableToExtractEntry = "unable to extract entry for %s: %s"
 )
@@ -18,7 +18,6 @@
 package spanner
 
 const (
-	unableToRetrieve       = "unable to retrieve record for %s: %w"
-	unableToExtractVcs     = "unable to extract vcs for %s: %w"
-	unableToExtractVcsPath = "unable to extract vcsPath for %s: %w"
+	unableToRetrieve     = "unable to retrieve record for %s: %w"
+	unableToExtractEntry = "unable to extract entry for %s: %w"
 )
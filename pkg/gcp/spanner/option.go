@@ -17,8 +17,11 @@
 package spanner
 
 import (
+	"time"
+
 	"cloud.google.com/go/spanner"
 	"google.golang.org/api/option"
+	"l7e.io/vanity"
 )
 
 const (
@@ -31,9 +34,10 @@ const (
 )
 
 type backendSettings struct {
-	table   string
-	config  *spanner.ClientConfig
-	options []option.ClientOption
+	table        string
+	config       *spanner.ClientConfig
+	options      []option.ClientOption
+	pollInterval time.Duration
 }
 
 // A BackendOption is an option for a Spanner-based backend.
@@ -78,9 +82,22 @@ func (w withClientOptions) Apply(o *backendSettings) {
 	o.options = w.o
 }
 
+// WithPollInterval configures the interval between the reads of the table
+// when watching for changes; default is vanity.DefaultPollInterval.
+func WithPollInterval(d time.Duration) BackendOption {
+	return withPollInterval{d}
+}
+
+type withPollInterval struct{ d time.Duration }
+
+func (w withPollInterval) Apply(o *backendSettings) {
+	o.pollInterval = w.d
+}
+
 func collectSettings(opts ...BackendOption) *backendSettings {
 	bs := &backendSettings{
-		table: DefaultTable,
+		table:        DefaultTable,
+		pollInterval: vanity.DefaultPollInterval,
 		config: &spanner.ClientConfig{
 			NumChannels: DefaultNumChannels,
 		},
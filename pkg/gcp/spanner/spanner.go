@@ -19,8 +19,12 @@ package spanner // import "l7e.io/vanity/pkg/gcp/spanner"
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	"google.golang.org/api/iterator"
@@ -30,20 +34,51 @@ import (
 )
 
 const (
-	importPathColumn = "import_path"
-	vcsColumn        = "vcs"
-	vcsPathColumn    = "vcs_path"
+	importPathColumn     = "import_path"
+	vcsColumn            = "vcs"
+	vcsPathColumn        = "vcs_path"
+	versionColumn        = "version"
+	revisionColumn       = "revision"
+	descriptionColumn    = "description"
+	ownerColumn          = "owner"
+	defaultBranchColumn  = "default_branch"
+	docURLColumn         = "doc_url"
+	sourceTemplateColumn = "source_template"
+	visibilityColumn     = "visibility"
+	labelsColumn         = "labels"
+	createdColumn        = "created_at"
+	updatedColumn        = "updated_at"
 )
 
+// entryColumns are the columns of an entry, in the order they are decoded by
+// decodeEntry.
+var entryColumns = []string{
+	importPathColumn,
+	vcsColumn,
+	vcsPathColumn,
+	versionColumn,
+	revisionColumn,
+	descriptionColumn,
+	ownerColumn,
+	defaultBranchColumn,
+	docURLColumn,
+	sourceTemplateColumn,
+	visibilityColumn,
+	labelsColumn,
+	createdColumn,
+	updatedColumn,
+}
+
 type spannerClient struct {
-	table  string
-	client *spanner.Client
-	lock   sync.RWMutex
+	table        string
+	client       *spanner.Client
+	pollInterval time.Duration
+	lock         sync.RWMutex
 }
 
 // NewClient creates a client to a database. A valid database name has the
 // form projects/PROJECT_ID/instances/INSTANCE_ID/databases/DATABASE_ID.
-func NewClient(ctx context.Context, database string, opts ...BackendOption) (api vanity.Backend, err error) {
+func NewClient(ctx context.Context, database string, opts ...BackendOption) (api vanity.EntryBackend, err error) {
 	var dataClient *spanner.Client
 
 	s := collectSettings(opts...)
@@ -58,8 +93,9 @@ func NewClient(ctx context.Context, database string, opts ...BackendOption) (api
 	}
 
 	return &spannerClient{
-		table:  s.table,
-		client: dataClient,
+		table:        s.table,
+		client:       dataClient,
+		pollInterval: s.pollInterval,
 	}, nil
 }
 
@@ -90,47 +126,230 @@ func (s *spannerClient) Close() error {
 	return nil
 }
 
+// Watch polls the table for changes, using the updated_at column for
+// positions.
+func (s *spannerClient) Watch(ctx context.Context, position string) (<-chan vanity.Event, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	return vanity.Poll(ctx, s, s.pollInterval, position)
+}
+
 func (s *spannerClient) Healthz(ctx context.Context) error {
-	return s.List(ctx, vanity.ConsumerFunc(func(ctx context.Context, importPath, vcs, vcsPath string) {}))
+	_, err := s.ListPage(ctx, vanity.ListOptions{PageSize: 1}, vanity.EntryConsumerFunc(func(context.Context, *vanity.Entry) {}))
+
+	return err
 }
 
 func (s *spannerClient) Get(ctx context.Context, importPath string) (vcs, vcsPath string, err error) {
-	if err = s.checkClosed(); err != nil {
-		return
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *spannerClient) GetEntry(ctx context.Context, importPath string) (*vanity.Entry, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
 	}
 
-	row, err :=
-		s.client.Single().ReadRow(ctx, s.table, spanner.Key{importPath}, []string{vcsColumn, vcsPathColumn})
+	row, err := s.client.Single().ReadRow(ctx, s.table, spanner.Key{importPath}, entryColumns)
 	if err != nil {
 		if spanner.ErrCode(err) == codes.NotFound {
-			return "", "", vanity.ErrNotFound
+			return nil, vanity.ErrNotFound
 		}
-		return "", "", fmt.Errorf(unableToRetrieve, importPath, err)
+		return nil, fmt.Errorf(unableToRetrieve, importPath, err)
 	}
 
-	if err = row.ColumnByName(vcsColumn, &vcs); err != nil {
-		return "", "", fmt.Errorf(unableToExtractVcs, importPath, err)
+	return decodeEntry(row)
+}
+
+func (s *spannerClient) GetPrefix(ctx context.Context, path string) (*vanity.Entry, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
 	}
 
-	if err = row.ColumnByName(vcsPathColumn, &vcsPath); err != nil {
-		return "", "", fmt.Errorf(unableToExtractVcsPath, importPath, err)
+	var keys []spanner.KeySet
+	for _, candidate := range vanity.Prefixes(path) {
+		keys = append(keys, spanner.Key{candidate})
 	}
 
-	return vcs, vcsPath, err
+	iter := s.client.Single().Read(ctx, s.table, spanner.KeySets(keys...), entryColumns)
+	defer iter.Stop()
+
+	var longest *vanity.Entry
+
+	for {
+		row, err := iter.Next()
+
+		switch {
+		case err == iterator.Done:
+			if longest == nil {
+				return nil, vanity.ErrNotFound
+			}
+			return longest, nil
+		case err != nil:
+			return nil, fmt.Errorf(unableToRetrieve, path, err)
+		}
+
+		e, err := decodeEntry(row)
+		if err != nil {
+			return nil, err
+		}
+
+		if longest == nil || len(e.ImportPath) > len(longest.ImportPath) {
+			longest = e
+		}
+	}
 }
 
 func (s *spannerClient) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return s.InsertEntry(ctx, vanity.NewEntry(importPath, vcs, vcsPath))
+}
+
+func (s *spannerClient) InsertEntry(ctx context.Context, entry *vanity.Entry) error {
 	if err := s.checkClosed(); err != nil {
 		return err
 	}
 
+	columns, values, err := encodeEntry(entry, 1, true)
+	if err != nil {
+		return err
+	}
+
 	ms := []*spanner.Mutation{
-		spanner.Insert(
-			s.table,
-			[]string{importPathColumn, vcsColumn, vcsPathColumn},
-			[]interface{}{importPath, vcs, vcsPath}),
+		spanner.Insert(s.table, columns, values),
 	}
-	_, err := s.client.Apply(ctx, ms)
+	_, err = s.client.Apply(ctx, ms)
+	if spanner.ErrCode(err) == codes.AlreadyExists {
+		return vanity.ErrAlreadyExists
+	}
+
+	return err
+}
+
+func (s *spannerClient) UpdateEntry(ctx context.Context, entry *vanity.Entry, ifRevision int64) error {
+	return s.update(ctx, entry, ifRevision, false)
+}
+
+func (s *spannerClient) UpsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.update(ctx, entry, vanity.AnyRevision, true)
+}
+
+// update replaces the row of entry in a read-write transaction, checking its
+// revision unless ifRevision is AnyRevision.  If the row does not exist, it is
+// inserted if create is true.
+func (s *spannerClient) update(ctx context.Context, entry *vanity.Entry, ifRevision int64, create bool) error {
+	if err := s.checkClosed(); err != nil {
+		return err
+	}
+
+	_, err := s.client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		row, err := tx.ReadRow(ctx, s.table, spanner.Key{entry.ImportPath}, []string{revisionColumn})
+
+		var m *spanner.Mutation
+		switch {
+		case spanner.ErrCode(err) == codes.NotFound:
+			if !create {
+				return vanity.ErrNotFound
+			}
+			columns, values, err := encodeEntry(entry, 1, true)
+			if err != nil {
+				return err
+			}
+			m = spanner.Insert(s.table, columns, values)
+		case err != nil:
+			return fmt.Errorf(unableToRetrieve, entry.ImportPath, err)
+		default:
+			var revision spanner.NullInt64
+			if err := row.Columns(&revision); err != nil {
+				return fmt.Errorf(unableToExtractEntry, entry.ImportPath, err)
+			}
+			current := revisionOf(revision)
+			if ifRevision != vanity.AnyRevision && ifRevision != current {
+				return vanity.ErrConflict
+			}
+			columns, values, err := encodeEntry(entry, current+1, false)
+			if err != nil {
+				return err
+			}
+			m = spanner.Update(s.table, columns, values)
+		}
+
+		return tx.BufferWrite([]*spanner.Mutation{m})
+	})
+
+	return err
+}
+
+func (s *spannerClient) Apply(ctx context.Context, mutations []vanity.Mutation) error {
+	if err := s.checkClosed(); err != nil {
+		return err
+	}
+
+	var keys []spanner.KeySet
+	for _, m := range mutations {
+		if m.Entry != nil {
+			keys = append(keys, spanner.Key{m.Entry.ImportPath})
+		}
+	}
+
+	_, err := s.client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		entries := make(map[string]*vanity.Entry)
+		existed := make(map[string]bool)
+
+		iter := tx.Read(ctx, s.table, spanner.KeySets(keys...), entryColumns)
+		err := iter.Do(func(row *spanner.Row) error {
+			e, err := decodeEntry(row)
+			if err != nil {
+				return err
+			}
+			entries[e.ImportPath] = e
+			existed[e.ImportPath] = true
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := vanity.ApplyMutations(entries, mutations, time.Now().UTC()); err != nil {
+			return err
+		}
+
+		importPaths := make([]string, 0, len(entries))
+		for importPath := range entries {
+			importPaths = append(importPaths, importPath)
+		}
+		sort.Strings(importPaths)
+
+		ms := make([]*spanner.Mutation, 0, len(importPaths))
+		for _, importPath := range importPaths {
+			e := entries[importPath]
+
+			if e == nil {
+				if existed[importPath] {
+					ms = append(ms, spanner.Delete(s.table, spanner.Key{importPath}))
+				}
+				continue
+			}
+
+			columns, values, err := encodeEntry(e, e.Revision, !existed[importPath])
+			if err != nil {
+				return err
+			}
+			if existed[importPath] {
+				ms = append(ms, spanner.Update(s.table, columns, values))
+			} else {
+				ms = append(ms, spanner.Insert(s.table, columns, values))
+			}
+		}
+
+		return tx.BufferWrite(ms)
+	})
 
 	return err
 }
@@ -149,12 +368,17 @@ func (s *spannerClient) Remove(ctx context.Context, importPath string) error {
 }
 
 func (s *spannerClient) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+func (s *spannerClient) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
 	if err := s.checkClosed(); err != nil {
 		return err
 	}
 
-	stmt := spanner.Statement{SQL: s.sql()}
-	iter := s.client.Single().Query(ctx, stmt)
+	iter := s.client.Single().Read(ctx, s.table, spanner.AllKeys(), entryColumns)
 
 	defer iter.Stop()
 
@@ -174,16 +398,194 @@ func (s *spannerClient) List(ctx context.Context, consumer vanity.Consumer) erro
 			return err
 		}
 
-		var importPath, vcs, vcsPath string
-
-		if err := row.Columns(&importPath, &vcs, &vcsPath); err != nil {
+		e, err := decodeEntry(row)
+		if err != nil {
 			return err
 		}
 
-		consumer.OnEntry(ctx, importPath, vcs, vcsPath)
+		consumer.Consume(ctx, e)
+	}
+}
+
+// ListPage lists a page of entries with a query on the primary key, which is
+// served by a key range scan.
+func (s *spannerClient) ListPage(ctx context.Context, opts vanity.ListOptions, consumer vanity.EntryConsumer) (string, error) {
+	if err := s.checkClosed(); err != nil {
+		return "", err
+	}
+
+	after, err := vanity.DecodePageToken(opts.PageToken)
+	if err != nil {
+		return "", err
+	}
+
+	iter := s.client.Single().Query(ctx, s.pageStatement(opts, after))
+
+	defer iter.Stop()
+
+	var last string
+	for n := 0; ; n++ {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		row, err := iter.Next()
+
+		switch {
+		case err == iterator.Done:
+			return "", nil
+		case err != nil:
+			return "", err
+		}
+
+		if opts.PageSize > 0 && n == opts.PageSize {
+			// the extra row shows there is another page
+			return vanity.EncodePageToken(last), nil
+		}
+
+		e, err := decodeEntry(row)
+		if err != nil {
+			return "", err
+		}
+
+		consumer.Consume(ctx, e)
+		last = e.ImportPath
+	}
+}
+
+// pageStatement creates the query of a page of entries, which come after the
+// import path after.  One more row than the size of the page is selected to
+// determine whether there is another page.
+func (s *spannerClient) pageStatement(opts vanity.ListOptions, after string) spanner.Statement {
+	params := make(map[string]interface{})
+
+	var where []string
+	if opts.Prefix != "" {
+		where = append(where, fmt.Sprintf("STARTS_WITH(%s, @prefix)", importPathColumn))
+		params["prefix"] = opts.Prefix
+	}
+
+	order, cmp := "ASC", ">"
+	if opts.Order == vanity.Descending {
+		order, cmp = "DESC", "<"
+	}
+
+	if after != "" {
+		where = append(where, fmt.Sprintf("%s %s @after", importPathColumn, cmp))
+		params["after"] = after
+	}
+
+	sql := fmt.Sprintf("SELECT %s FROM %s", strings.Join(entryColumns, ", "), s.table)
+	if len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	sql += fmt.Sprintf(" ORDER BY %s %s", importPathColumn, order)
+	if opts.PageSize > 0 {
+		sql += " LIMIT @limit"
+		params["limit"] = int64(opts.PageSize + 1)
+	}
+
+	return spanner.Statement{SQL: sql, Params: params} // nolint:gosec
+}
+
+// encodeEntry encodes entry, at revision, as the columns and values of a
+// mutation.  The update time, and the creation time if created is true, are
+// set to the commit timestamp.
+func encodeEntry(entry *vanity.Entry, revision int64, created bool) ([]string, []interface{}, error) {
+	var labels spanner.NullString
+	if len(entry.Labels) > 0 {
+		b, err := json.Marshal(entry.Labels)
+		if err != nil {
+			return nil, nil, err
+		}
+		labels = spanner.NullString{StringVal: string(b), Valid: true}
+	}
+
+	version := entry.Version
+	if version == 0 {
+		version = vanity.EntryVersion
+	}
+
+	values := []interface{}{
+		entry.ImportPath,
+		entry.VCS,
+		entry.VCSPath,
+		int64(version),
+		revision,
+		nullString(entry.Description),
+		nullString(entry.Owner),
+		nullString(entry.DefaultBranch),
+		nullString(entry.DocURL),
+		nullString(entry.SourceTemplate),
+		nullString(string(entry.Visibility)),
+		labels,
+		spanner.CommitTimestamp,
+		spanner.CommitTimestamp,
+	}
+
+	if created {
+		return entryColumns, values, nil
+	}
+
+	// drop the creation time, which precedes the update time
+	n := len(entryColumns)
+	columns := append(append([]string{}, entryColumns[:n-2]...), entryColumns[n-1])
+	values = append(values[:n-2], values[n-1])
+
+	return columns, values, nil
+}
+
+// decodeEntry decodes a row of entryColumns.
+func decodeEntry(row *spanner.Row) (*vanity.Entry, error) {
+	var (
+		e                                                         vanity.Entry
+		version, revision                                         spanner.NullInt64
+		description, owner, defaultBranch, docURL, sourceTemplate spanner.NullString
+		visibility, labels                                        spanner.NullString
+		created, updated                                          spanner.NullTime
+	)
+
+	err := row.Columns(&e.ImportPath, &e.VCS, &e.VCSPath, &version, &revision,
+		&description, &owner, &defaultBranch, &docURL, &sourceTemplate,
+		&visibility, &labels, &created, &updated)
+	if err != nil {
+		return nil, fmt.Errorf(unableToExtractEntry, e.ImportPath, err)
+	}
+
+	e.Version = vanity.EntryVersion
+	if version.Valid {
+		e.Version = int(version.Int64)
+	}
+	e.Revision = revisionOf(revision)
+	e.Description = description.StringVal
+	e.Owner = owner.StringVal
+	e.DefaultBranch = defaultBranch.StringVal
+	e.DocURL = docURL.StringVal
+	e.SourceTemplate = sourceTemplate.StringVal
+	e.Visibility = vanity.Visibility(visibility.StringVal)
+	if labels.Valid && labels.StringVal != "" {
+		if err := json.Unmarshal([]byte(labels.StringVal), &e.Labels); err != nil {
+			return nil, fmt.Errorf(unableToExtractEntry, e.ImportPath, err)
+		}
+	}
+	e.Created = created.Time
+	e.Updated = updated.Time
+
+	return &e, nil
+}
+
+// revisionOf returns the revision of a row; rows stored before entries carried
+// revisions are at revision one.
+func revisionOf(revision spanner.NullInt64) int64 {
+	if !revision.Valid {
+		return 1
 	}
+
+	return revision.Int64
 }
 
-func (s *spannerClient) sql() string {
-	return fmt.Sprintf("SELECT %s, %s, %s FROM %s", importPathColumn, vcsColumn, vcsPathColumn, s.table) // nolint:gosec
+func nullString(s string) spanner.NullString {
+	return spanner.NullString{StringVal: s, Valid: s != ""}
 }
@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spanner
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+)
+
+func TestPageStatement(t *testing.T) {
+	s := &spannerClient{table: "urls"}
+	columns := "import_path, vcs, vcs_path, version, revision, description, owner, default_branch, doc_url, " +
+		"source_template, visibility, labels, created_at, updated_at"
+
+	stmt := s.pageStatement(vanity.ListOptions{}, "")
+	assert.Equal(t, "SELECT "+columns+" FROM urls ORDER BY import_path ASC", stmt.SQL)
+	assert.Empty(t, stmt.Params)
+
+	stmt = s.pageStatement(vanity.ListOptions{Prefix: "a.com/", PageSize: 10, Order: vanity.Descending}, "a.com/m")
+	assert.Equal(t, "SELECT "+columns+" FROM urls WHERE STARTS_WITH(import_path, @prefix) AND import_path < @after "+
+		"ORDER BY import_path DESC LIMIT @limit", stmt.SQL)
+	assert.Equal(t, map[string]interface{}{"prefix": "a.com/", "after": "a.com/m", "limit": int64(11)}, stmt.Params)
+}
+
+func TestParseDSN(t *testing.T) {
+	u, _ := url.Parse("spanner://projects/p/instances/i/databases/d?table=vanity&poll-interval=1m")
+	database, opts, err := parseDSN(u)
+	assert.NoError(t, err)
+	assert.Equal(t, "projects/p/instances/i/databases/d", database)
+
+	s := collectSettings(opts...)
+	assert.Equal(t, "vanity", s.table)
+	assert.Equal(t, time.Minute, s.pollInterval)
+
+	u, _ = url.Parse("spanner://instances/i/databases/d")
+	_, _, err = parseDSN(u)
+	assert.Equal(t, vanity.ErrInvalidDSN, err)
+
+	u, _ = url.Parse("spanner://projects/p/instances/i/databases/d?poll-interval=often")
+	_, _, err = parseDSN(u)
+	assert.Equal(t, vanity.ErrInvalidDSN, err)
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package readonly
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"l7e.io/vanity"
+)
+
+var (
+	// ErrTableDoesNotExist is returned when the tables do not lead to the
+	// entries.
+	ErrTableDoesNotExist = fmt.Errorf("table does not exist")
+
+	// ErrImportPathNotSpecified is returned for entries without an import path.
+	ErrImportPathNotSpecified = fmt.Errorf("import_path not specified")
+
+	// ErrVcsNotSpecified is returned for entries without a VCS.
+	ErrVcsNotSpecified = fmt.Errorf("vcs not specified")
+
+	// ErrVcsPathNotSpecified is returned for entries without a VCS root.
+	ErrVcsPathNotSpecified = fmt.Errorf("vcs_path not specified")
+
+	// ErrInvalidVisibility is returned for entries with an unknown visibility.
+	ErrInvalidVisibility = fmt.Errorf("invalid visibility")
+)
+
+// An Entry is an entry as it is decoded from a document.
+type Entry struct {
+	ImportPath     string            `yaml:"import_path" json:"import_path"`
+	Vcs            string            `yaml:"vcs" json:"vcs"`
+	VcsPath        string            `yaml:"vcs_path" json:"vcs_path"`
+	Repo           string            `yaml:"repo" json:"repo"`
+	Description    string            `yaml:"description" json:"description"`
+	Owner          string            `yaml:"owner" json:"owner"`
+	DefaultBranch  string            `yaml:"default_branch" json:"default_branch"`
+	DocURL         string            `yaml:"doc_url" json:"doc_url"`
+	SourceTemplate string            `yaml:"source_template" json:"source_template"`
+	Visibility     string            `yaml:"visibility" json:"visibility"`
+	Labels         map[string]string `yaml:"labels" json:"labels"`
+	Created        time.Time         `yaml:"created" json:"created"`
+	Updated        time.Time         `yaml:"updated" json:"updated"`
+}
+
+// ToEntry returns the vanity entry of the decoded entry.
+func (e *Entry) ToEntry() *vanity.Entry {
+	return &vanity.Entry{
+		Version:        vanity.EntryVersion,
+		Revision:       1,
+		ImportPath:     e.ImportPath,
+		VCS:            e.Vcs,
+		VCSPath:        e.VcsPath,
+		Description:    e.Description,
+		Owner:          e.Owner,
+		DefaultBranch:  e.DefaultBranch,
+		DocURL:         e.DocURL,
+		SourceTemplate: e.SourceTemplate,
+		Visibility:     vanity.Visibility(e.Visibility),
+		Labels:         e.Labels,
+		Created:        e.Created,
+		Updated:        e.Updated,
+	}
+}
+
+// Validate checks the entry, after taking its VCS root from repo, as
+// govanityurls does, if unspecified.
+func (e *Entry) Validate() error {
+	if e.VcsPath == "" {
+		e.VcsPath = e.Repo
+	}
+
+	if e.ImportPath == "" {
+		return ErrImportPathNotSpecified
+	}
+	if e.Vcs == "" {
+		return ErrVcsNotSpecified
+	}
+	if e.VcsPath == "" {
+		return ErrVcsPathNotSpecified
+	}
+	if !vanity.Visibility(e.Visibility).IsValid() {
+		return ErrInvalidVisibility
+	}
+
+	return nil
+}
+
+// Keyed completes an entry keyed by import path, as in the paths of
+// govanityurls configurations: keys starting with a slash are relative to
+// host, and the vcs of entries whose repo is on GitHub or Bitbucket defaults
+// to git.
+func (e *Entry) Keyed(host, key string) {
+	e.ImportPath = importPath(host, key)
+	if e.Vcs == "" {
+		e.Vcs = inferVcs(e.Repo)
+	}
+}
+
+// importPath returns the import path of a mapping key, which is relative to
+// host if it starts with a slash; it is empty if host is.
+func importPath(host, key string) string {
+	key = strings.TrimSuffix(key, "/")
+	if !strings.HasPrefix(key, "/") {
+		return key
+	}
+	if host == "" {
+		return ""
+	}
+
+	return strings.TrimSuffix(host, "/") + key
+}
+
+// inferVcs returns the version control system of repo the way govanityurls
+// does, or an empty string if it cannot be inferred.
+func inferVcs(repo string) string {
+	if strings.HasPrefix(repo, "https://github.com/") || strings.HasPrefix(repo, "https://bitbucket.org/") {
+		return "git"
+	}
+
+	return ""
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package readonly
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Settings holds the content of a document and the tables leading to its
+// entries.
+type Settings struct {
+	Tables []string
+	Path   string
+	Reader io.Reader
+	String string
+	Bytes  []byte
+}
+
+// An Option is an option for a read-only backend.
+type Option interface {
+	Apply(*Settings)
+}
+
+var (
+	// ErrNoContentSpecified is returned when none of the content options is
+	// specified.
+	ErrNoContentSpecified = fmt.Errorf("no content specified")
+)
+
+// InTable is used to specify where the entries can be found, by the keys
+// leading to them from the root of the document, in order.
+func InTable(tables ...string) Option {
+	return tablesOption{tables: tables}
+}
+
+type tablesOption struct{ tables []string }
+
+func (t tablesOption) Apply(o *Settings) {
+	o.Tables = t.tables
+}
+
+// FromFile is used to specify the path of the document.
+func FromFile(path string) Option {
+	return fileOption{path: path}
+}
+
+type fileOption struct{ path string }
+
+func (f fileOption) Apply(o *Settings) {
+	o.Path = f.path
+}
+
+// FromReader is used to specify a Reader that contains the document.
+func FromReader(r io.Reader) Option {
+	return readerOption{reader: r}
+}
+
+type readerOption struct{ reader io.Reader }
+
+func (r readerOption) Apply(o *Settings) {
+	o.Reader = r.reader
+}
+
+// FromString is used to specify the document as a string.
+func FromString(s string) Option {
+	return stringOption{string: s}
+}
+
+type stringOption struct{ string string }
+
+func (s stringOption) Apply(o *Settings) {
+	o.String = s.string
+}
+
+// FromBytes is used to specify the document as bytes.
+func FromBytes(b []byte) Option {
+	return bytesOption{bytes: b}
+}
+
+type bytesOption struct{ bytes []byte }
+
+func (b bytesOption) Apply(o *Settings) {
+	o.Bytes = b.bytes
+}
+
+// read returns the content of the document, from the first of the bytes,
+// string, file or Reader that is specified.
+func (s *Settings) read() ([]byte, error) {
+	switch {
+	case s.Bytes != nil:
+		return s.Bytes, nil
+	case s.String != "":
+		return []byte(s.String), nil
+	case s.Path != "":
+		file, err := os.Open(s.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = file.Close() }()
+		return ioutil.ReadAll(file)
+	case s.Reader != nil:
+		return ioutil.ReadAll(s.Reader)
+	default:
+		return nil, ErrNoContentSpecified
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package readonly contains the read-only, in-memory, store shared by the
+// backends initialized from YAML and JSON documents.
+package readonly
+
+import (
+	"context"
+	"sync"
+
+	"l7e.io/vanity"
+)
+
+// A Parser extracts and validates the entries of the document at tables.
+type Parser func(b []byte, tables []string) (map[string]*vanity.Entry, error)
+
+type backend struct {
+	lock    sync.RWMutex
+	entries map[string]*vanity.Entry
+	closed  bool
+}
+
+// NewBackend creates a new, read-only, backend of the entries parsed from the
+// content specified by the options.
+func NewBackend(parse Parser, options ...Option) (vanity.EntryBackend, error) {
+	s := Settings{Tables: []string{}}
+	for _, o := range options {
+		o.Apply(&s)
+	}
+
+	b, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := parse(b, s.Tables)
+	if err != nil {
+		return nil, err
+	}
+
+	return &backend{entries: entries}, nil
+}
+
+func (s *backend) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	s.entries = nil
+
+	return nil
+}
+
+// snapshot returns the current entries, which must not be modified.
+func (s *backend) snapshot() (map[string]*vanity.Entry, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return nil, vanity.ErrAlreadyClosed
+	}
+	return s.entries, nil
+}
+
+func (s *backend) Get(ctx context.Context, importPath string) (string, string, error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *backend) GetEntry(_ context.Context, importPath string) (*vanity.Entry, error) {
+	entries, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	e, found := entries[importPath]
+	if !found {
+		return nil, vanity.ErrNotFound
+	}
+	return e.Clone(), nil
+}
+
+func (s *backend) GetPrefix(_ context.Context, path string) (*vanity.Entry, error) {
+	entries, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, importPath := range vanity.Prefixes(path) {
+		if e, found := entries[importPath]; found {
+			return e.Clone(), nil
+		}
+	}
+	return nil, vanity.ErrNotFound
+}
+
+func (s *backend) Add(_ context.Context, importPath, vcs, vcsPath string) error {
+	return vanity.ErrNotSupported
+}
+
+func (s *backend) InsertEntry(_ context.Context, entry *vanity.Entry) error {
+	return vanity.ErrNotSupported
+}
+
+func (s *backend) UpdateEntry(_ context.Context, entry *vanity.Entry, ifRevision int64) error {
+	return vanity.ErrNotSupported
+}
+
+func (s *backend) UpsertEntry(_ context.Context, entry *vanity.Entry) error {
+	return vanity.ErrNotSupported
+}
+
+func (s *backend) Remove(_ context.Context, importPath string) error {
+	return vanity.ErrNotSupported
+}
+
+func (s *backend) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+func (s *backend) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	entries, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		consumer.Consume(ctx, e.Clone())
+	}
+
+	return nil
+}
+
+func (s *backend) ListPage(ctx context.Context, opts vanity.ListOptions, consumer vanity.EntryConsumer) (string, error) {
+	entries, err := s.snapshot()
+	if err != nil {
+		return "", err
+	}
+
+	c := make([]*vanity.Entry, 0, len(entries))
+	for _, v := range entries {
+		c = append(c, v)
+	}
+
+	page, next, err := vanity.PageEntries(c, opts)
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range page {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		consumer.Consume(ctx, e.Clone())
+	}
+
+	return next, nil
+}
+
+// Healthz is always healthy, as the entries are read once.
+func (s *backend) Healthz(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,280 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package readonly
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"l7e.io/vanity"
+)
+
+const document = "l7e.io/one l7e.io/two l7e.io/three"
+
+// parseFields is a Parser of the import paths of GitHub repositories, separated
+// by white space.
+func parseFields(b []byte, tables []string) (map[string]*vanity.Entry, error) {
+	if len(tables) > 0 {
+		return nil, ErrTableDoesNotExist
+	}
+
+	entries := make(map[string]*vanity.Entry)
+	for _, f := range strings.Fields(string(b)) {
+		e := &Entry{Repo: "https://github.com/livetribe/" + strings.TrimPrefix(f, "l7e.io/")}
+		e.Keyed("l7e.io", strings.TrimPrefix(f, "l7e.io"))
+		if err := e.Validate(); err != nil {
+			return nil, err
+		}
+		entries[e.ImportPath] = e.ToEntry()
+	}
+
+	return entries, nil
+}
+
+func TestReadOnlyConstructionOptions(t *testing.T) {
+	Convey("Test construction options", t, func() {
+		Convey("Ensure InTable correctly fills settings", func() {
+			var s Settings
+			InTable("one", "two", "three").Apply(&s)
+
+			So(s.Tables, ShouldResemble, []string{"one", "two", "three"})
+		})
+
+		Convey("Ensure FromFile correctly fills settings", func() {
+			var s Settings
+			FromFile(".config/vanity/vanity.yaml").Apply(&s)
+
+			So(s.Path, ShouldEqual, ".config/vanity/vanity.yaml")
+		})
+
+		Convey("Ensure FromReader correctly fills settings", func() {
+			r := strings.NewReader(document)
+
+			var s Settings
+			FromReader(r).Apply(&s)
+
+			So(s.Reader, ShouldEqual, r)
+		})
+
+		Convey("Ensure FromString correctly fills settings", func() {
+			var s Settings
+			FromString(document).Apply(&s)
+
+			So(s.String, ShouldEqual, document)
+		})
+
+		Convey("Ensure FromBytes correctly fills settings", func() {
+			var s Settings
+			FromBytes([]byte(document)).Apply(&s)
+
+			So(s.Bytes, ShouldResemble, []byte(document))
+		})
+	})
+}
+
+func TestReadOnlyConstruction(t *testing.T) {
+	Convey("Test read-only backend construction", t, func() {
+		Convey("Construction with a file", func() {
+			f, err := ioutil.TempFile("", "vanity-*.txt")
+			So(err, ShouldBeNil)
+			defer func() { _ = os.Remove(f.Name()) }()
+
+			_, err = f.WriteString(document)
+			So(err, ShouldBeNil)
+			So(f.Close(), ShouldBeNil)
+
+			be, err := NewBackend(parseFields, FromFile(f.Name()))
+			So(err, ShouldBeNil)
+
+			verify(be)
+		})
+
+		Convey("Construction with a missing file", func() {
+			_, err := NewBackend(parseFields, FromFile("/does/not/exist"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("Construction with Reader", func() {
+			be, err := NewBackend(parseFields, FromReader(strings.NewReader(document)))
+			So(err, ShouldBeNil)
+
+			verify(be)
+		})
+
+		Convey("Construction with string", func() {
+			be, err := NewBackend(parseFields, FromString(document))
+			So(err, ShouldBeNil)
+
+			verify(be)
+		})
+
+		Convey("Construction with bytes", func() {
+			be, err := NewBackend(parseFields, FromBytes([]byte(document)))
+			So(err, ShouldBeNil)
+
+			verify(be)
+		})
+
+		Convey("Construction with a parse error", func() {
+			be, err := NewBackend(parseFields, InTable("obj"), FromString(document))
+			So(err, ShouldEqual, ErrTableDoesNotExist)
+			So(be, ShouldBeNil)
+		})
+
+		Convey("Construction with no content", func() {
+			be, err := NewBackend(parseFields, InTable("obj"))
+			So(err, ShouldEqual, ErrNoContentSpecified)
+			So(be, ShouldBeNil)
+		})
+	})
+}
+
+func TestReadOnlyEntry(t *testing.T) {
+	Convey("Test decoded entries", t, func() {
+		Convey("Ensure repo is the default VCS root", func() {
+			e := &Entry{ImportPath: "l7e.io/one", Vcs: "git", Repo: "https://github.com/livetribe/one"}
+			So(e.Validate(), ShouldBeNil)
+			So(e.VcsPath, ShouldEqual, "https://github.com/livetribe/one")
+		})
+
+		Convey("Ensure invalid entries are rejected", func() {
+			So((&Entry{Vcs: "git", VcsPath: "https://github.com/livetribe/one"}).Validate(), ShouldEqual, ErrImportPathNotSpecified)
+			So((&Entry{ImportPath: "l7e.io/one", VcsPath: "https://github.com/livetribe/one"}).Validate(), ShouldEqual, ErrVcsNotSpecified)
+			So((&Entry{ImportPath: "l7e.io/one", Vcs: "git"}).Validate(), ShouldEqual, ErrVcsPathNotSpecified)
+			So((&Entry{ImportPath: "l7e.io/one", Vcs: "git", VcsPath: "https://github.com/livetribe/one", Visibility: "secret"}).Validate(), ShouldEqual, ErrInvalidVisibility)
+		})
+
+		Convey("Ensure keys are completed as in govanityurls", func() {
+			e := &Entry{Repo: "https://bitbucket.org/livetribe/one"}
+			e.Keyed("l7e.io/", "/one/")
+			So(e.ImportPath, ShouldEqual, "l7e.io/one")
+			So(e.Vcs, ShouldEqual, "git")
+
+			e = &Entry{Repo: "https://example.com/livetribe/one"}
+			e.Keyed("", "/one")
+			So(e.ImportPath, ShouldBeEmpty)
+			So(e.Vcs, ShouldBeEmpty)
+
+			e = &Entry{Vcs: "hg", Repo: "https://github.com/livetribe/one"}
+			e.Keyed("l7e.io", "example.com/one")
+			So(e.ImportPath, ShouldEqual, "example.com/one")
+			So(e.Vcs, ShouldEqual, "hg")
+		})
+	})
+}
+
+func TestReadOnlyBackend(t *testing.T) {
+	Convey("Test read-only backend methods", t, func() {
+		be, err := NewBackend(parseFields, FromString(document+" l7e.io/four"))
+		So(err, ShouldBeNil)
+
+		Convey("Changes should not be supported", func() {
+			So(be.Add(context.Background(), "l7e.io/five", "git", "https://github.com/livetribe/five"), ShouldEqual, vanity.ErrNotSupported)
+			So(be.InsertEntry(context.Background(), vanity.NewEntry("l7e.io/five", "git", "https://github.com/livetribe/five")), ShouldEqual, vanity.ErrNotSupported)
+			So(be.UpdateEntry(context.Background(), vanity.NewEntry("l7e.io/one", "git", "https://github.com/livetribe/one"), vanity.AnyRevision), ShouldEqual, vanity.ErrNotSupported)
+			So(be.UpsertEntry(context.Background(), vanity.NewEntry("l7e.io/one", "git", "https://github.com/livetribe/one")), ShouldEqual, vanity.ErrNotSupported)
+			So(be.Remove(context.Background(), "l7e.io/one"), ShouldEqual, vanity.ErrNotSupported)
+		})
+
+		Convey("Test GetEntry", func() {
+			e, err := be.GetEntry(context.Background(), "l7e.io/four")
+			So(err, ShouldBeNil)
+			So(e.Revision, ShouldEqual, 1)
+			So(e.VCSPath, ShouldEqual, "https://github.com/livetribe/four")
+
+			e.VCSPath = "https://github.com/livetribe/five"
+			e, err = be.GetEntry(context.Background(), "l7e.io/four")
+			So(err, ShouldBeNil)
+			So(e.VCSPath, ShouldEqual, "https://github.com/livetribe/four")
+
+			_, err = be.GetEntry(context.Background(), "l7e.io/five")
+			So(err, ShouldEqual, vanity.ErrNotFound)
+		})
+
+		Convey("Test GetPrefix", func() {
+			pg, ok := be.(vanity.PrefixGetter)
+			So(ok, ShouldBeTrue)
+
+			e, err := pg.GetPrefix(context.Background(), "l7e.io/two/cmd/two")
+			So(err, ShouldBeNil)
+			So(e.ImportPath, ShouldEqual, "l7e.io/two")
+
+			_, err = pg.GetPrefix(context.Background(), "l7e.io/five/cmd")
+			So(err, ShouldEqual, vanity.ErrNotFound)
+		})
+
+		Convey("Test List", func() {
+			var listed []string
+			err := be.List(context.Background(), vanity.ConsumerFunc(func(_ context.Context, importPath, _, _ string) {
+				listed = append(listed, importPath)
+			}))
+			So(err, ShouldBeNil)
+			So(listed, ShouldHaveLength, 4)
+		})
+
+		Convey("Test ListPage", func() {
+			p, ok := be.(vanity.Pager)
+			So(ok, ShouldBeTrue)
+
+			var listed []string
+			next, err := p.ListPage(context.Background(), vanity.ListOptions{PageSize: 3},
+				vanity.EntryConsumerFunc(func(_ context.Context, e *vanity.Entry) {
+					listed = append(listed, e.ImportPath)
+				}))
+			So(err, ShouldBeNil)
+			So(next, ShouldNotBeEmpty)
+			So(listed, ShouldResemble, []string{"l7e.io/four", "l7e.io/one", "l7e.io/three"})
+		})
+
+		Convey("Ensure always healthy", func() {
+			So(be.Healthz(context.Background()), ShouldBeNil)
+		})
+
+		Convey("Test Close", func() {
+			So(be.Close(), ShouldBeNil)
+			So(be.Close(), ShouldBeNil)
+
+			_, _, err = be.Get(context.Background(), "l7e.io/one")
+			So(err, ShouldEqual, vanity.ErrAlreadyClosed)
+
+			err = be.List(context.Background(), vanity.ConsumerFunc(func(_ context.Context, _, _, _ string) {}))
+			So(err, ShouldEqual, vanity.ErrAlreadyClosed)
+		})
+	})
+}
+
+func verify(be vanity.Backend) {
+	vcs, vcsPath, err := be.Get(context.Background(), "l7e.io/one")
+	So(err, ShouldBeNil)
+	So(vcs, ShouldResemble, "git")
+	So(vcsPath, ShouldResemble, "https://github.com/livetribe/one")
+
+	vcs, vcsPath, err = be.Get(context.Background(), "l7e.io/two")
+	So(err, ShouldBeNil)
+	So(vcs, ShouldResemble, "git")
+	So(vcsPath, ShouldResemble, "https://github.com/livetribe/two")
+
+	vcs, vcsPath, err = be.Get(context.Background(), "l7e.io/three")
+	So(err, ShouldBeNil)
+	So(vcs, ShouldResemble, "git")
+	So(vcsPath, ShouldResemble, "https://github.com/livetribe/three")
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package json
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/pkg/internal/readonly"
+)
+
+func init() { //nolint:gochecknoinits
+	vanity.RegisterBackend("json", openDSN)
+}
+
+// openDSN creates a JSON-based Backend from a data source name such as
+// "json:///etc/vanity/vanity.json?table=paths"; dotted table names are split
+// into their tokens, and the entries are at the root of the document if no
+// table is specified.
+func openDSN(_ context.Context, dsn *url.URL) (vanity.Backend, error) {
+	path := dsn.Opaque
+	if path == "" {
+		path = dsn.Host + dsn.Path
+	}
+	if path == "" {
+		return nil, vanity.ErrInvalidDSN
+	}
+
+	options := []Option{FromFile(path)}
+	if table := dsn.Query().Get("table"); table != "" {
+		options = append(options, InTable(strings.Split(table, ".")...))
+	}
+
+	return NewJSONBackend(options...)
+}
+
+// An Option is an option for a JSON-based Backend.
+type Option = readonly.Option
+
+// InTable is used to specify the object the configuration can be found,
+// by the keys leading to it from the root of the document, in order.
+func InTable(tables ...string) Option {
+	return readonly.InTable(tables...)
+}
+
+// FromFile is used to specify the path of the JSON configuration file.
+func FromFile(path string) Option {
+	return readonly.FromFile(path)
+}
+
+// FromReader is used to specify a Reader that contains the JSON contents of the configuration.
+func FromReader(r io.Reader) Option {
+	return readonly.FromReader(r)
+}
+
+// FromString is used to specify the string JSON contents of the configuration.
+func FromString(s string) Option {
+	return readonly.FromString(s)
+}
+
+// FromBytes is used to specify the byte JSON contents of the configuration.
+func FromBytes(b []byte) Option {
+	return readonly.FromBytes(b)
+}
+
+// NewJSONBackend creates a new, read-only, JSON-backend using the specified
+// options.
+func NewJSONBackend(options ...Option) (vanity.EntryBackend, error) {
+	return readonly.NewBackend(parse, options...)
+}
+
+// parse extracts and validates the entries of the array, or object, at
+// tables.
+//
+// The keys of objects are the import paths of their entries, as in the paths
+// of govanityurls configurations, relative to the host at the root of the
+// document.
+func parse(b []byte, tables []string) (map[string]*vanity.Entry, error) {
+	var root json.RawMessage
+	if err := json.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+
+	node := root
+	for _, t := range tables {
+		if node = child(node, t); node == nil {
+			return nil, readonly.ErrTableDoesNotExist
+		}
+	}
+
+	entries := make(map[string]*vanity.Entry)
+	switch kind(node) {
+	case '[':
+		var array []*readonly.Entry
+		if err := json.Unmarshal(node, &array); err != nil {
+			return nil, err
+		}
+
+		for _, e := range array {
+			if e == nil {
+				e = &readonly.Entry{}
+			}
+			if err := e.Validate(); err != nil {
+				return nil, err
+			}
+			entries[e.ImportPath] = e.ToEntry()
+		}
+	case '{':
+		var object map[string]*readonly.Entry
+		if err := json.Unmarshal(node, &object); err != nil {
+			return nil, err
+		}
+
+		var host string
+		if h := child(root, "host"); h != nil {
+			_ = json.Unmarshal(h, &host)
+		}
+
+		for key, e := range object {
+			if e == nil {
+				e = &readonly.Entry{}
+			}
+			e.Keyed(host, key)
+			if err := e.Validate(); err != nil {
+				return nil, err
+			}
+			entries[e.ImportPath] = e.ToEntry()
+		}
+	default:
+		return nil, readonly.ErrTableDoesNotExist
+	}
+
+	return entries, nil
+}
+
+// child returns the value of key in the object, or nil if the value is not
+// an object or has no such key.
+func child(value json.RawMessage, key string) json.RawMessage {
+	if kind(value) != '{' {
+		return nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(value, &object); err != nil {
+		return nil
+	}
+
+	return object[key]
+}
+
+// kind returns the first character of the value, which identifies its type.
+func kind(value json.RawMessage) byte {
+	value = bytes.TrimLeft(value, " \t\r\n")
+	if len(value) == 0 {
+		return 0
+	}
+
+	return value[0]
+}
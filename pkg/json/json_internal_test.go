@@ -0,0 +1,235 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package json
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/pkg/internal/readonly"
+)
+
+const entries = `{
+  "obj": [
+    {"import_path": "l7e.io/one", "vcs": "git", "vcs_path": "https://github.com/livetribe/one"},
+    {"import_path": "l7e.io/two", "vcs": "git", "vcs_path": "https://github.com/livetribe/two"},
+    {"import_path": "l7e.io/three", "vcs": "git", "vcs_path": "https://github.com/livetribe/three"}
+  ]
+}`
+
+func TestJsonConstruction(t *testing.T) {
+	Convey("Test JSON backend construction", t, func() {
+		Convey("Construction with a file", func() {
+			f, err := ioutil.TempFile("", "vanity-*.json")
+			So(err, ShouldBeNil)
+			defer func() { _ = os.Remove(f.Name()) }()
+
+			_, err = f.WriteString(entries)
+			So(err, ShouldBeNil)
+			So(f.Close(), ShouldBeNil)
+
+			be, err := NewJSONBackend(InTable("obj"), FromFile(f.Name()))
+			So(err, ShouldBeNil)
+
+			verify(be)
+		})
+
+		Convey("Construction with Reader", func() {
+			be, err := NewJSONBackend(InTable("obj"), FromReader(strings.NewReader(entries)))
+			So(err, ShouldBeNil)
+
+			verify(be)
+		})
+
+		Convey("Construction with bytes", func() {
+			be, err := NewJSONBackend(InTable("obj"), FromBytes([]byte(entries)))
+			So(err, ShouldBeNil)
+
+			verify(be)
+		})
+
+		Convey("Construction with no path", func() {
+			be, err := NewJSONBackend(FromString(`[
+  {"import_path": "l7e.io/one", "vcs": "git", "vcs_path": "https://github.com/livetribe/one"},
+  {"import_path": "l7e.io/two", "vcs": "git", "vcs_path": "https://github.com/livetribe/two"},
+  {"import_path": "l7e.io/three", "vcs": "git", "vcs_path": "https://github.com/livetribe/three"}
+]`))
+			So(err, ShouldBeNil)
+
+			verify(be)
+		})
+
+		Convey("Construction with nested path", func() {
+			be, err := NewJSONBackend(InTable("a", "b", "obj"), FromString(`{"a": {"b": `+entries+`}}`))
+			So(err, ShouldBeNil)
+
+			verify(be)
+		})
+
+		Convey("Construction with govanityurls paths", func() {
+			be, err := NewJSONBackend(InTable("paths"), FromString(`{
+  "host": "l7e.io",
+  "cache_max_age": 3600,
+  "paths": {
+    "/one": {"repo": "https://github.com/livetribe/one"},
+    "/two/": {"repo": "https://github.com/livetribe/two", "display": "https://github.com/livetribe/two"},
+    "l7e.io/three": {"vcs": "git", "vcs_path": "https://github.com/livetribe/three"}
+  }
+}`))
+			So(err, ShouldBeNil)
+
+			verify(be)
+		})
+
+		Convey("Construction with bad JSON", func() {
+			be, err := NewJSONBackend(InTable("obj"), FromString(`How now brown cow`))
+			So(err, ShouldNotBeNil)
+			So(be, ShouldBeNil)
+		})
+
+		Convey("Construction with bad entry", func() {
+			Convey("missing import_path", func() {
+				_, err := NewJSONBackend(FromString(`[{"vcs": "git", "vcs_path": "https://github.com/livetribe/one"}]`))
+				So(err, ShouldEqual, readonly.ErrImportPathNotSpecified)
+			})
+
+			Convey("missing vcs", func() {
+				_, err := NewJSONBackend(FromString(`[{"import_path": "l7e.io/one", "vcs_path": "https://github.com/livetribe/one"}]`))
+				So(err, ShouldEqual, readonly.ErrVcsNotSpecified)
+			})
+
+			Convey("missing vcs_path", func() {
+				_, err := NewJSONBackend(FromString(`[{"import_path": "l7e.io/one", "vcs": "git"}]`))
+				So(err, ShouldEqual, readonly.ErrVcsPathNotSpecified)
+			})
+
+			Convey("invalid visibility", func() {
+				_, err := NewJSONBackend(FromString(`[{"import_path": "l7e.io/one", "vcs": "git", "vcs_path": "https://github.com/livetribe/one", "visibility": "secret"}]`))
+				So(err, ShouldEqual, readonly.ErrInvalidVisibility)
+			})
+
+			Convey("relative path without host", func() {
+				_, err := NewJSONBackend(FromString(`{"/one": {"repo": "https://github.com/livetribe/one"}}`))
+				So(err, ShouldEqual, readonly.ErrImportPathNotSpecified)
+			})
+
+			Convey("uninferable vcs", func() {
+				_, err := NewJSONBackend(InTable("paths"), FromString(`{"host": "l7e.io", "paths": {"/one": {"repo": "https://example.com/livetribe/one"}}}`))
+				So(err, ShouldEqual, readonly.ErrVcsNotSpecified)
+			})
+		})
+
+		Convey("Construction with bad nested path", func() {
+			_, err := NewJSONBackend(InTable("x", "y", "obj"), FromString(entries))
+			So(err, ShouldEqual, readonly.ErrTableDoesNotExist)
+
+			_, err = NewJSONBackend(InTable("obj", "wrong"), FromString(entries))
+			So(err, ShouldEqual, readonly.ErrTableDoesNotExist)
+
+			_, err = NewJSONBackend(FromString(`"scalar"`))
+			So(err, ShouldEqual, readonly.ErrTableDoesNotExist)
+		})
+
+		Convey("Construction with no content", func() {
+			be, err := NewJSONBackend(InTable("obj"))
+			So(err, ShouldEqual, readonly.ErrNoContentSpecified)
+			So(be, ShouldBeNil)
+		})
+	})
+}
+
+func TestJsonFields(t *testing.T) {
+	Convey("Test JSON entry fields", t, func() {
+		be, err := NewJSONBackend(FromString(`[
+  {"import_path": "l7e.io/one", "vcs": "git", "vcs_path": "https://github.com/livetribe/one"},
+  {"import_path": "l7e.io/two", "vcs": "git", "vcs_path": "https://github.com/livetribe/two"},
+  {"import_path": "l7e.io/three", "vcs": "git", "vcs_path": "https://github.com/livetribe/three"},
+  {
+    "import_path": "l7e.io/four",
+    "vcs": "git",
+    "vcs_path": "https://github.com/livetribe/four",
+    "description": "The fourth",
+    "visibility": "internal",
+    "labels": {"team": "core"},
+    "created": "2020-06-01T12:00:00Z"
+  }
+]`))
+		So(err, ShouldBeNil)
+
+		e, err := be.GetEntry(context.Background(), "l7e.io/four")
+		So(err, ShouldBeNil)
+		So(e.Revision, ShouldEqual, 1)
+		So(e.Description, ShouldEqual, "The fourth")
+		So(e.Visibility, ShouldEqual, vanity.VisibilityInternal)
+		So(e.Labels, ShouldResemble, map[string]string{"team": "core"})
+		So(e.Created.Year(), ShouldEqual, 2020)
+
+		_, err = be.GetEntry(context.Background(), "l7e.io/five")
+		So(err, ShouldEqual, vanity.ErrNotFound)
+	})
+}
+
+func TestJsonOpen(t *testing.T) {
+	Convey("Test opening JSON backends by data source name", t, func() {
+		f, err := ioutil.TempFile("", "vanity-*.json")
+		So(err, ShouldBeNil)
+		defer func() { _ = os.Remove(f.Name()) }()
+
+		_, err = f.WriteString(`{"vanity": {"urls": [
+  {"import_path": "l7e.io/one", "vcs": "git", "vcs_path": "https://github.com/livetribe/one"}
+]}}`)
+		So(err, ShouldBeNil)
+		So(f.Close(), ShouldBeNil)
+
+		be, err := vanity.Open(context.Background(), "json://"+f.Name()+"?table=vanity.urls")
+		So(err, ShouldBeNil)
+
+		vcs, vcsPath, err := be.Get(context.Background(), "l7e.io/one")
+		So(err, ShouldBeNil)
+		So(vcs, ShouldEqual, "git")
+		So(vcsPath, ShouldEqual, "https://github.com/livetribe/one")
+
+		_, err = vanity.Open(context.Background(), "json://"+f.Name()+"?table=vanity.paths")
+		So(err, ShouldEqual, readonly.ErrTableDoesNotExist)
+
+		_, err = vanity.Open(context.Background(), "json:")
+		So(err, ShouldEqual, vanity.ErrInvalidDSN)
+	})
+}
+
+func verify(be vanity.Backend) {
+	vcs, vcsPath, err := be.Get(context.Background(), "l7e.io/one")
+	So(err, ShouldBeNil)
+	So(vcs, ShouldResemble, "git")
+	So(vcsPath, ShouldResemble, "https://github.com/livetribe/one")
+
+	vcs, vcsPath, err = be.Get(context.Background(), "l7e.io/two")
+	So(err, ShouldBeNil)
+	So(vcs, ShouldResemble, "git")
+	So(vcsPath, ShouldResemble, "https://github.com/livetribe/two")
+
+	vcs, vcsPath, err = be.Get(context.Background(), "l7e.io/three")
+	So(err, ShouldBeNil)
+	So(vcs, ShouldResemble, "git")
+	So(vcsPath, ShouldResemble, "https://github.com/livetribe/three")
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"net/url"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"l7e.io/vanity"
+)
+
+func init() { //nolint:gochecknoinits
+	vanity.RegisterBackend("kubernetes", openDSN)
+}
+
+// openDSN creates a Kubernetes-based Backend from a data source name such as
+// "kubernetes://namespace?kubeconfig=/home/user/.kube/config", whose host is
+// the namespace of the ConfigMaps.  The in-cluster configuration is used
+// unless a kubeconfig file is specified.
+func openDSN(ctx context.Context, dsn *url.URL) (vanity.Backend, error) {
+	if dsn.Host == "" {
+		return nil, vanity.ErrInvalidDSN
+	}
+
+	q := dsn.Query()
+
+	var opts []BackendOption
+	if s := q.Get("selector"); s != "" {
+		opts = append(opts, WithLabelSelector(s))
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", q.Get("kubeconfig"))
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBackend(ctx, client, dsn.Host, opts...)
+}
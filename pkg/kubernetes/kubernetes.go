@@ -0,0 +1,684 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package kubernetes contains the Kubernetes Backend, storing entries in
+// labelled ConfigMaps which are read through a shared informer cache, so
+// entries can also be managed with kubectl apply.
+package kubernetes // import "l7e.io/vanity/pkg/kubernetes"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+
+	"l7e.io/vanity"
+)
+
+// Keys of the ConfigMap data of an entry; only importPath, vcs and vcsPath
+// are required.
+const (
+	importPathKey     = "importPath"
+	vcsKey            = "vcs"
+	vcsPathKey        = "vcsPath"
+	descriptionKey    = "description"
+	ownerKey          = "owner"
+	defaultBranchKey  = "defaultBranch"
+	docURLKey         = "docURL"
+	sourceTemplateKey = "sourceTemplate"
+	visibilityKey     = "visibility"
+	labelsKey         = "labels"
+	versionKey        = "version"
+	revisionKey       = "revision"
+	createdKey        = "created"
+	updatedKey        = "updated"
+)
+
+const (
+	importPathIndex = "importPath"
+
+	// maxNameLength bounds the sanitized import path in ConfigMap names, well
+	// below the 253 characters allowed.
+	maxNameLength = 200
+
+	// syncInterval and syncTimeout bound the wait for the informer cache to
+	// observe a change.
+	syncInterval = 10 * time.Millisecond
+	syncTimeout  = 5 * time.Second
+)
+
+var (
+	// InvalidConfigMaps is a Prometheus counter that tracks the total
+	// ConfigMaps observed whose entry cannot be decoded, which are skipped.
+	InvalidConfigMaps = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "kubernetes",
+		Name:      "invalid_config_maps_total",
+		Help:      "The total ConfigMaps observed whose entry cannot be decoded",
+	})
+
+	errUnknownRecordVersion = fmt.Errorf("unknown record version")
+	errMissingImportPath    = fmt.Errorf("missing import path")
+	errSetBasedSelector     = fmt.Errorf("label selector is not equality-based")
+
+	invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// objectName is the name of the ConfigMap created for an import path, which
+// is sanitized and suffixed with its hash to avoid collisions.
+func objectName(importPath string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(importPath))
+
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(importPath), "-"), "-")
+	if len(name) > maxNameLength {
+		name = strings.TrimRight(name[:maxNameLength], "-")
+	}
+
+	return fmt.Sprintf("vanity-%s-%08x", name, h.Sum32())
+}
+
+func encodeEntry(e *vanity.Entry) (map[string]string, error) {
+	data := map[string]string{
+		importPathKey: e.ImportPath,
+		vcsKey:        e.VCS,
+		vcsPathKey:    e.VCSPath,
+		versionKey:    strconv.Itoa(e.Version),
+		revisionKey:   strconv.FormatInt(e.Revision, 10),
+		createdKey:    e.Created.Format(time.RFC3339Nano),
+		updatedKey:    e.Updated.Format(time.RFC3339Nano),
+	}
+
+	optional := map[string]string{
+		descriptionKey:    e.Description,
+		ownerKey:          e.Owner,
+		defaultBranchKey:  e.DefaultBranch,
+		docURLKey:         e.DocURL,
+		sourceTemplateKey: e.SourceTemplate,
+		visibilityKey:     string(e.Visibility),
+	}
+	for k, v := range optional {
+		if v != "" {
+			data[k] = v
+		}
+	}
+
+	if len(e.Labels) > 0 {
+		b, err := json.Marshal(e.Labels)
+		if err != nil {
+			return nil, err
+		}
+		data[labelsKey] = string(b)
+	}
+
+	return data, nil
+}
+
+// decodeEntry extracts the entry of a ConfigMap, which may have been applied
+// with kubectl: its version defaults to the current version, its revision to
+// zero, and its times to the creation time of the ConfigMap.
+func decodeEntry(cm *corev1.ConfigMap) (*vanity.Entry, error) {
+	d := cm.Data
+	if d[importPathKey] == "" {
+		return nil, fmt.Errorf(unableToExtractEntry, cm.Name, errMissingImportPath)
+	}
+
+	e := &vanity.Entry{
+		Version:        vanity.EntryVersion,
+		ImportPath:     d[importPathKey],
+		VCS:            d[vcsKey],
+		VCSPath:        d[vcsPathKey],
+		Description:    d[descriptionKey],
+		Owner:          d[ownerKey],
+		DefaultBranch:  d[defaultBranchKey],
+		DocURL:         d[docURLKey],
+		SourceTemplate: d[sourceTemplateKey],
+		Visibility:     vanity.Visibility(d[visibilityKey]),
+		Created:        cm.CreationTimestamp.UTC(),
+	}
+
+	var err error
+	if v, ok := d[versionKey]; ok {
+		if e.Version, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf(unableToExtractEntry, cm.Name, err)
+		}
+		if e.Version != vanity.EntryVersion {
+			return nil, fmt.Errorf(unableToExtractEntry, cm.Name, errUnknownRecordVersion)
+		}
+	}
+	if v, ok := d[revisionKey]; ok {
+		if e.Revision, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf(unableToExtractEntry, cm.Name, err)
+		}
+	}
+	if v, ok := d[createdKey]; ok {
+		if e.Created, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return nil, fmt.Errorf(unableToExtractEntry, cm.Name, err)
+		}
+	}
+	e.Updated = e.Created
+	if v, ok := d[updatedKey]; ok {
+		if e.Updated, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return nil, fmt.Errorf(unableToExtractEntry, cm.Name, err)
+		}
+	}
+	if v, ok := d[labelsKey]; ok {
+		if err = json.Unmarshal([]byte(v), &e.Labels); err != nil {
+			return nil, fmt.Errorf(unableToExtractEntry, cm.Name, err)
+		}
+	}
+
+	return e, nil
+}
+
+func asConfigMap(obj interface{}) (*corev1.ConfigMap, bool) {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = d.Obj
+	}
+	cm, ok := obj.(*corev1.ConfigMap)
+
+	return cm, ok
+}
+
+type kubernetesBE struct {
+	client    kubernetes.Interface
+	namespace string
+	labels    map[string]string
+	informer  cache.SharedIndexInformer
+	events    *vanity.Broadcaster
+	onError   func(name string, err error)
+	stop      chan struct{}
+
+	lock   sync.RWMutex
+	closed bool
+}
+
+// NewBackend creates a Backend storing entries in the ConfigMaps of a
+// namespace that are selected by their labels, one per entry.  The ConfigMaps
+// are read through a shared informer cache, whose initial sync is awaited
+// until ctx is done.
+//
+// Changes are made through the API server, and are awaited in the cache so
+// they are read back; changes to several entries are not atomic.
+func NewBackend(ctx context.Context, client kubernetes.Interface, namespace string, opts ...BackendOption) (vanity.EntryBackend, error) {
+	s := &backendSettings{labelSelector: DefaultLabelSelector}
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	selected, err := labels.ConvertSelectorToLabelsMap(s.labelSelector)
+	if err != nil {
+		return nil, errSetBasedSelector
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(client, s.resync,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = s.labelSelector
+		}))
+
+	be := &kubernetesBE{
+		client:    client,
+		namespace: namespace,
+		labels:    selected,
+		informer:  factory.Core().V1().ConfigMaps().Informer(),
+		events:    vanity.NewBroadcaster(vanity.DefaultRetainedEvents),
+		onError:   s.onError,
+		stop:      make(chan struct{}),
+	}
+
+	err = be.informer.AddIndexers(cache.Indexers{importPathIndex: func(obj interface{}) ([]string, error) {
+		if cm, ok := asConfigMap(obj); ok && cm.Data[importPathKey] != "" {
+			return []string{cm.Data[importPathKey]}, nil
+		}
+		return nil, nil
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	be.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    be.onAdd,
+		UpdateFunc: be.onUpdate,
+		DeleteFunc: be.onDelete,
+	})
+
+	factory.Start(be.stop)
+
+	synced := make(chan struct{})
+	go func() {
+		defer close(synced)
+		cache.WaitForCacheSync(be.stop, be.informer.HasSynced)
+	}()
+
+	select {
+	case <-synced:
+	case <-ctx.Done():
+		close(be.stop)
+		return nil, ctx.Err()
+	}
+
+	return be, nil
+}
+
+func (s *kubernetesBE) onAdd(obj interface{}) {
+	cm, ok := asConfigMap(obj)
+	if !ok {
+		return
+	}
+
+	e, err := decodeEntry(cm)
+	if err != nil {
+		s.reportInvalid(cm, err)
+		return
+	}
+	s.events.Publish(vanity.EventAdded, e.ImportPath, e)
+}
+
+// onUpdate skips resyncs, which leave the ConfigMap data unchanged, and
+// publishes a changed import path as a removal and an addition.
+func (s *kubernetesBE) onUpdate(oldObj, newObj interface{}) {
+	old, ok := asConfigMap(oldObj)
+	if !ok {
+		return
+	}
+	cm, ok := asConfigMap(newObj)
+	if !ok || reflect.DeepEqual(old.Data, cm.Data) {
+		return
+	}
+
+	e, err := decodeEntry(cm)
+	if err != nil {
+		s.reportInvalid(cm, err)
+		s.onDelete(old)
+		return
+	}
+
+	if p := old.Data[importPathKey]; p != e.ImportPath {
+		if p != "" {
+			s.events.Publish(vanity.EventRemoved, p, nil)
+		}
+		s.events.Publish(vanity.EventAdded, e.ImportPath, e)
+		return
+	}
+
+	s.events.Publish(vanity.EventUpdated, e.ImportPath, e)
+}
+
+func (s *kubernetesBE) onDelete(obj interface{}) {
+	cm, ok := asConfigMap(obj)
+	if !ok {
+		return
+	}
+	if p := cm.Data[importPathKey]; p != "" {
+		s.events.Publish(vanity.EventRemoved, p, nil)
+	}
+}
+
+// reportInvalid reports a ConfigMap whose entry cannot be decoded, as it is
+// observed; the ConfigMap is skipped by listings.
+func (s *kubernetesBE) reportInvalid(cm *corev1.ConfigMap, err error) {
+	InvalidConfigMaps.Inc()
+	if s.onError != nil {
+		s.onError(cm.Name, err)
+	}
+}
+
+// checkClosed read-locks the backend, which is unlocked by the returned
+// function, unless it is closed.
+func (s *kubernetesBE) checkClosed() (func(), error) {
+	s.lock.RLock()
+	if s.closed {
+		s.lock.RUnlock()
+		return nil, vanity.ErrAlreadyClosed
+	}
+
+	return s.lock.RUnlock, nil
+}
+
+// lookup returns the cached ConfigMap of an import path; if several
+// ConfigMaps have the same import path, the first by name is returned.
+func (s *kubernetesBE) lookup(importPath string) (*corev1.ConfigMap, error) {
+	objs, err := s.informer.GetIndexer().ByIndex(importPathIndex, importPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, vanity.ErrNotFound
+	}
+
+	var found *corev1.ConfigMap
+	for _, obj := range objs {
+		if cm, ok := asConfigMap(obj); ok && (found == nil || cm.Name < found.Name) {
+			found = cm
+		}
+	}
+
+	return found, nil
+}
+
+// await waits for the cache to observe the data of a ConfigMap, or its
+// removal if data is nil; it gives up after syncTimeout.
+func (s *kubernetesBE) await(ctx context.Context, name string, data map[string]string) {
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+
+	key := s.namespace + "/" + name
+	_ = wait.PollImmediateUntil(syncInterval, func() (bool, error) {
+		obj, exists, err := s.informer.GetIndexer().GetByKey(key)
+		if err != nil || data == nil {
+			return !exists, err
+		}
+		cm, ok := asConfigMap(obj)
+
+		return exists && ok && reflect.DeepEqual(cm.Data, data), nil
+	}, ctx.Done())
+}
+
+func (s *kubernetesBE) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	close(s.stop)
+	s.events.Close()
+
+	return nil
+}
+
+// Healthz checks that the API server is reachable.
+func (s *kubernetesBE) Healthz(context.Context) error {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, err = s.client.Discovery().ServerVersion()
+
+	return err
+}
+
+func (s *kubernetesBE) Get(ctx context.Context, importPath string) (vcs, vcsPath string, err error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *kubernetesBE) GetEntry(_ context.Context, importPath string) (*vanity.Entry, error) {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cm, err := s.lookup(importPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeEntry(cm)
+}
+
+// GetPrefix looks up the prefixes of path in the cache.
+func (s *kubernetesBE) GetPrefix(_ context.Context, path string) (*vanity.Entry, error) {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	for _, candidate := range vanity.Prefixes(path) {
+		cm, err := s.lookup(candidate)
+		if err == vanity.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return decodeEntry(cm)
+	}
+
+	return nil, vanity.ErrNotFound
+}
+
+func (s *kubernetesBE) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return s.InsertEntry(ctx, vanity.NewEntry(importPath, vcs, vcsPath))
+}
+
+func (s *kubernetesBE) InsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.mutate(ctx, vanity.InsertMutation(entry))
+}
+
+func (s *kubernetesBE) UpdateEntry(ctx context.Context, entry *vanity.Entry, ifRevision int64) error {
+	return s.mutate(ctx, vanity.UpdateMutation(entry, ifRevision))
+}
+
+func (s *kubernetesBE) UpsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.mutate(ctx, vanity.UpsertMutation(entry))
+}
+
+func (s *kubernetesBE) Remove(ctx context.Context, importPath string) error {
+	return s.mutate(ctx, vanity.RemoveMutation(importPath))
+}
+
+// fetch reads the ConfigMap of an import path from the API server, as the
+// cache may be stale; nil is returned if it does not exist.
+func (s *kubernetesBE) fetch(ctx context.Context, importPath string) (*corev1.ConfigMap, error) {
+	name := objectName(importPath)
+	if cm, err := s.lookup(importPath); err == nil {
+		name = cm.Name
+	} else if err != vanity.ErrNotFound {
+		return nil, err
+	}
+
+	cm, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cm.Data[importPathKey] != importPath {
+		return nil, nil
+	}
+
+	return cm, nil
+}
+
+// mutate applies a mutation to the ConfigMap of its import path, using
+// optimistic concurrency; the mutation is retried if the ConfigMap is changed
+// concurrently.
+func (s *kubernetesBE) mutate(ctx context.Context, m vanity.Mutation) error {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	importPath := m.Entry.ImportPath
+	var name string
+	var data map[string]string
+
+	err = retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+	}, func() error {
+		cm, err := s.fetch(ctx, importPath)
+		if err != nil {
+			return err
+		}
+
+		entries := make(map[string]*vanity.Entry, 1)
+		if cm != nil {
+			if entries[importPath], err = decodeEntry(cm); err != nil {
+				return err
+			}
+		}
+
+		if err = vanity.ApplyMutations(entries, []vanity.Mutation{m}, time.Now().UTC()); err != nil {
+			if me, ok := err.(*vanity.MutationError); ok {
+				return me.Err
+			}
+			return err
+		}
+
+		configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+
+		e := entries[importPath]
+		if e == nil {
+			name, data = cm.Name, nil
+			uid, rv := cm.UID, cm.ResourceVersion
+			return configMaps.Delete(ctx, cm.Name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{UID: &uid, ResourceVersion: &rv},
+			})
+		}
+
+		if data, err = encodeEntry(e); err != nil {
+			return err
+		}
+
+		if cm == nil {
+			cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name:      objectName(importPath),
+				Namespace: s.namespace,
+				Labels:    s.labels,
+			}}
+			cm.Data = data
+			cm, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+		} else {
+			cm = cm.DeepCopy()
+			cm.Data = data
+			cm, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return err
+		}
+		name = cm.Name
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.await(ctx, name, data)
+
+	return nil
+}
+
+func (s *kubernetesBE) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+func (s *kubernetesBE) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	_, err := s.ListPage(ctx, vanity.ListOptions{}, consumer)
+
+	return err
+}
+
+// ListPage pages the cached entries.
+func (s *kubernetesBE) ListPage(ctx context.Context, opts vanity.ListOptions, consumer vanity.EntryConsumer) (string, error) {
+	entries, err := s.entries()
+	if err != nil {
+		return "", err
+	}
+
+	page, token, err := vanity.PageEntries(entries, opts)
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range page {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		consumer.Consume(ctx, e)
+	}
+
+	return token, nil
+}
+
+// entries returns the cached entries in import path order, skipping the
+// ConfigMaps shadowed by another with the same import path, and those whose
+// entry cannot be decoded, which were reported when observed.
+func (s *kubernetesBE) entries() ([]*vanity.Entry, error) {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	indexer := s.informer.GetIndexer()
+	importPaths := indexer.ListIndexFuncValues(importPathIndex)
+	sort.Strings(importPaths)
+
+	entries := make([]*vanity.Entry, 0, len(importPaths))
+	for _, importPath := range importPaths {
+		cm, err := s.lookup(importPath)
+		if err == vanity.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeEntry(cm)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// Watch delivers the changes observed by the informer, including those made
+// with kubectl; positions are the sequence numbers of the observed changes.
+func (s *kubernetesBE) Watch(ctx context.Context, position string) (<-chan vanity.Event, error) {
+	return s.events.Watch(ctx, position)
+}
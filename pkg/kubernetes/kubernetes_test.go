@@ -0,0 +1,233 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/pkg/kubernetes"
+)
+
+func newKubernetes(t *testing.T, objects ...runtime.Object) (vanity.EntryBackend, *fake.Clientset) {
+	client := fake.NewSimpleClientset(objects...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	be, err := kubernetes.NewBackend(ctx, client, "vanity")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return be, client
+}
+
+// configMap is a ConfigMap of an entry as applied with kubectl.
+func configMap(name, importPath, vcsPath string, labels map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "vanity",
+			Labels:            labels,
+			CreationTimestamp: metav1.Date(2020, 8, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Data: map[string]string{
+			"importPath": importPath,
+			"vcs":        "git",
+			"vcsPath":    vcsPath,
+		},
+	}
+}
+
+var selected = map[string]string{kubernetes.ImportLabel: "true"}
+
+func factory(t *testing.T) (vanity.EntryBackend, func()) {
+	be, _ := newKubernetes(t)
+	return be, func() { _ = be.Close() }
+}
+
+func TestKubernetes(t *testing.T) {
+	apitest.TestEntryBackend(t, factory)
+}
+
+func TestKubernetes_Watch(t *testing.T) {
+	apitest.TestWatcher(t, factory)
+}
+
+func TestKubernetes_configMaps(t *testing.T) {
+	be, client := newKubernetes(t)
+	defer func() { _ = be.Close() }()
+
+	ctx := context.Background()
+
+	assert.NoError(t, be.Add(ctx, "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+
+	// the ConfigMap is labelled so it is selected
+	cms, err := client.CoreV1().ConfigMaps("vanity").List(ctx, metav1.ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, cms.Items, 1) {
+		assert.Equal(t, selected, cms.Items[0].Labels)
+		assert.Regexp(t, `^vanity-l7e-io-vanity-[0-9a-f]{8}$`, cms.Items[0].Name)
+	}
+}
+
+func TestKubernetes_kubectl(t *testing.T) {
+	be, client := newKubernetes(t,
+		configMap("vanity", "l7e.io/vanity", "https://github.com/livetribe/vanity", selected),
+		configMap("unlabelled", "l7e.io/yama", "https://github.com/livetribe/yama", nil))
+	defer func() { _ = be.Close() }()
+
+	ctx := context.Background()
+
+	e, err := be.GetEntry(ctx, "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, vanity.EntryVersion, e.Version)
+	assert.Equal(t, int64(0), e.Revision)
+	assert.Equal(t, time.Date(2020, 8, 1, 0, 0, 0, 0, time.UTC), e.Created)
+	assert.Equal(t, e.Created, e.Updated)
+
+	_, err = be.GetEntry(ctx, "l7e.io/yama")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	// updates keep the name of applied ConfigMaps
+	e.Owner = "platform"
+	assert.NoError(t, be.UpdateEntry(ctx, e, vanity.AnyRevision))
+
+	cm, err := client.CoreV1().ConfigMaps("vanity").Get(ctx, "vanity", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "platform", cm.Data["owner"])
+	assert.Equal(t, "1", cm.Data["revision"])
+
+	// changes applied with kubectl are observed
+	cm = configMap("pbf", "m4o.io/pbf", "https://github.com/magurl/pbf", selected)
+	_, err = client.CoreV1().ConfigMaps("vanity").Create(ctx, cm, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, err := be.GetEntry(ctx, "m4o.io/pbf")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestKubernetes_resume(t *testing.T) {
+	be, client := newKubernetes(t)
+	defer func() { _ = be.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := be.(vanity.Watcher)
+	events, err := w.Watch(ctx, "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, be.Add(ctx, "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+	assert.NoError(t, be.UpsertEntry(ctx, vanity.NewEntry("l7e.io/vanity", "git", "https://gitlab.com/livetribe/vanity")))
+
+	// ConfigMaps applied with kubectl are notified
+	cm := configMap("pbf", "m4o.io/pbf", "https://github.com/magurl/pbf", selected)
+	_, err = client.CoreV1().ConfigMaps("vanity").Create(ctx, cm, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	expected := []vanity.Event{
+		{Type: vanity.EventAdded, ImportPath: "l7e.io/vanity"},
+		{Type: vanity.EventUpdated, ImportPath: "l7e.io/vanity"},
+		{Type: vanity.EventAdded, ImportPath: "m4o.io/pbf"},
+	}
+	var positions []string
+	for _, x := range expected {
+		e := <-events
+		assert.Equal(t, x.Type, e.Type)
+		assert.Equal(t, x.ImportPath, e.ImportPath)
+		positions = append(positions, e.Position)
+	}
+
+	// resume after the addition
+	resumed, err := w.Watch(ctx, positions[0])
+	assert.NoError(t, err)
+	e := <-resumed
+	assert.Equal(t, vanity.EventUpdated, e.Type)
+	assert.Equal(t, "https://gitlab.com/livetribe/vanity", e.Entry.VCSPath)
+}
+
+func TestKubernetes_invalid(t *testing.T) {
+	bad := configMap("bad", "l7e.io/bad", "https://github.com/livetribe/bad", selected)
+	bad.Data["labels"] = "{team"
+	good := configMap("good", "l7e.io/good", "https://github.com/livetribe/good", selected)
+
+	var lock sync.Mutex
+	var invalid []string
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	m := &dto.Metric{}
+	assert.NoError(t, kubernetes.InvalidConfigMaps.Write(m))
+	before := m.Counter.GetValue()
+
+	be, err := kubernetes.NewBackend(ctx, fake.NewSimpleClientset(bad, good), "vanity",
+		kubernetes.WithErrorHandler(func(name string, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			invalid = append(invalid, name)
+		}))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = be.Close() }()
+
+	// the bad manifest is reported and skipped, rather than failing listings
+	var listed []string
+	assert.NoError(t, be.ListEntries(ctx, vanity.EntryConsumerFunc(func(_ context.Context, e *vanity.Entry) {
+		listed = append(listed, e.ImportPath)
+	})))
+	assert.Equal(t, []string{"l7e.io/good"}, listed)
+
+	// handlers are notified asynchronously of the observed ConfigMaps
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(invalid) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"bad"}, invalid)
+	assert.NoError(t, kubernetes.InvalidConfigMaps.Write(m))
+	assert.Equal(t, before+1, m.Counter.GetValue())
+
+	_, err = be.GetEntry(ctx, "l7e.io/bad")
+	assert.Error(t, err)
+}
+
+func TestKubernetes_setBasedSelector(t *testing.T) {
+	_, err := kubernetes.NewBackend(context.Background(), fake.NewSimpleClientset(), "vanity",
+		kubernetes.WithLabelSelector("team in (platform)"))
+	assert.Error(t, err)
+}
+
+func TestKubernetes_Open(t *testing.T) {
+	_, err := vanity.Open(context.Background(), "kubernetes:///")
+	assert.Equal(t, vanity.ErrInvalidDSN, err)
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import "time"
+
+const (
+	// ImportLabel is the label of the ConfigMaps of the entries.
+	ImportLabel = "vanity.l7e.io/import"
+
+	// DefaultLabelSelector is the default selector of the ConfigMaps of the
+	// entries.
+	DefaultLabelSelector = ImportLabel + "=true"
+)
+
+type backendSettings struct {
+	labelSelector string
+	resync        time.Duration
+	onError       func(name string, err error)
+}
+
+// A BackendOption is an option for a Kubernetes-based backend.
+type BackendOption interface {
+	Apply(*backendSettings)
+}
+
+// WithLabelSelector configures the selector of the ConfigMaps of the entries;
+// default is DefaultLabelSelector.  The selector must be equality-based, as
+// the ConfigMaps of added entries are labelled with it.
+func WithLabelSelector(selector string) BackendOption {
+	return withLabelSelector{selector}
+}
+
+type withLabelSelector struct{ selector string }
+
+func (w withLabelSelector) Apply(o *backendSettings) {
+	o.labelSelector = w.selector
+}
+
+// WithResync configures the interval between the resyncs of the informer
+// cache; default is zero, which disables resyncs.
+func WithResync(d time.Duration) BackendOption {
+	return withResync{d}
+}
+
+type withResync struct{ d time.Duration }
+
+func (w withResync) Apply(o *backendSettings) {
+	o.resync = w.d
+}
+
+// WithErrorHandler configures the function reporting the ConfigMaps whose
+// entry cannot be decoded, by name, which are skipped; they are otherwise only
+// counted by the InvalidConfigMaps counter.
+func WithErrorHandler(h func(name string, err error)) BackendOption {
+	return withErrorHandler{h}
+}
+
+type withErrorHandler struct{ h func(name string, err error) }
+
+func (w withErrorHandler) Apply(o *backendSettings) {
+	o.onError = w.h
+}
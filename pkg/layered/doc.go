@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+/*
+Package layered provides an implementation of Backend which combines an ordered
+list of Backends, its layers.
+
+Vanity URL configurations are looked up in each layer, in order, until one is
+found, so configurations of earlier layers shadow those of later layers with
+the same import path.  Listings merge the configurations of all layers, less
+the shadowed ones.  Changes are made to the designated primary layer; without
+a primary layer, changes are not supported.
+
+
+Creating a Layered Backend
+
+To keep a static TOML file of core configurations in front of a Spanner table
+of team-managed ones:
+
+    core, err := toml.NewTOMLBackend(toml.InTable("entry"), toml.FromFile("core.toml"))
+    if err != nil {
+        return err
+    }
+
+    teams, err := spanner.NewClient(ctx, "projects/p/instances/i/databases/d")
+    if err != nil {
+        return err
+    }
+
+    be, err := layered.NewLayeredBackend([]layered.Layer{
+        {Name: "core", Backend: core},
+        {Name: "teams", Backend: teams},
+    }, layered.WithPrimary("teams"))
+    if err != nil {
+        return err
+    }
+    defer be.Close()
+
+Closing the layered backend closes all its layers.
+
+
+Opening a Layered Backend
+
+Layered backends can be opened by vanity.Open using the layer query parameter,
+once per layer, whose value is the query-escaped data source name of the
+layer.  Layers are named after the scheme of their data source name and the
+primary layer is selected by the primary query parameter:
+
+    be, err := vanity.Open(ctx, "layered:?layer=toml:///etc/vanity/core.toml&layer=spanner://projects/p/instances/i/databases/d&primary=spanner")
+
+Layers of the same scheme are named by the fragment of their data source name,
+which is removed before the layer is opened:
+
+    be, err := vanity.Open(ctx, "layered:?layer=toml:///etc/vanity/core.toml%23core&layer=toml:///etc/vanity/teams.toml%23teams&primary=teams")
+
+
+Health Checks
+
+Healthz checks every layer, returning a *HealthError which reports the error
+of each unhealthy layer.
+
+*/
+package layered // import "l7e.io/vanity/pkg/layered"
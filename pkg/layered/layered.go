@@ -0,0 +1,324 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package layered
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"l7e.io/vanity"
+)
+
+var (
+	errNoLayers         = fmt.Errorf("no layers specified")
+	errDuplicateLayer   = fmt.Errorf("duplicate layer name")
+	errUnknownPrimary   = fmt.Errorf("unknown primary layer")
+	errMissingLayerName = fmt.Errorf("layer name not specified")
+)
+
+// Layer is a named Backend of a layered Backend.
+type Layer struct {
+	// Name identifies the layer in health checks and options.
+	Name string
+
+	// Backend is the Backend of the layer.
+	Backend vanity.Backend
+}
+
+type settings struct {
+	primary string
+}
+
+// An Option is an option for a layered Backend.
+type Option interface {
+	Apply(*settings)
+}
+
+// WithPrimary designates the named layer as the primary layer, to which
+// changes are made.
+func WithPrimary(name string) Option {
+	return primaryOption{name: name}
+}
+
+type primaryOption struct{ name string }
+
+func (p primaryOption) Apply(o *settings) {
+	o.primary = p.name
+}
+
+// LayerError is the error of an unhealthy layer.
+type LayerError struct {
+	Name string
+	Err  error
+}
+
+// HealthError is returned by Healthz if any of the layers is unhealthy.
+type HealthError struct {
+	// Layers are the errors of the unhealthy layers, in layer order.
+	Layers []LayerError
+}
+
+func (e *HealthError) Error() string {
+	s := make([]string, len(e.Layers))
+	for i, l := range e.Layers {
+		s[i] = fmt.Sprintf("layer %s: %s", l.Name, l.Err)
+	}
+
+	return strings.Join(s, "; ")
+}
+
+type layeredBE struct {
+	layers  []Layer
+	primary vanity.EntryBackend
+}
+
+func init() { //nolint:gochecknoinits
+	vanity.RegisterBackend("layered", openDSN)
+}
+
+// NewLayeredBackend creates a Backend combining layers, in order, using the
+// specified options.
+func NewLayeredBackend(layers []Layer, options ...Option) (vanity.EntryBackend, error) {
+	if len(layers) == 0 {
+		return nil, errNoLayers
+	}
+
+	var s settings
+	for _, o := range options {
+		o.Apply(&s)
+	}
+
+	be := &layeredBE{layers: layers}
+
+	names := make(map[string]bool)
+	for _, l := range layers {
+		if l.Name == "" {
+			return nil, errMissingLayerName
+		}
+		if names[l.Name] {
+			return nil, errDuplicateLayer
+		}
+		names[l.Name] = true
+
+		if l.Name == s.primary {
+			be.primary = vanity.AsEntryBackend(l.Backend)
+		}
+	}
+
+	if s.primary != "" && be.primary == nil {
+		return nil, errUnknownPrimary
+	}
+
+	return be, nil
+}
+
+// openDSN creates a layered Backend from a data source name whose layer query
+// parameters are the data source names of its layers, which are named after
+// their fragment, or their scheme if they have none.  The primary query
+// parameter names the primary layer.
+func openDSN(ctx context.Context, dsn *url.URL) (vanity.Backend, error) {
+	q := dsn.Query()
+
+	var layers []Layer
+	closeAll := func() {
+		for _, l := range layers {
+			_ = l.Backend.Close()
+		}
+	}
+
+	for _, d := range q["layer"] {
+		u, err := url.Parse(d)
+		if err != nil || u.Scheme == "" {
+			closeAll()
+			return nil, vanity.ErrInvalidDSN
+		}
+
+		name := u.Scheme
+		if i := strings.IndexByte(d, '#'); i >= 0 {
+			if u.Fragment != "" {
+				name = u.Fragment
+			}
+			d = d[:i]
+		}
+
+		be, err := vanity.Open(ctx, d)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		layers = append(layers, Layer{Name: name, Backend: be})
+	}
+
+	var options []Option
+	if p := q.Get("primary"); p != "" {
+		options = append(options, WithPrimary(p))
+	}
+
+	be, err := NewLayeredBackend(layers, options...)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+
+	return be, nil
+}
+
+func (s *layeredBE) Close() error {
+	var err error
+	for _, l := range s.layers {
+		if e := l.Backend.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+func (s *layeredBE) Get(ctx context.Context, importPath string) (string, string, error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *layeredBE) GetEntry(ctx context.Context, importPath string) (*vanity.Entry, error) {
+	for _, l := range s.layers {
+		e, err := vanity.AsEntryBackend(l.Backend).GetEntry(ctx, importPath)
+		if err == vanity.ErrNotFound {
+			continue
+		}
+
+		return e, err
+	}
+
+	return nil, vanity.ErrNotFound
+}
+
+// GetPrefix finds the longest prefix of path in every layer, preferring the
+// earlier layer if several layers find the same prefix.
+func (s *layeredBE) GetPrefix(ctx context.Context, path string) (*vanity.Entry, error) {
+	var found *vanity.Entry
+	for _, l := range s.layers {
+		e, err := vanity.GetPrefix(ctx, l.Backend, path)
+		if err == vanity.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if found == nil || len(e.ImportPath) > len(found.ImportPath) {
+			found = e
+		}
+	}
+
+	if found == nil {
+		return nil, vanity.ErrNotFound
+	}
+
+	return found, nil
+}
+
+func (s *layeredBE) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return s.InsertEntry(ctx, vanity.NewEntry(importPath, vcs, vcsPath))
+}
+
+func (s *layeredBE) InsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	if s.primary == nil {
+		return vanity.ErrNotSupported
+	}
+	return s.primary.InsertEntry(ctx, entry)
+}
+
+func (s *layeredBE) UpdateEntry(ctx context.Context, entry *vanity.Entry, ifRevision int64) error {
+	if s.primary == nil {
+		return vanity.ErrNotSupported
+	}
+	return s.primary.UpdateEntry(ctx, entry, ifRevision)
+}
+
+func (s *layeredBE) UpsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	if s.primary == nil {
+		return vanity.ErrNotSupported
+	}
+	return s.primary.UpsertEntry(ctx, entry)
+}
+
+func (s *layeredBE) Apply(ctx context.Context, mutations []vanity.Mutation) error {
+	if s.primary == nil {
+		return vanity.ErrNotSupported
+	}
+	return vanity.Apply(ctx, s.primary, mutations)
+}
+
+func (s *layeredBE) Remove(ctx context.Context, importPath string) error {
+	if s.primary == nil {
+		return vanity.ErrNotSupported
+	}
+	return s.primary.Remove(ctx, importPath)
+}
+
+func (s *layeredBE) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+// ListEntries lists the configurations of every layer, skipping those
+// shadowed by an earlier layer.
+func (s *layeredBE) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	seen := make(map[string]bool)
+	for _, l := range s.layers {
+		var listed []string
+		err := vanity.AsEntryBackend(l.Backend).ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+			if seen[e.ImportPath] {
+				return
+			}
+			listed = append(listed, e.ImportPath)
+			consumer.Consume(ctx, e)
+		}))
+		if err != nil {
+			return err
+		}
+
+		// only earlier layers shadow configurations
+		for _, importPath := range listed {
+			seen[importPath] = true
+		}
+	}
+
+	return nil
+}
+
+// Healthz checks every layer, returning a *HealthError if any is unhealthy.
+func (s *layeredBE) Healthz(ctx context.Context) error {
+	var errs []LayerError
+	for _, l := range s.layers {
+		if err := l.Backend.Healthz(ctx); err != nil {
+			errs = append(errs, LayerError{Name: l.Name, Err: err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return &HealthError{Layers: errs}
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package layered_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/pkg/layered"
+	"l7e.io/vanity/pkg/memory"
+)
+
+func newLayers() (core, teams memory.ConvenientBackend, be vanity.EntryBackend, err error) {
+	core = memory.NewInMemoryAPI()
+	core.AddEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
+	core.AddEntry("l7e.io/tools", "git", "https://github.com/livetribe/tools")
+
+	teams = memory.NewInMemoryAPI()
+	teams.AddEntry("l7e.io/vanity", "git", "https://gitlab.com/shadowed/vanity")
+	teams.AddEntry("l7e.io/tools/lint", "git", "https://github.com/team/lint")
+	teams.AddEntry("l7e.io/yama", "git", "https://github.com/livetribe/yama")
+
+	be, err = layered.NewLayeredBackend([]layered.Layer{
+		{Name: "core", Backend: core},
+		{Name: "teams", Backend: teams},
+	}, layered.WithPrimary("teams"))
+
+	return core, teams, be, err
+}
+
+func TestLayered_Get(t *testing.T) {
+	_, _, be, err := newLayers()
+	assert.NoError(t, err)
+
+	_, vcsPath, err := be.Get(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/livetribe/vanity", vcsPath)
+
+	_, vcsPath, err = be.Get(context.Background(), "l7e.io/yama")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/livetribe/yama", vcsPath)
+
+	_, _, err = be.Get(context.Background(), "l7e.io/unknown")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	e, err := vanity.GetPrefix(context.Background(), be, "l7e.io/tools/lint/cmd/lint")
+	assert.NoError(t, err)
+	assert.Equal(t, "l7e.io/tools/lint", e.ImportPath)
+
+	e, err = vanity.GetPrefix(context.Background(), be, "l7e.io/vanity/cmd/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/livetribe/vanity", e.VCSPath)
+}
+
+func TestLayered_Get_error(t *testing.T) {
+	failure := fmt.Errorf("unavailable")
+	be, err := layered.NewLayeredBackend([]layered.Layer{
+		{Name: "mock", Backend: &apitest.MockBackend{Healthy: failure}},
+		{Name: "memory", Backend: memory.NewInMemoryAPI()},
+	})
+	assert.NoError(t, err)
+
+	_, _, err = be.Get(context.Background(), "l7e.io/vanity")
+	assert.Equal(t, failure, err)
+}
+
+func TestLayered_List(t *testing.T) {
+	_, _, be, err := newLayers()
+	assert.NoError(t, err)
+
+	listed := make(map[string]string)
+	var paths []string
+	err = be.List(context.Background(), vanity.ConsumerFunc(func(_ context.Context, importPath, _, vcsPath string) {
+		listed[importPath] = vcsPath
+		paths = append(paths, importPath)
+	}))
+	assert.NoError(t, err)
+
+	sort.Strings(paths)
+	assert.Equal(t, []string{"l7e.io/tools", "l7e.io/tools/lint", "l7e.io/vanity", "l7e.io/yama"}, paths)
+	assert.Equal(t, "https://github.com/livetribe/vanity", listed["l7e.io/vanity"])
+}
+
+func TestLayered_Writes(t *testing.T) {
+	core, teams, be, err := newLayers()
+	assert.NoError(t, err)
+
+	assert.NoError(t, be.Add(context.Background(), "l7e.io/new", "git", "https://github.com/team/new"))
+
+	_, _, err = teams.Get(context.Background(), "l7e.io/new")
+	assert.NoError(t, err)
+	_, _, err = core.Get(context.Background(), "l7e.io/new")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	assert.NoError(t, be.Remove(context.Background(), "l7e.io/new"))
+	_, _, err = teams.Get(context.Background(), "l7e.io/new")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	readOnly, err := layered.NewLayeredBackend([]layered.Layer{{Name: "core", Backend: core}})
+	assert.NoError(t, err)
+	assert.Equal(t, vanity.ErrNotSupported, readOnly.Add(context.Background(), "l7e.io/new", "git", "https://github.com/team/new"))
+	assert.Equal(t, vanity.ErrNotSupported, readOnly.Remove(context.Background(), "l7e.io/vanity"))
+}
+
+func TestLayered_Healthz(t *testing.T) {
+	failure := fmt.Errorf("unavailable")
+	be, err := layered.NewLayeredBackend([]layered.Layer{
+		{Name: "memory", Backend: memory.NewInMemoryAPI()},
+		{Name: "mock", Backend: &apitest.MockBackend{Healthy: failure}},
+	})
+	assert.NoError(t, err)
+
+	err = be.Healthz(context.Background())
+	assert.IsType(t, &layered.HealthError{}, err)
+	assert.Equal(t, []layered.LayerError{{Name: "mock", Err: failure}}, err.(*layered.HealthError).Layers)
+	assert.Equal(t, "layer mock: unavailable", err.Error())
+}
+
+func TestNewLayeredBackend_errors(t *testing.T) {
+	_, err := layered.NewLayeredBackend(nil)
+	assert.Error(t, err)
+
+	_, err = layered.NewLayeredBackend([]layered.Layer{
+		{Name: "a", Backend: memory.NewInMemoryAPI()},
+		{Name: "a", Backend: memory.NewInMemoryAPI()},
+	})
+	assert.Error(t, err)
+
+	_, err = layered.NewLayeredBackend([]layered.Layer{
+		{Name: "a", Backend: memory.NewInMemoryAPI()},
+	}, layered.WithPrimary("b"))
+	assert.Error(t, err)
+}
+
+func TestLayered_Open(t *testing.T) {
+	be, err := vanity.Open(context.Background(), "layered:?layer="+url.QueryEscape("memory:")+"&primary=memory")
+	assert.NoError(t, err)
+
+	assert.NoError(t, be.Add(context.Background(), "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+	_, vcsPath, err := be.Get(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/livetribe/vanity", vcsPath)
+	assert.NoError(t, be.Close())
+
+	// layers of the same scheme are named by their fragment
+	be, err = vanity.Open(context.Background(), "layered:?layer="+url.QueryEscape("memory:#core")+
+		"&layer="+url.QueryEscape("memory:#teams")+"&primary=teams")
+	assert.NoError(t, err)
+	assert.NoError(t, be.Add(context.Background(), "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+	assert.NoError(t, be.Close())
+
+	_, err = vanity.Open(context.Background(), "layered:?layer=memory:&layer=memory:")
+	assert.Error(t, err)
+
+	_, err = vanity.Open(context.Background(), "layered:?layer=nope:")
+	assert.Equal(t, vanity.ErrUnknownBackend, err)
+}
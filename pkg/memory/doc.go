@@ -46,5 +46,20 @@ ignored.
 
     be.AddEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
 
+
+Watching for Changes
+
+The backend implements vanity.Watcher, delivering every change made to its
+configurations:
+
+    events, err := be.(vanity.Watcher).Watch(ctx, "")
+    if err != nil {
+        return err
+    }
+
+    for e := range events {
+        fmt.Println(e.Type, e.ImportPath)
+    }
+
 */
 package memory // import "l7e.io/vanity/pkg/memory"
@@ -19,17 +19,20 @@ package memory
 
 import (
 	"context"
+	"net/url"
 	"sync"
+	"time"
 
 	"l7e.io/vanity"
 )
 
 // ConvenientBackend is the interface that wraps Backend with the AddEntry method.
 type ConvenientBackend interface {
-	vanity.Backend
+	vanity.EntryBackend
 
 	// AddEntry is a convenience method for adding a vanity URL configuration
 	// without having to pass a context.Context instance and check for errors.
+	// Any existing configuration of the import path is replaced.
 	//
 	// Calling this method on a closed instance has no effect; the call is
 	// virtually ignored.
@@ -38,25 +41,57 @@ type ConvenientBackend interface {
 
 type inMemory struct {
 	lock    sync.RWMutex
-	entries map[string]*entry
+	entries map[string]*vanity.Entry
+	events  *vanity.Broadcaster
 	closed  bool
 }
 
-type entry struct {
-	vcs, vcsPath string
+func init() { //nolint:gochecknoinits
+	vanity.RegisterBackend("memory", func(context.Context, *url.URL) (vanity.Backend, error) {
+		return NewInMemoryAPI(), nil
+	})
 }
 
 // NewInMemoryAPI creates an in-memory Backend instance.
 func NewInMemoryAPI() ConvenientBackend {
-	return &inMemory{entries: make(map[string]*entry)}
+	return &inMemory{
+		entries: make(map[string]*vanity.Entry),
+		events:  vanity.NewBroadcaster(vanity.DefaultRetainedEvents),
+	}
 }
 
 func (s *inMemory) AddEntry(importPath, vcs, vcsPath string) {
+	s.put(vanity.NewEntry(importPath, vcs, vcsPath))
+}
+
+// put stores a copy of entry, stamping its revision, creation and update
+// times.  The creation time of an existing configuration is kept.
+func (s *inMemory) put(entry *vanity.Entry) {
 	if s.closed {
 		return
 	}
 
-	s.entries[importPath] = &entry{vcs: vcs, vcsPath: vcsPath}
+	e := entry.Clone()
+	if e.Version == 0 {
+		e.Version = vanity.EntryVersion
+	}
+
+	t := vanity.EventAdded
+	now := time.Now().UTC()
+	if old, found := s.entries[e.ImportPath]; found {
+		t = vanity.EventUpdated
+		e.Revision = old.Revision + 1
+		e.Created = old.Created
+	} else {
+		e.Revision = 1
+		if e.Created.IsZero() {
+			e.Created = now
+		}
+	}
+	e.Updated = now
+
+	s.entries[e.ImportPath] = e
+	s.events.Publish(t, e.ImportPath, e)
 }
 
 func (s *inMemory) Close() error {
@@ -65,6 +100,7 @@ func (s *inMemory) Close() error {
 
 	s.entries = nil
 	s.closed = true
+	s.events.Close()
 
 	return nil
 }
@@ -77,22 +113,67 @@ func (s *inMemory) check() error {
 	return nil
 }
 
-func (s *inMemory) Get(_ context.Context, importPath string) (string, string, error) {
+func (s *inMemory) Get(ctx context.Context, importPath string) (string, string, error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *inMemory) GetEntry(_ context.Context, importPath string) (*vanity.Entry, error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
 	if err := s.check(); err != nil {
-		return "", "", err
+		return nil, err
 	}
 
 	e, found := s.entries[importPath]
 	if !found {
-		return "", "", vanity.ErrNotFound
+		return nil, vanity.ErrNotFound
+	}
+	return e.Clone(), nil
+}
+
+func (s *inMemory) GetPrefix(_ context.Context, path string) (*vanity.Entry, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	for _, importPath := range vanity.Prefixes(path) {
+		if e, found := s.entries[importPath]; found {
+			return e.Clone(), nil
+		}
+	}
+	return nil, vanity.ErrNotFound
+}
+
+func (s *inMemory) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return s.InsertEntry(ctx, vanity.NewEntry(importPath, vcs, vcsPath))
+}
+
+func (s *inMemory) InsertEntry(_ context.Context, entry *vanity.Entry) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.check(); err != nil {
+		return err
 	}
-	return e.vcs, e.vcsPath, nil
+
+	if _, found := s.entries[entry.ImportPath]; found {
+		return vanity.ErrAlreadyExists
+	}
+
+	s.put(entry)
+
+	return nil
 }
 
-func (s *inMemory) Add(_ context.Context, importPath, vcs, vcsPath string) error {
+func (s *inMemory) UpdateEntry(_ context.Context, entry *vanity.Entry, ifRevision int64) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
@@ -100,7 +181,70 @@ func (s *inMemory) Add(_ context.Context, importPath, vcs, vcsPath string) error
 		return err
 	}
 
-	s.AddEntry(importPath, vcs, vcsPath)
+	old, found := s.entries[entry.ImportPath]
+	if !found {
+		return vanity.ErrNotFound
+	}
+	if ifRevision != vanity.AnyRevision && ifRevision != old.Revision {
+		return vanity.ErrConflict
+	}
+
+	s.put(entry)
+
+	return nil
+}
+
+func (s *inMemory) UpsertEntry(_ context.Context, entry *vanity.Entry) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.check(); err != nil {
+		return err
+	}
+
+	s.put(entry)
+
+	return nil
+}
+
+func (s *inMemory) Apply(_ context.Context, mutations []vanity.Mutation) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.check(); err != nil {
+		return err
+	}
+
+	entries := make(map[string]*vanity.Entry)
+	for _, m := range mutations {
+		if m.Entry == nil {
+			continue
+		}
+		if e, found := s.entries[m.Entry.ImportPath]; found {
+			entries[m.Entry.ImportPath] = e
+		}
+	}
+
+	if err := vanity.ApplyMutations(entries, mutations, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	for importPath, e := range entries {
+		_, found := s.entries[importPath]
+		switch {
+		case e == nil:
+			if found {
+				delete(s.entries, importPath)
+				s.events.Publish(vanity.EventRemoved, importPath, nil)
+			}
+		case found:
+			s.entries[importPath] = e
+			s.events.Publish(vanity.EventUpdated, importPath, e)
+		default:
+			s.entries[importPath] = e
+			s.events.Publish(vanity.EventAdded, importPath, e)
+		}
+	}
 
 	return nil
 }
@@ -119,37 +263,85 @@ func (s *inMemory) Remove(_ context.Context, importPath string) error {
 	}
 
 	delete(s.entries, importPath)
+	s.events.Publish(vanity.EventRemoved, importPath, nil)
 
 	return nil
 }
 
 func (s *inMemory) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+func (s *inMemory) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
 	s.lock.RLock()
 
 	if err := s.check(); err != nil {
+		s.lock.RUnlock()
 		return err
 	}
 
-	c := make(map[string]*entry)
-	for k, v := range s.entries {
-		c[k] = v
+	c := make([]*vanity.Entry, 0, len(s.entries))
+	for _, v := range s.entries {
+		c = append(c, v.Clone())
 	}
 
 	s.lock.RUnlock()
 
-	for importPath, e := range c {
+	for _, e := range c {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
 
-		consumer.OnEntry(ctx, importPath, e.vcs, e.vcsPath)
+		consumer.Consume(ctx, e)
 	}
 
 	return nil
 }
 
+func (s *inMemory) ListPage(ctx context.Context, opts vanity.ListOptions, consumer vanity.EntryConsumer) (string, error) {
+	s.lock.RLock()
+
+	if err := s.check(); err != nil {
+		s.lock.RUnlock()
+		return "", err
+	}
+
+	c := make([]*vanity.Entry, 0, len(s.entries))
+	for _, v := range s.entries {
+		c = append(c, v)
+	}
+
+	s.lock.RUnlock()
+
+	page, next, err := vanity.PageEntries(c, opts)
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range page {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		consumer.Consume(ctx, e.Clone())
+	}
+
+	return next, nil
+}
+
+func (s *inMemory) Watch(ctx context.Context, position string) (<-chan vanity.Event, error) {
+	return s.events.Watch(ctx, position)
+}
+
 func (s *inMemory) Healthz(_ context.Context) error {
-	return nil
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.check()
 }
@@ -28,9 +28,15 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
 	"l7e.io/vanity/pkg/memory"
 )
 
+func newInMemory(*testing.T) (vanity.EntryBackend, func()) {
+	be := memory.NewInMemoryAPI()
+	return be, func() { _ = be.Close() }
+}
+
 type entry struct {
 	vcs, vcsPath string
 }
@@ -157,6 +163,10 @@ func TestInMemoryAPI(t *testing.T) {
 	})
 }
 
+func TestInMemory_EntryBackend(t *testing.T) {
+	apitest.TestEntryBackend(t, newInMemory)
+}
+
 func TestInMemory_AddEntry(t *testing.T) {
 	be := memory.NewInMemoryAPI()
 
@@ -219,5 +229,158 @@ func TestInMemory_List_Timeout(t *testing.T) {
 
 func TestInMemory_Healthz(t *testing.T) {
 	be := memory.NewInMemoryAPI()
-	assert.NoError(t, be.Healthz(context.Background())) // always healthy
+	assert.NoError(t, be.Healthz(context.Background()))
+	assert.NoError(t, be.Close())
+	assert.Equal(t, vanity.ErrAlreadyClosed, be.Healthz(context.Background()))
+}
+
+func TestInMemory_GetPrefix(t *testing.T) {
+	be := memory.NewInMemoryAPI()
+	be.AddEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
+	be.AddEntry("l7e.io/vanity/cmd/vanity", "git", "https://github.com/livetribe/vanity-cmd")
+
+	pg, ok := be.(vanity.PrefixGetter)
+	assert.True(t, ok)
+
+	e, err := pg.GetPrefix(context.Background(), "l7e.io/vanity/pkg/toml")
+	assert.NoError(t, err)
+	assert.Equal(t, "l7e.io/vanity", e.ImportPath)
+	assert.Equal(t, "git", e.VCS)
+	assert.Equal(t, "https://github.com/livetribe/vanity", e.VCSPath)
+
+	e, err = pg.GetPrefix(context.Background(), "l7e.io/vanity/cmd/vanity/server")
+	assert.NoError(t, err)
+	assert.Equal(t, "l7e.io/vanity/cmd/vanity", e.ImportPath)
+	assert.Equal(t, "https://github.com/livetribe/vanity-cmd", e.VCSPath)
+
+	_, err = pg.GetPrefix(context.Background(), "m4o.io/pbf")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	assert.NoError(t, be.Close())
+	_, err = pg.GetPrefix(context.Background(), "l7e.io/vanity")
+	assert.Equal(t, vanity.ErrAlreadyClosed, err)
+}
+
+func TestInMemory_Entries(t *testing.T) {
+	be := memory.NewInMemoryAPI()
+
+	in := vanity.NewEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
+	in.Description = "Vanity URL server"
+	in.Owner = "platform"
+	in.DefaultBranch = "main"
+	in.Visibility = vanity.VisibilityPublic
+	in.Labels = map[string]string{"tier": "core"}
+
+	assert.NoError(t, be.InsertEntry(context.Background(), in))
+
+	in.Labels["tier"] = "changed"
+
+	e, err := be.GetEntry(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, vanity.EntryVersion, e.Version)
+	assert.Equal(t, "Vanity URL server", e.Description)
+	assert.Equal(t, "platform", e.Owner)
+	assert.Equal(t, "main", e.DefaultBranch)
+	assert.Equal(t, vanity.VisibilityPublic, e.Visibility)
+	assert.Equal(t, map[string]string{"tier": "core"}, e.Labels)
+	assert.False(t, e.Created.IsZero())
+	assert.False(t, e.Updated.IsZero())
+
+	var entries []*vanity.Entry
+	err = be.ListEntries(context.Background(), vanity.EntryConsumerFunc(func(_ context.Context, e *vanity.Entry) {
+		entries = append(entries, e)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "platform", entries[0].Owner)
+
+	_, err = be.GetEntry(context.Background(), "m4o.io/pbf")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	assert.NoError(t, be.Close())
+	assert.Equal(t, vanity.ErrAlreadyClosed, be.InsertEntry(context.Background(), in))
+}
+
+func TestInMemory_Insert_Update_Upsert(t *testing.T) {
+	be := memory.NewInMemoryAPI()
+
+	assert.NoError(t, be.Add(context.Background(), "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+	assert.Equal(t, vanity.ErrAlreadyExists,
+		be.Add(context.Background(), "l7e.io/vanity", "git", "https://github.com/livetribe/other"))
+
+	created, err := be.GetEntry(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+
+	err = be.UpdateEntry(context.Background(), vanity.NewEntry("l7e.io/vanity", "git", "https://gitlab.com/livetribe/vanity"),
+		vanity.AnyRevision)
+	assert.NoError(t, err)
+
+	e, err := be.GetEntry(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://gitlab.com/livetribe/vanity", e.VCSPath)
+	assert.Equal(t, created.Created, e.Created)
+
+	err = be.UpdateEntry(context.Background(), vanity.NewEntry("m4o.io/pbf", "git", "https://github.com/magurl/pbf"),
+		vanity.AnyRevision)
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	err = be.UpsertEntry(context.Background(), vanity.NewEntry("m4o.io/pbf", "git", "https://github.com/magurl/pbf"))
+	assert.NoError(t, err)
+	err = be.UpsertEntry(context.Background(), vanity.NewEntry("m4o.io/pbf", "git", "https://gitlab.com/magurl/pbf"))
+	assert.NoError(t, err)
+
+	vcs, vcsPath, err := be.Get(context.Background(), "m4o.io/pbf")
+	assert.NoError(t, err)
+	assert.Equal(t, "git", vcs)
+	assert.Equal(t, "https://gitlab.com/magurl/pbf", vcsPath)
+}
+
+func TestInMemory_Revisions(t *testing.T) {
+	be := memory.NewInMemoryAPI()
+	be.AddEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
+
+	e, err := be.GetEntry(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), e.Revision)
+
+	e.VCSPath = "https://gitlab.com/livetribe/vanity"
+	assert.NoError(t, be.UpdateEntry(context.Background(), e, e.Revision))
+
+	// a concurrent editor still holds revision one
+	assert.Equal(t, vanity.ErrConflict, be.UpdateEntry(context.Background(), e, 1))
+
+	e, err = be.GetEntry(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), e.Revision)
+	assert.Equal(t, "https://gitlab.com/livetribe/vanity", e.VCSPath)
+
+	assert.NoError(t, be.UpsertEntry(context.Background(), e))
+	e, err = be.GetEntry(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), e.Revision)
+}
+
+func TestInMemory_ListPage(t *testing.T) {
+	be := memory.NewInMemoryAPI()
+	be.AddEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
+	be.AddEntry("l7e.io/yama", "git", "https://github.com/livetribe/yama")
+	be.AddEntry("example.com/tool", "git", "https://github.com/example/tool")
+
+	var paths []string
+	consumer := vanity.EntryConsumerFunc(func(_ context.Context, e *vanity.Entry) {
+		paths = append(paths, e.ImportPath)
+	})
+
+	next, err := vanity.ListPage(context.Background(), be, vanity.ListOptions{Prefix: "l7e.io/", PageSize: 1, Order: vanity.Descending}, consumer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"l7e.io/yama"}, paths)
+
+	next, err = vanity.ListPage(context.Background(), be, vanity.ListOptions{Prefix: "l7e.io/", PageSize: 1, Order: vanity.Descending, PageToken: next}, consumer)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"l7e.io/yama", "l7e.io/vanity"}, paths)
+	assert.Empty(t, next)
+}
+
+func TestInMemory_Watch(t *testing.T) {
+	apitest.TestWatcher(t, newInMemory)
 }
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/go-redis/redis/v8"
+
+	"l7e.io/vanity"
+)
+
+func init() { //nolint:gochecknoinits
+	for _, scheme := range []string{"redis", "rediss"} {
+		vanity.RegisterBackend(scheme, openDSN)
+	}
+}
+
+// openDSN creates a Redis-based Backend from a data source name such as
+// "redis://:password@host:6379/0?prefix=vanity:", or "rediss://host" for TLS.
+func openDSN(_ context.Context, dsn *url.URL) (vanity.Backend, error) {
+	options, opts, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(options, opts...), nil
+}
+
+// parseDSN obtains the client options of a data source name, along with the
+// options set by its prefix query parameter.
+func parseDSN(dsn *url.URL) (*redis.Options, []BackendOption, error) {
+	u := *dsn
+	q := u.Query()
+
+	var opts []BackendOption
+	if p, ok := q["prefix"]; ok {
+		opts = append(opts, WithKeyPrefix(p[0]))
+	}
+
+	q.Del("prefix")
+	u.RawQuery = q.Encode()
+
+	options, err := redis.ParseURL(u.String())
+	if err != nil {
+		return nil, nil, vanity.ErrInvalidDSN
+	}
+
+	return options, opts, nil
+}
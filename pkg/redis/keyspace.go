@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"l7e.io/vanity"
+)
+
+// keyspaceTimeout is the time allowed to read the entry of a keyspace event.
+const keyspaceTimeout = 10 * time.Second
+
+// Watch delivers the changes notified by the keyspace events of the entry
+// keys, which the Redis server only publishes if its notify-keyspace-events
+// configuration includes the K, g and h classes, e.g. "Kgh".
+//
+// The keyspace events are subscribed to by the first watch, after which
+// watches can resume from the position of the events retained by the backend.
+func (s *redisBE) Watch(ctx context.Context, position string) (<-chan vanity.Event, error) {
+	if err := s.subscribe(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.events.Watch(ctx, position)
+}
+
+// subscribe subscribes to the keyspace events of the entry keys, unless
+// already subscribed.
+func (s *redisBE) subscribe(ctx context.Context) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return vanity.ErrAlreadyClosed
+	}
+	if s.pubsub != nil {
+		return nil
+	}
+
+	channel := "__keyspace@" + strconv.Itoa(s.client.Options().DB) + "__:"
+	pubsub := s.client.PSubscribe(ctx, escapePattern(channel+s.keyPrefix)+"*")
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+	s.pubsub = pubsub
+
+	go s.notify(pubsub.Channel(), len(channel))
+
+	return nil
+}
+
+// notify publishes the changes of the keyspace events received from c, whose
+// channels are the keys with a prefix of length skip.  As an update is notified
+// by several events, those of a revision already published are skipped.
+func (s *redisBE) notify(c <-chan *redis.Message, skip int) {
+	revisions := make(map[string]int64)
+
+	for msg := range c {
+		importPath := strings.TrimPrefix(msg.Channel[skip:], s.keyPrefix)
+
+		switch msg.Payload {
+		case "del", "expired", "evicted":
+			delete(revisions, importPath)
+			s.events.Publish(vanity.EventRemoved, importPath, nil)
+			continue
+		}
+
+		e, err := s.changed(importPath)
+		if err == vanity.ErrAlreadyClosed {
+			return
+		}
+		if err != nil {
+			// removed since, or to be notified again by its next event
+			continue
+		}
+
+		revision, known := revisions[importPath]
+		if known && revision == e.Revision {
+			continue
+		}
+		revisions[importPath] = e.Revision
+
+		t := vanity.EventUpdated
+		if e.Revision == 1 {
+			t = vanity.EventAdded
+		}
+		s.events.Publish(t, importPath, e)
+	}
+}
+
+func (s *redisBE) changed(importPath string) (*vanity.Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyspaceTimeout)
+	defer cancel()
+
+	return s.GetEntry(ctx, importPath)
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis
+
+const (
+	// DefaultKeyPrefix is the default prefix of the keys of the entries.
+	DefaultKeyPrefix = "vanity:"
+)
+
+type backendSettings struct {
+	keyPrefix string
+}
+
+// A BackendOption is an option for a Redis-based backend.
+type BackendOption interface {
+	Apply(*backendSettings)
+}
+
+// WithKeyPrefix configures the prefix of the keys of the entries; default is
+// "vanity:".  Every key with the prefix is expected to be an entry.
+func WithKeyPrefix(p string) BackendOption {
+	return withKeyPrefix{p}
+}
+
+type withKeyPrefix struct{ p string }
+
+func (w withKeyPrefix) Apply(o *backendSettings) {
+	o.keyPrefix = w.p
+}
@@ -0,0 +1,469 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package redis contains the Redis Backend, storing entries as hashes.
+package redis // import "l7e.io/vanity/pkg/redis"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"l7e.io/vanity"
+)
+
+const (
+	versionField        = "version"
+	revisionField       = "revision"
+	vcsField            = "vcs"
+	vcsPathField        = "vcs_path"
+	descriptionField    = "description"
+	ownerField          = "owner"
+	defaultBranchField  = "default_branch"
+	docURLField         = "doc_url"
+	sourceTemplateField = "source_template"
+	visibilityField     = "visibility"
+	labelsField         = "labels"
+	createdField        = "created"
+	updatedField        = "updated"
+
+	// scanCount is the number of keys hinted to SCAN, and fetched at once.
+	scanCount = 100
+
+	// maxAttempts is the number of times a transaction is attempted when its
+	// keys are changed concurrently.
+	maxAttempts = 10
+)
+
+// optionalFields are the fields only stored if not empty.
+var optionalFields = []string{
+	descriptionField, ownerField, defaultBranchField, docURLField,
+	sourceTemplateField, visibilityField, labelsField,
+}
+
+type redisBE struct {
+	client    *redis.Client
+	keyPrefix string
+	events    *vanity.Broadcaster
+
+	lock    sync.RWMutex
+	closed  bool
+	pubsub  *redis.PubSub
+	watched chan struct{}
+}
+
+// NewClient creates a Backend storing entries in the Redis server of the
+// client options, as hashes whose keys are the import paths with a prefix.
+func NewClient(options *redis.Options, opts ...BackendOption) vanity.EntryBackend {
+	s := &backendSettings{keyPrefix: DefaultKeyPrefix}
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	return &redisBE{
+		client:    redis.NewClient(options),
+		keyPrefix: s.keyPrefix,
+		events:    vanity.NewBroadcaster(vanity.DefaultRetainedEvents),
+	}
+}
+
+func (s *redisBE) key(importPath string) string {
+	return s.keyPrefix + importPath
+}
+
+func encodeEntry(e *vanity.Entry) (map[string]interface{}, []string, error) {
+	fields := map[string]interface{}{
+		versionField:        e.Version,
+		revisionField:       e.Revision,
+		vcsField:            e.VCS,
+		vcsPathField:        e.VCSPath,
+		descriptionField:    e.Description,
+		ownerField:          e.Owner,
+		defaultBranchField:  e.DefaultBranch,
+		docURLField:         e.DocURL,
+		sourceTemplateField: e.SourceTemplate,
+		visibilityField:     string(e.Visibility),
+		createdField:        e.Created.Format(time.RFC3339Nano),
+		updatedField:        e.Updated.Format(time.RFC3339Nano),
+	}
+
+	if len(e.Labels) > 0 {
+		labels, err := json.Marshal(e.Labels)
+		if err != nil {
+			return nil, nil, err
+		}
+		fields[labelsField] = string(labels)
+	}
+
+	var absent []string
+	for _, f := range optionalFields {
+		if v, ok := fields[f]; !ok || v == "" {
+			delete(fields, f)
+			absent = append(absent, f)
+		}
+	}
+
+	return fields, absent, nil
+}
+
+func decodeEntry(importPath string, fields map[string]string) (*vanity.Entry, error) {
+	e := &vanity.Entry{
+		ImportPath:     importPath,
+		VCS:            fields[vcsField],
+		VCSPath:        fields[vcsPathField],
+		Description:    fields[descriptionField],
+		Owner:          fields[ownerField],
+		DefaultBranch:  fields[defaultBranchField],
+		DocURL:         fields[docURLField],
+		SourceTemplate: fields[sourceTemplateField],
+		Visibility:     vanity.Visibility(fields[visibilityField]),
+	}
+
+	var err error
+	if e.Version, err = strconv.Atoi(fields[versionField]); err != nil {
+		return nil, fmt.Errorf(unableToExtractEntry, importPath, err)
+	}
+	if e.Revision, err = strconv.ParseInt(fields[revisionField], 10, 64); err != nil {
+		return nil, fmt.Errorf(unableToExtractEntry, importPath, err)
+	}
+	if e.Created, err = time.Parse(time.RFC3339Nano, fields[createdField]); err != nil {
+		return nil, fmt.Errorf(unableToExtractEntry, importPath, err)
+	}
+	if e.Updated, err = time.Parse(time.RFC3339Nano, fields[updatedField]); err != nil {
+		return nil, fmt.Errorf(unableToExtractEntry, importPath, err)
+	}
+	if l, ok := fields[labelsField]; ok {
+		if err = json.Unmarshal([]byte(l), &e.Labels); err != nil {
+			return nil, fmt.Errorf(unableToExtractEntry, importPath, err)
+		}
+	}
+
+	return e, nil
+}
+
+// checkClosed read-locks the backend, which is unlocked by the returned
+// function, unless it is closed.
+func (s *redisBE) checkClosed() (func(), error) {
+	s.lock.RLock()
+	if s.closed {
+		s.lock.RUnlock()
+		return nil, vanity.ErrAlreadyClosed
+	}
+
+	return s.lock.RUnlock, nil
+}
+
+// read fetches the entries of importPaths in a single round trip; those not
+// found are missing from the returned map.
+func (s *redisBE) read(ctx context.Context, c redis.Cmdable, importPaths []string) (map[string]*vanity.Entry, error) {
+	cmds := make([]*redis.StringStringMapCmd, len(importPaths))
+	_, err := c.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, importPath := range importPaths {
+			cmds[i] = p.HGetAll(ctx, s.key(importPath))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*vanity.Entry, len(importPaths))
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			continue
+		}
+
+		e, err := decodeEntry(importPaths[i], fields)
+		if err != nil {
+			return nil, err
+		}
+		entries[importPaths[i]] = e
+	}
+
+	return entries, nil
+}
+
+func (s *redisBE) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	s.events.Close()
+	if s.pubsub != nil {
+		_ = s.pubsub.Close()
+	}
+
+	return s.client.Close()
+}
+
+// Healthz pings the Redis server.
+func (s *redisBE) Healthz(ctx context.Context) error {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *redisBE) Get(ctx context.Context, importPath string) (vcs, vcsPath string, err error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *redisBE) GetEntry(ctx context.Context, importPath string) (*vanity.Entry, error) {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := s.read(ctx, s.client, []string{importPath})
+	if err != nil {
+		return nil, err
+	}
+
+	e, ok := entries[importPath]
+	if !ok {
+		return nil, vanity.ErrNotFound
+	}
+
+	return e, nil
+}
+
+// GetPrefix fetches all the prefixes of path in a single round trip.
+func (s *redisBE) GetPrefix(ctx context.Context, path string) (*vanity.Entry, error) {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	prefixes := vanity.Prefixes(path)
+	entries, err := s.read(ctx, s.client, prefixes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range prefixes {
+		if e, ok := entries[candidate]; ok {
+			return e, nil
+		}
+	}
+
+	return nil, vanity.ErrNotFound
+}
+
+func (s *redisBE) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return s.InsertEntry(ctx, vanity.NewEntry(importPath, vcs, vcsPath))
+}
+
+func (s *redisBE) InsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.single(ctx, vanity.InsertMutation(entry))
+}
+
+func (s *redisBE) UpdateEntry(ctx context.Context, entry *vanity.Entry, ifRevision int64) error {
+	return s.single(ctx, vanity.UpdateMutation(entry, ifRevision))
+}
+
+func (s *redisBE) UpsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.single(ctx, vanity.UpsertMutation(entry))
+}
+
+func (s *redisBE) Remove(ctx context.Context, importPath string) error {
+	return s.single(ctx, vanity.RemoveMutation(importPath))
+}
+
+// single applies a single mutation, returning the reason it failed.
+func (s *redisBE) single(ctx context.Context, m vanity.Mutation) error {
+	err := s.Apply(ctx, []vanity.Mutation{m})
+	if me, ok := err.(*vanity.MutationError); ok {
+		return me.Err
+	}
+
+	return err
+}
+
+// Apply applies the mutations in a MULTI/EXEC transaction, which is retried
+// if the keys of the mutations are changed while it is prepared; ErrConflict
+// is returned if they keep changing.
+func (s *redisBE) Apply(ctx context.Context, mutations []vanity.Mutation) error {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var importPaths, keys []string
+	seen := make(map[string]bool)
+	for _, m := range mutations {
+		if m.Entry == nil || seen[m.Entry.ImportPath] {
+			continue
+		}
+		seen[m.Entry.ImportPath] = true
+		importPaths = append(importPaths, m.Entry.ImportPath)
+		keys = append(keys, s.key(m.Entry.ImportPath))
+	}
+
+	for i := 0; i < maxAttempts; i++ {
+		err = s.client.Watch(ctx, func(tx *redis.Tx) error {
+			return s.apply(ctx, tx, importPaths, mutations)
+		}, keys...)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+
+	return vanity.ErrConflict
+}
+
+func (s *redisBE) apply(ctx context.Context, tx *redis.Tx, importPaths []string, mutations []vanity.Mutation) error {
+	entries, err := s.read(ctx, tx, importPaths)
+	if err != nil {
+		return err
+	}
+
+	existed := make(map[string]bool, len(entries))
+	for importPath := range entries {
+		existed[importPath] = true
+	}
+
+	if err := vanity.ApplyMutations(entries, mutations, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for _, importPath := range importPaths {
+			e := entries[importPath]
+			if e == nil {
+				if existed[importPath] {
+					p.Del(ctx, s.key(importPath))
+				}
+				continue
+			}
+
+			fields, absent, err := encodeEntry(e)
+			if err != nil {
+				return err
+			}
+			p.HSet(ctx, s.key(importPath), fields)
+			// HDEL requires at least one field
+			if existed[importPath] && len(absent) > 0 {
+				p.HDel(ctx, s.key(importPath), absent...)
+			}
+		}
+		return nil
+	})
+
+	return err
+}
+
+func (s *redisBE) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+// ListEntries scans the keys with the prefix, listing their entries in import
+// path order.  Entries changed during the scan may or may not be listed.
+func (s *redisBE) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	unlock, err := s.checkClosed()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	seen := make(map[string]bool)
+	var importPaths []string
+
+	match := escapePattern(s.keyPrefix) + "*"
+	var cursor uint64
+	for {
+		var keys []string
+		keys, cursor, err = s.client.Scan(ctx, cursor, match, scanCount).Result()
+		if err != nil {
+			return err
+		}
+
+		// SCAN may return a key more than once
+		for _, k := range keys {
+			importPath := strings.TrimPrefix(k, s.keyPrefix)
+			if !seen[importPath] {
+				seen[importPath] = true
+				importPaths = append(importPaths, importPath)
+			}
+		}
+
+		if cursor == 0 {
+			break
+		}
+	}
+
+	sort.Strings(importPaths)
+
+	for len(importPaths) > 0 {
+		n := len(importPaths)
+		if n > scanCount {
+			n = scanCount
+		}
+
+		entries, err := s.read(ctx, s.client, importPaths[:n])
+		if err != nil {
+			return err
+		}
+
+		for _, importPath := range importPaths[:n] {
+			if e, ok := entries[importPath]; ok {
+				consumer.Consume(ctx, e)
+			}
+		}
+
+		importPaths = importPaths[n:]
+	}
+
+	return nil
+}
+
+// escapePattern escapes the special characters of glob-style patterns.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
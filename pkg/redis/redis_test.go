@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redis_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/pkg/redis"
+)
+
+func newRedis(t *testing.T, opts ...redis.BackendOption) (*miniredis.Miniredis, vanity.EntryBackend, func()) {
+	m, err := miniredis.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	be := redis.NewClient(&goredis.Options{Addr: m.Addr()}, opts...)
+
+	return m, be, func() {
+		_ = be.Close()
+		m.Close()
+	}
+}
+
+func TestRedis(t *testing.T) {
+	apitest.TestEntryBackend(t, func(t *testing.T) (vanity.EntryBackend, func()) {
+		_, be, done := newRedis(t)
+		return be, done
+	})
+}
+
+func TestRedis_hashes(t *testing.T) {
+	m, be, done := newRedis(t, redis.WithKeyPrefix("urls/"))
+	defer done()
+
+	ctx := context.Background()
+
+	in := vanity.NewEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
+	in.Description = "Vanity URLs"
+	in.Visibility = vanity.VisibilityPublic
+	in.Labels = map[string]string{"team": "platform"}
+	assert.NoError(t, be.InsertEntry(ctx, in))
+	assert.Equal(t, "https://github.com/livetribe/vanity", m.HGet("urls/l7e.io/vanity", "vcs_path"))
+
+	e, err := be.GetEntry(ctx, "l7e.io/vanity")
+	assert.NoError(t, err)
+	e.Description = ""
+	e.Labels = nil
+	assert.NoError(t, be.UpdateEntry(ctx, e, 1))
+
+	// cleared fields are removed from the hash
+	fields, err := m.HKeys("urls/l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"created", "revision", "updated", "vcs", "vcs_path", "version", "visibility"}, fields)
+
+	assert.NoError(t, be.Remove(ctx, "l7e.io/vanity"))
+	assert.False(t, m.Exists("urls/l7e.io/vanity"))
+
+	assert.NoError(t, be.Add(ctx, "m4o.io/pbf", "git", "https://github.com/magurl/pbf"))
+	m.HSet("urls/m4o.io/pbf", "revision", "two")
+	_, err = be.GetEntry(ctx, "m4o.io/pbf")
+	assert.Error(t, err)
+}
+
+func TestRedis_List(t *testing.T) {
+	m, be, done := newRedis(t)
+	defer done()
+
+	ctx := context.Background()
+
+	var expected []string
+	for _, importPath := range []string{"m4o.io/pbf", "l7e.io/yama", "l7e.io/vanity", "example.com/[tool]"} {
+		assert.NoError(t, be.Add(ctx, importPath, "git", "https://github.com/"+importPath))
+	}
+	for i := 0; i < 150; i++ {
+		importPath := "example.com/" + string(rune('a'+i/26)) + string(rune('a'+i%26))
+		assert.NoError(t, be.Add(ctx, importPath, "git", "https://github.com/"+importPath))
+		expected = append(expected, importPath)
+	}
+	expected = append([]string{"example.com/[tool]"}, expected...)
+	expected = append(expected, "l7e.io/vanity", "l7e.io/yama", "m4o.io/pbf")
+
+	// keys without the prefix are not listed
+	assert.NoError(t, m.Set("other", "value"))
+
+	var paths []string
+	err := be.ListEntries(ctx, vanity.EntryConsumerFunc(func(_ context.Context, e *vanity.Entry) {
+		paths = append(paths, e.ImportPath)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, paths)
+}
+
+func TestRedis_Watch(t *testing.T) {
+	m, be, done := newRedis(t)
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := be.(vanity.Watcher).Watch(ctx, "")
+	assert.NoError(t, err)
+
+	// miniredis does not publish keyspace events
+	const channel = "__keyspace@0__:vanity:l7e.io/vanity"
+
+	assert.NoError(t, be.Add(ctx, "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+	m.Publish(channel, "hset")
+	assert.NoError(t, be.UpsertEntry(ctx, vanity.NewEntry("l7e.io/vanity", "git", "https://gitlab.com/livetribe/vanity")))
+	m.Publish(channel, "hset")
+	m.Publish(channel, "hdel")
+	assert.NoError(t, be.Remove(ctx, "l7e.io/vanity"))
+	m.Publish(channel, "del")
+
+	for _, expected := range []vanity.EventType{vanity.EventAdded, vanity.EventUpdated, vanity.EventRemoved} {
+		e := <-events
+		assert.Equal(t, expected, e.Type)
+		assert.Equal(t, "l7e.io/vanity", e.ImportPath)
+	}
+
+	assert.NoError(t, be.Close())
+
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+func TestRedis_Open(t *testing.T) {
+	m, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer m.Close()
+
+	be, err := vanity.Open(context.Background(), "redis://"+m.Addr()+"/0?prefix=urls:")
+	assert.NoError(t, err)
+	defer func() { _ = be.Close() }()
+
+	assert.NoError(t, be.Add(context.Background(), "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+	assert.True(t, m.Exists("urls:l7e.io/vanity"))
+
+	_, err = vanity.Open(context.Background(), "redis://"+m.Addr()+"/zero")
+	assert.Equal(t, vanity.ErrInvalidDSN, err)
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+/*
+Package replica provides a Backend decorator serving lookups from a full
+in-memory snapshot of the configurations of a backend.
+
+The snapshot is refreshed periodically, so lookups never reach the decorated
+backend and keep being served from the last snapshot while it is unavailable.
+Changes made through the decorator are made to the decorated backend, and are
+served once the next snapshot is taken.
+
+
+Creating a Replica
+
+To serve the configurations of a Spanner-based backend from memory:
+
+    be, err := spanner.NewClient(ctx, "projects/p/instances/i/databases/d")
+    if err != nil {
+        return err
+    }
+
+    r := replica.NewReplica(be, replica.WithInterval(30*time.Second))
+    defer r.Close()
+
+Closing the replica closes the decorated backend.
+
+
+Readiness
+
+Until the first snapshot is taken lookups return vanity.ErrNotReady, as does
+the Readyz method of the replica, which implements vanity.ReadinessChecker.
+
+
+Metrics
+
+The age of the snapshot is tracked by the Prometheus gauge SnapshotAge; the
+snapshots taken, and failed, by the Prometheus counters Snapshots and
+SnapshotErrors.
+
+*/
+package replica // import "l7e.io/vanity/pkg/replica"
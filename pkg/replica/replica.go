@@ -0,0 +1,347 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package replica
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"l7e.io/vanity"
+)
+
+const (
+	// DefaultInterval is the default interval between snapshots.
+	DefaultInterval = time.Minute
+
+	// initialRetry is the longest interval between attempts to take the
+	// first snapshot.
+	initialRetry = time.Second
+)
+
+var (
+	// lastSnapshot is when the latest snapshot of any replica was taken, in
+	// nanoseconds since the epoch.
+	lastSnapshot int64
+
+	// SnapshotAge is a Prometheus gauge that tracks the age, in seconds, of the
+	// latest snapshot taken by a replica; zero until the first snapshot.
+	SnapshotAge = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "vanity",
+		Subsystem: "replica",
+		Name:      "snapshot_age_seconds",
+		Help:      "The age of the latest replica snapshot in seconds",
+	}, func() float64 {
+		t := atomic.LoadInt64(&lastSnapshot)
+		if t == 0 {
+			return 0
+		}
+		return time.Since(time.Unix(0, t)).Seconds()
+	})
+
+	// Snapshots is a Prometheus counter that tracks the total snapshots taken.
+	Snapshots = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "replica",
+		Name:      "snapshots_total",
+		Help:      "The total replica snapshots",
+	})
+
+	// SnapshotErrors is a Prometheus counter that tracks the total snapshots
+	// that failed.
+	SnapshotErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "replica",
+		Name:      "snapshot_errors_total",
+		Help:      "The total replica snapshot errors",
+	})
+)
+
+type settings struct {
+	interval time.Duration
+}
+
+// An Option is an option for a replica Backend.
+type Option interface {
+	Apply(*settings)
+}
+
+// WithInterval configures the interval between snapshots; default is
+// DefaultInterval.
+func WithInterval(interval time.Duration) Option {
+	return intervalOption{interval: interval}
+}
+
+type intervalOption struct{ interval time.Duration }
+
+func (i intervalOption) Apply(o *settings) {
+	o.interval = i.interval
+}
+
+// snapshot is a snapshot of the configurations of a backend, which is stale
+// if the backend reported them stale through vanity.MarkStale.
+type snapshot struct {
+	entries map[string]*vanity.Entry
+	stale   bool
+}
+
+type replicaBE struct {
+	be       vanity.EntryBackend
+	interval time.Duration
+
+	// latest is the latest *snapshot, which is never modified once stored
+	latest atomic.Value
+	ready  chan struct{}
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewReplica creates a Backend serving vanity URL configurations from an
+// in-memory replica of be, using the specified options.  The replica is a
+// snapshot of the configurations listed by be, replaced every interval.
+//
+// Until the first snapshot is taken, lookups return vanity.ErrNotReady, as
+// does Readyz.  Changes are made to be, and are served once the next
+// snapshot is taken.
+func NewReplica(be vanity.Backend, options ...Option) vanity.EntryBackend {
+	s := settings{interval: DefaultInterval}
+	for _, o := range options {
+		o.Apply(&s)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &replicaBE{
+		be:       vanity.AsEntryBackend(be),
+		interval: s.interval,
+		ready:    make(chan struct{}),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	go r.run(ctx)
+
+	return r
+}
+
+func (s *replicaBE) run(ctx context.Context) {
+	defer close(s.done)
+
+	loaded := false
+	retry := initialRetry
+	for {
+		next := s.interval
+		if s.take(ctx) == nil {
+			loaded = true
+		} else if !loaded && retry < next {
+			// retry the first snapshot sooner, backing off
+			next = retry
+			retry *= 2
+		}
+
+		timer := time.NewTimer(next)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// take lists the configurations of the backend, replacing the snapshot.
+func (s *replicaBE) take(ctx context.Context) error {
+	ctx, stale := vanity.WithStaleReport(ctx)
+	entries := make(map[string]*vanity.Entry)
+	err := s.be.ListEntries(ctx, vanity.EntryConsumerFunc(func(_ context.Context, e *vanity.Entry) {
+		entries[e.ImportPath] = e
+	}))
+	if err != nil {
+		SnapshotErrors.Inc()
+		return err
+	}
+
+	s.latest.Store(&snapshot{entries: entries, stale: stale()})
+	atomic.StoreInt64(&lastSnapshot, time.Now().UnixNano())
+	Snapshots.Inc()
+
+	select {
+	case <-s.ready:
+	default:
+		close(s.ready)
+	}
+
+	return nil
+}
+
+// current returns the configurations of the latest snapshot, marking ctx
+// stale if the snapshot is.
+func (s *replicaBE) current(ctx context.Context) (map[string]*vanity.Entry, error) {
+	latest, ok := s.latest.Load().(*snapshot)
+	if !ok {
+		return nil, vanity.ErrNotReady
+	}
+
+	if latest.stale {
+		vanity.MarkStale(ctx)
+	}
+
+	return latest.entries, nil
+}
+
+func (s *replicaBE) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		<-s.done
+	})
+
+	return s.be.Close()
+}
+
+func (s *replicaBE) Get(ctx context.Context, importPath string) (string, string, error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *replicaBE) GetEntry(ctx context.Context, importPath string) (*vanity.Entry, error) {
+	entries, err := s.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	e, found := entries[importPath]
+	if !found {
+		return nil, vanity.ErrNotFound
+	}
+	return e.Clone(), nil
+}
+
+func (s *replicaBE) GetPrefix(ctx context.Context, path string) (*vanity.Entry, error) {
+	entries, err := s.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, importPath := range vanity.Prefixes(path) {
+		if e, found := entries[importPath]; found {
+			return e.Clone(), nil
+		}
+	}
+	return nil, vanity.ErrNotFound
+}
+
+func (s *replicaBE) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return s.be.Add(ctx, importPath, vcs, vcsPath)
+}
+
+func (s *replicaBE) InsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.be.InsertEntry(ctx, entry)
+}
+
+func (s *replicaBE) UpdateEntry(ctx context.Context, entry *vanity.Entry, ifRevision int64) error {
+	return s.be.UpdateEntry(ctx, entry, ifRevision)
+}
+
+func (s *replicaBE) UpsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.be.UpsertEntry(ctx, entry)
+}
+
+func (s *replicaBE) Apply(ctx context.Context, mutations []vanity.Mutation) error {
+	return vanity.Apply(ctx, s.be, mutations)
+}
+
+func (s *replicaBE) Remove(ctx context.Context, importPath string) error {
+	return s.be.Remove(ctx, importPath)
+}
+
+func (s *replicaBE) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+func (s *replicaBE) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	entries, err := s.current(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		consumer.Consume(ctx, e.Clone())
+	}
+
+	return nil
+}
+
+func (s *replicaBE) ListPage(ctx context.Context, opts vanity.ListOptions, consumer vanity.EntryConsumer) (string, error) {
+	entries, err := s.current(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	c := make([]*vanity.Entry, 0, len(entries))
+	for _, e := range entries {
+		c = append(c, e)
+	}
+
+	page, next, err := vanity.PageEntries(c, opts)
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range page {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		consumer.Consume(ctx, e.Clone())
+	}
+
+	return next, nil
+}
+
+// Healthz reports the replica healthy, even if the backend is not, as it
+// keeps serving the latest snapshot.
+func (s *replicaBE) Healthz(_ context.Context) error {
+	return nil
+}
+
+// Readyz returns vanity.ErrNotReady until the first snapshot is taken.
+func (s *replicaBE) Readyz(_ context.Context) error {
+	select {
+	case <-s.ready:
+		return nil
+	default:
+		return vanity.ErrNotReady
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package replica_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/pkg/memory"
+	"l7e.io/vanity/pkg/replica"
+)
+
+func eventually(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReplica(t *testing.T) {
+	source := memory.NewInMemoryAPI()
+	source.AddEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
+
+	be := replica.NewReplica(source, replica.WithInterval(10*time.Millisecond))
+	defer func() { _ = be.Close() }()
+
+	rc := be.(vanity.ReadinessChecker)
+	eventually(t, func() bool { return rc.Readyz(context.Background()) == nil })
+
+	e, err := vanity.GetPrefix(context.Background(), be, "l7e.io/vanity/cmd/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/livetribe/vanity", e.VCSPath)
+
+	// changes are served once the next snapshot is taken
+	assert.NoError(t, be.Add(context.Background(), "l7e.io/yama", "git", "https://github.com/livetribe/yama"))
+	eventually(t, func() bool {
+		_, _, err := be.Get(context.Background(), "l7e.io/yama")
+		return err == nil
+	})
+
+	var paths []string
+	_, err = vanity.ListPage(context.Background(), be, vanity.ListOptions{}, vanity.EntryConsumerFunc(func(_ context.Context, e *vanity.Entry) {
+		paths = append(paths, e.ImportPath)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"l7e.io/vanity", "l7e.io/yama"}, paths)
+
+	m := &dto.Metric{}
+	assert.NoError(t, replica.SnapshotAge.Write(m))
+	assert.True(t, m.Gauge.GetValue() >= 0)
+}
+
+func TestReplica_notReady(t *testing.T) {
+	source := &apitest.MockBackend{Healthy: fmt.Errorf("unavailable")}
+
+	be := replica.NewReplica(source, replica.WithInterval(time.Hour))
+	defer func() { _ = be.Close() }()
+
+	assert.Equal(t, vanity.ErrNotReady, be.(vanity.ReadinessChecker).Readyz(context.Background()))
+	assert.NoError(t, be.Healthz(context.Background()))
+
+	_, _, err := be.Get(context.Background(), "l7e.io/vanity")
+	assert.Equal(t, vanity.ErrNotReady, err)
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+
+/*
+Package snapshot provides a Backend decorator serving vanity URL
+configurations from a snapshot file while the decorated backend fails.
+
+The configurations of the backend are listed periodically and written to the
+snapshot file, which is read when the decorator is created, so a backend that
+is unreachable from the start is served from the snapshot of a previous run.
+Lookups and listings served from the snapshot are reported stale through
+vanity.MarkStale, which the vanity.Handler reflects in a Warning header.
+
+
+Creating a Snapshot Backend
+
+To keep serving a Spanner-based backend during outages:
+
+    be, err := spanner.NewClient(ctx, "projects/p/instances/i/databases/d")
+    if err != nil {
+        return err
+    }
+
+    sb, err := snapshot.NewSnapshotBackend(be, "/var/lib/vanity/snapshot.json",
+        snapshot.WithInterval(5*time.Minute))
+    if err != nil {
+        return err
+    }
+    defer sb.Close()
+
+Closing the snapshot backend closes the decorated backend.
+
+
+Metrics
+
+The snapshot files written, the snapshots that failed, and the reads served
+from the snapshot are tracked by the Prometheus counters Writes, WriteErrors
+and StaleReads.
+
+*/
+package snapshot // import "l7e.io/vanity/pkg/snapshot"
@@ -0,0 +1,366 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"l7e.io/vanity"
+)
+
+const (
+	// DefaultInterval is the default interval between snapshots.
+	DefaultInterval = time.Minute
+
+	// fileVersion is the current version of the snapshot file format.
+	fileVersion = 1
+)
+
+var (
+	errUnknownVersion = fmt.Errorf("unknown snapshot file version")
+
+	// Writes is a Prometheus counter that tracks the total snapshot files
+	// written.
+	Writes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "snapshot",
+		Name:      "writes_total",
+		Help:      "The total snapshot files written",
+	})
+
+	// WriteErrors is a Prometheus counter that tracks the total snapshots that
+	// could not be taken or written.
+	WriteErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "snapshot",
+		Name:      "write_errors_total",
+		Help:      "The total snapshot errors",
+	})
+
+	// StaleReads is a Prometheus counter that tracks the total lookups and
+	// listings served from the snapshot because the backend failed.
+	StaleReads = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "snapshot",
+		Name:      "stale_reads_total",
+		Help:      "The total reads served from the snapshot",
+	})
+)
+
+// file is the content of a snapshot file.
+type file struct {
+	Version int
+	Taken   time.Time
+	Entries []*vanity.Entry
+}
+
+type settings struct {
+	interval time.Duration
+}
+
+// An Option is an option for a snapshot Backend.
+type Option interface {
+	Apply(*settings)
+}
+
+// WithInterval configures the interval between snapshots; default is
+// DefaultInterval.
+func WithInterval(interval time.Duration) Option {
+	return intervalOption{interval: interval}
+}
+
+type intervalOption struct{ interval time.Duration }
+
+func (i intervalOption) Apply(o *settings) {
+	o.interval = i.interval
+}
+
+type snapshotBE struct {
+	be       vanity.EntryBackend
+	path     string
+	interval time.Duration
+
+	// entries is the latest snapshot, a map[string]*vanity.Entry which is
+	// never modified once stored
+	entries atomic.Value
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSnapshotBackend creates a Backend decorating be, which writes a snapshot
+// of the configurations listed by be to the file at path every interval, using
+// the specified options.
+//
+// When be fails, lookups and listings are served from the latest snapshot,
+// which is read from path on creation if be has not been listed yet, and are
+// reported stale through vanity.MarkStale.  A malformed snapshot file is an
+// error; a missing one is not.
+func NewSnapshotBackend(be vanity.Backend, path string, options ...Option) (vanity.EntryBackend, error) {
+	s := settings{interval: DefaultInterval}
+	for _, o := range options {
+		o.Apply(&s)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sb := &snapshotBE{
+		be:       vanity.AsEntryBackend(be),
+		path:     path,
+		interval: s.interval,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	if err := sb.read(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go sb.run(ctx)
+
+	return sb, nil
+}
+
+// read loads the snapshot file, if any.
+func (s *snapshotBE) read() error {
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var f file
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	if f.Version != fileVersion {
+		return errUnknownVersion
+	}
+
+	entries := make(map[string]*vanity.Entry, len(f.Entries))
+	for _, e := range f.Entries {
+		entries[e.ImportPath] = e
+	}
+	s.entries.Store(entries)
+
+	return nil
+}
+
+func (s *snapshotBE) run(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		if err := s.write(ctx); err != nil {
+			WriteErrors.Inc()
+		}
+
+		timer := time.NewTimer(s.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// write lists the configurations of the backend, replacing the snapshot and
+// its file.  The file is replaced atomically, so a failed write never leaves
+// it partially written.
+func (s *snapshotBE) write(ctx context.Context) error {
+	f := file{Version: fileVersion, Taken: time.Now()}
+	entries := make(map[string]*vanity.Entry)
+	err := s.be.ListEntries(ctx, vanity.EntryConsumerFunc(func(_ context.Context, e *vanity.Entry) {
+		entries[e.ImportPath] = e
+		f.Entries = append(f.Entries, e)
+	}))
+	if err != nil {
+		return err
+	}
+
+	s.entries.Store(entries)
+
+	b, err := json.Marshal(&f)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err = tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	Writes.Inc()
+
+	return nil
+}
+
+// stale returns the latest snapshot if err is a failure of the backend the
+// snapshot can be served instead of, marking ctx stale.  Backends timing out
+// are failing, while canceled callers are not served.
+func (s *snapshotBE) stale(ctx context.Context, err error) (map[string]*vanity.Entry, bool) {
+	if err == nil || err == vanity.ErrNotFound || ctx.Err() == context.Canceled {
+		return nil, false
+	}
+
+	entries, ok := s.entries.Load().(map[string]*vanity.Entry)
+	if !ok {
+		return nil, false
+	}
+
+	StaleReads.Inc()
+	vanity.MarkStale(ctx)
+
+	return entries, true
+}
+
+func (s *snapshotBE) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		<-s.done
+	})
+
+	return s.be.Close()
+}
+
+func (s *snapshotBE) Get(ctx context.Context, importPath string) (string, string, error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *snapshotBE) GetEntry(ctx context.Context, importPath string) (*vanity.Entry, error) {
+	e, err := s.be.GetEntry(ctx, importPath)
+	entries, ok := s.stale(ctx, err)
+	if !ok {
+		return e, err
+	}
+
+	if e, found := entries[importPath]; found {
+		return e.Clone(), nil
+	}
+	return nil, vanity.ErrNotFound
+}
+
+func (s *snapshotBE) GetPrefix(ctx context.Context, path string) (*vanity.Entry, error) {
+	e, err := vanity.GetPrefix(ctx, s.be, path)
+	entries, ok := s.stale(ctx, err)
+	if !ok {
+		return e, err
+	}
+
+	for _, importPath := range vanity.Prefixes(path) {
+		if e, found := entries[importPath]; found {
+			return e.Clone(), nil
+		}
+	}
+	return nil, vanity.ErrNotFound
+}
+
+func (s *snapshotBE) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return s.be.Add(ctx, importPath, vcs, vcsPath)
+}
+
+func (s *snapshotBE) InsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.be.InsertEntry(ctx, entry)
+}
+
+func (s *snapshotBE) UpdateEntry(ctx context.Context, entry *vanity.Entry, ifRevision int64) error {
+	return s.be.UpdateEntry(ctx, entry, ifRevision)
+}
+
+func (s *snapshotBE) UpsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.be.UpsertEntry(ctx, entry)
+}
+
+func (s *snapshotBE) Apply(ctx context.Context, mutations []vanity.Mutation) error {
+	return vanity.Apply(ctx, s.be, mutations)
+}
+
+func (s *snapshotBE) Remove(ctx context.Context, importPath string) error {
+	return s.be.Remove(ctx, importPath)
+}
+
+func (s *snapshotBE) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+// ListEntries lists the configurations of the backend, or those of the
+// snapshot if the backend fails before listing any.
+func (s *snapshotBE) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	listed := false
+	err := s.be.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		listed = true
+		consumer.Consume(ctx, e)
+	}))
+	if listed {
+		return err
+	}
+
+	entries, ok := s.stale(ctx, err)
+	if !ok {
+		return err
+	}
+
+	for _, e := range entries {
+		consumer.Consume(ctx, e.Clone())
+	}
+
+	return nil
+}
+
+// Healthz reports the backend healthy while a snapshot can be served instead.
+func (s *snapshotBE) Healthz(ctx context.Context) error {
+	err := s.be.Healthz(ctx)
+	if err != nil {
+		if _, ok := s.entries.Load().(map[string]*vanity.Entry); ok {
+			return nil
+		}
+	}
+
+	return err
+}
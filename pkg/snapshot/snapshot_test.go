@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package snapshot_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/pkg/memory"
+	"l7e.io/vanity/pkg/snapshot"
+)
+
+var errUnavailable = fmt.Errorf("unavailable")
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "snapshot.json")
+
+	// snapshot a healthy backend
+	source := memory.NewInMemoryAPI()
+	source.AddEntry("l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
+
+	be, err := snapshot.NewSnapshotBackend(source, path, snapshot.WithInterval(10*time.Millisecond))
+	assert.NoError(t, err)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for _, err := os.Stat(path); err != nil; _, err = os.Stat(path) {
+		if time.Now().After(deadline) {
+			t.Fatal("snapshot not written in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, stale := vanity.WithStaleReport(context.Background())
+	e, err := vanity.GetPrefix(ctx, be, "l7e.io/vanity/cmd")
+	assert.NoError(t, err)
+	assert.Equal(t, "l7e.io/vanity", e.ImportPath)
+	assert.False(t, stale())
+	assert.NoError(t, be.Close())
+
+	// serve the snapshot of a backend unavailable from the start
+	be, err = snapshot.NewSnapshotBackend(&apitest.MockBackend{Healthy: errUnavailable}, path, snapshot.WithInterval(time.Hour))
+	assert.NoError(t, err)
+	defer func() { _ = be.Close() }()
+
+	assert.NoError(t, be.Healthz(context.Background()))
+
+	ctx, stale = vanity.WithStaleReport(context.Background())
+	e, err = vanity.GetPrefix(ctx, be, "l7e.io/vanity/cmd")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/livetribe/vanity", e.VCSPath)
+	assert.True(t, stale())
+
+	vcs, vcsPath, err := be.Get(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+	assert.Equal(t, "git", vcs)
+	assert.Equal(t, "https://github.com/livetribe/vanity", vcsPath)
+
+	_, _, err = be.Get(context.Background(), "l7e.io/yama")
+	assert.Equal(t, vanity.ErrNotFound, err)
+
+	var listed []string
+	err = be.List(context.Background(), vanity.ConsumerFunc(func(_ context.Context, importPath, _, _ string) {
+		listed = append(listed, importPath)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"l7e.io/vanity"}, listed)
+
+	// changes are not served from the snapshot
+	assert.Equal(t, errUnavailable, be.Add(context.Background(), "l7e.io/yama", "git", "https://github.com/livetribe/yama"))
+}
+
+func TestSnapshot_noSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	be, err := snapshot.NewSnapshotBackend(&apitest.MockBackend{Healthy: errUnavailable}, filepath.Join(dir, "snapshot.json"))
+	assert.NoError(t, err)
+	defer func() { _ = be.Close() }()
+
+	assert.Equal(t, errUnavailable, be.Healthz(context.Background()))
+
+	_, _, err = be.Get(context.Background(), "l7e.io/vanity")
+	assert.Equal(t, errUnavailable, err)
+}
+
+func TestSnapshot_malformed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "snapshot.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"Version": 2}`), 0600))
+
+	_, err = snapshot.NewSnapshotBackend(&apitest.MockBackend{}, path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialects of the supported databases, which are also the names of the
+// database/sql drivers used.
+const (
+	SQLite     = "sqlite3"
+	PostgreSQL = "postgres"
+	MySQL      = "mysql"
+)
+
+// dialect describes the differences between the supported databases.
+type dialect struct {
+	// numbered is true if query placeholders are numbered, e.g. $1, rather
+	// than question marks.
+	numbered bool
+
+	// migrations are the schema changes of each version, in order, which are
+	// formatted with the table name.
+	migrations [][]string
+}
+
+var dialects = map[string]*dialect{
+	SQLite: {
+		migrations: [][]string{
+			{
+				`CREATE TABLE %[1]s (
+    import_path TEXT NOT NULL PRIMARY KEY,
+    vcs TEXT NOT NULL,
+    vcs_path TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    description TEXT,
+    owner TEXT,
+    default_branch TEXT,
+    doc_url TEXT,
+    source_template TEXT,
+    visibility TEXT,
+    labels TEXT,
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL
+)`,
+			},
+		},
+	},
+	PostgreSQL: {
+		numbered: true,
+		migrations: [][]string{
+			{
+				`CREATE TABLE %[1]s (
+    import_path TEXT COLLATE "C" NOT NULL PRIMARY KEY,
+    vcs TEXT NOT NULL,
+    vcs_path TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    revision BIGINT NOT NULL,
+    description TEXT,
+    owner TEXT,
+    default_branch TEXT,
+    doc_url TEXT,
+    source_template TEXT,
+    visibility TEXT,
+    labels TEXT,
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL
+)`,
+			},
+		},
+	},
+	MySQL: {
+		migrations: [][]string{
+			{
+				`CREATE TABLE %[1]s (
+    import_path VARCHAR(512) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL PRIMARY KEY,
+    vcs TEXT NOT NULL,
+    vcs_path TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    revision BIGINT NOT NULL,
+    description TEXT,
+    owner TEXT,
+    default_branch TEXT,
+    doc_url TEXT,
+    source_template TEXT,
+    visibility TEXT,
+    labels TEXT,
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL
+) CHARACTER SET utf8mb4`,
+			},
+		},
+	},
+}
+
+// rebind replaces the question mark placeholders of query with those of the
+// dialect.
+func (d *dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
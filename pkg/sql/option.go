@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sql
+
+import (
+	"time"
+
+	"l7e.io/vanity"
+)
+
+// DefaultTable is the default table name.
+const DefaultTable = "urls"
+
+type backendSettings struct {
+	table        string
+	pollInterval time.Duration
+	migrate      bool
+}
+
+func collectSettings(opts ...BackendOption) *backendSettings {
+	s := &backendSettings{
+		table:        DefaultTable,
+		pollInterval: vanity.DefaultPollInterval,
+		migrate:      true,
+	}
+	for _, o := range opts {
+		o.Apply(s)
+	}
+
+	return s
+}
+
+// A BackendOption is an option for a SQL-based backend.
+type BackendOption interface {
+	Apply(*backendSettings)
+}
+
+// WithTable configures the table; default is "urls".  The schema version of
+// the table is kept in a table named after it with a "_schema" suffix.
+func WithTable(t string) BackendOption {
+	return withTable{t}
+}
+
+type withTable struct{ t string }
+
+func (w withTable) Apply(o *backendSettings) {
+	o.table = w.t
+}
+
+// WithPollInterval configures the interval between the reads of the table
+// when watching for changes; default is vanity.DefaultPollInterval.
+func WithPollInterval(d time.Duration) BackendOption {
+	return withPollInterval{d}
+}
+
+type withPollInterval struct{ d time.Duration }
+
+func (w withPollInterval) Apply(o *backendSettings) {
+	o.pollInterval = w.d
+}
+
+// WithoutMigration disables the migration of the schema when the backend is
+// created, for databases whose schema is managed separately.
+func WithoutMigration() BackendOption {
+	return withoutMigration{}
+}
+
+type withoutMigration struct{}
+
+func (w withoutMigration) Apply(o *backendSettings) {
+	o.migrate = false
+}
@@ -0,0 +1,607 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sql contains the database/sql Backend, supporting the SQLite,
+// PostgreSQL and MySQL dialects.
+package sql // import "l7e.io/vanity/pkg/sql"
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"l7e.io/vanity"
+)
+
+const (
+	importPathColumn = "import_path"
+	revisionColumn   = "revision"
+)
+
+// entryColumns are the columns of an entry, in the order they are decoded by
+// decodeEntry and encoded by encodeEntry.
+var entryColumns = []string{
+	importPathColumn,
+	"vcs",
+	"vcs_path",
+	"version",
+	revisionColumn,
+	"description",
+	"owner",
+	"default_branch",
+	"doc_url",
+	"source_template",
+	"visibility",
+	"labels",
+	"created_at",
+	"updated_at",
+}
+
+var (
+	errUnknownDialect = fmt.Errorf("unknown SQL dialect")
+	errInvalidTable   = fmt.Errorf("invalid table name")
+
+	tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+type sqlClient struct {
+	dialect      *dialect
+	table        string
+	pollInterval time.Duration
+	now          func() time.Time
+
+	lock sync.RWMutex
+	db   *sql.DB
+}
+
+// NewClient creates a client to the database of dataSource, in the dialect
+// of driverName, which is one of SQLite, PostgreSQL or MySQL.  The driver must
+// be registered with database/sql, usually by importing it, e.g.
+//
+//     import _ "github.com/mattn/go-sqlite3"
+//
+// Unless WithoutMigration is specified, the schema is migrated to the current
+// version.
+func NewClient(ctx context.Context, driverName, dataSource string, opts ...BackendOption) (vanity.EntryBackend, error) {
+	d, ok := dialects[driverName]
+	if !ok {
+		return nil, errUnknownDialect
+	}
+
+	s := collectSettings(opts...)
+	if !tableName.MatchString(s.table) {
+		return nil, errInvalidTable
+	}
+
+	db, err := sql.Open(driverName, dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	if driverName == SQLite {
+		// SQLite serializes writes, so concurrent transactions only fail
+		db.SetMaxOpenConns(1)
+	}
+
+	c := &sqlClient{
+		dialect:      d,
+		table:        s.table,
+		pollInterval: s.pollInterval,
+		now:          func() time.Time { return time.Now().UTC() },
+		db:           db,
+	}
+
+	if s.migrate {
+		if err := c.migrate(ctx); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// migrate applies the migrations of the dialect following the schema version
+// of the table, each in a transaction recording the new version.
+func (s *sqlClient) migrate(ctx context.Context) error {
+	schema := s.table + "_schema"
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version INTEGER NOT NULL)", schema))
+	if err != nil {
+		return err
+	}
+
+	var current sql.NullInt64
+	err = s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT MAX(version) FROM %s", schema)).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	for v := int(current.Int64); v < len(s.dialect.migrations); v++ {
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			for _, stmt := range s.dialect.migrations[v] {
+				if _, err := tx.ExecContext(ctx, fmt.Sprintf(stmt, s.table)); err != nil {
+					return err
+				}
+			}
+
+			_, err := tx.ExecContext(ctx, s.dialect.rebind(fmt.Sprintf("INSERT INTO %s (version) VALUES (?)", schema)), v+1)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf(unableToMigrate, v+1, err)
+		}
+	}
+
+	return nil
+}
+
+// inTx calls f in a transaction, which is committed if f succeeds and rolled
+// back otherwise.
+func (s *sqlClient) inTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlClient) checkClosed() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.db == nil {
+		return vanity.ErrAlreadyClosed
+	}
+
+	return nil
+}
+
+func (s *sqlClient) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+
+	db := s.db
+	s.db = nil
+
+	return db.Close()
+}
+
+// Watch polls the table for changes, using the updated_at column for
+// positions.
+func (s *sqlClient) Watch(ctx context.Context, position string) (<-chan vanity.Event, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	return vanity.Poll(ctx, s, s.pollInterval, position)
+}
+
+func (s *sqlClient) Healthz(ctx context.Context) error {
+	if err := s.checkClosed(); err != nil {
+		return err
+	}
+
+	return s.db.PingContext(ctx)
+}
+
+func (s *sqlClient) Get(ctx context.Context, importPath string) (vcs, vcsPath string, err error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return e.VCS, e.VCSPath, nil
+}
+
+func (s *sqlClient) GetEntry(ctx context.Context, importPath string) (*vanity.Entry, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	entries, err := s.read(ctx, s.db, []string{importPath})
+	if err != nil {
+		return nil, fmt.Errorf(unableToRetrieve, importPath, err)
+	}
+
+	e, found := entries[importPath]
+	if !found {
+		return nil, vanity.ErrNotFound
+	}
+
+	return e, nil
+}
+
+// GetPrefix reads the rows of every prefix of path in a single query.
+func (s *sqlClient) GetPrefix(ctx context.Context, path string) (*vanity.Entry, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	entries, err := s.read(ctx, s.db, vanity.Prefixes(path))
+	if err != nil {
+		return nil, fmt.Errorf(unableToRetrieve, path, err)
+	}
+
+	for _, candidate := range vanity.Prefixes(path) {
+		if e, found := entries[candidate]; found {
+			return e, nil
+		}
+	}
+
+	return nil, vanity.ErrNotFound
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// read reads the rows of importPaths.
+func (s *sqlClient) read(ctx context.Context, q querier, importPaths []string) (map[string]*vanity.Entry, error) {
+	entries := make(map[string]*vanity.Entry)
+	if len(importPaths) == 0 {
+		return entries, nil
+	}
+
+	args := make([]interface{}, len(importPaths))
+	for i, importPath := range importPaths {
+		args[i] = importPath
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IN (?%s)",
+		strings.Join(entryColumns, ", "), s.table, importPathColumn, strings.Repeat(", ?", len(args)-1))
+
+	err := s.query(ctx, q, query, args, func(e *vanity.Entry) {
+		entries[e.ImportPath] = e
+	})
+
+	return entries, err
+}
+
+// query runs a query selecting entryColumns, delivering the decoded rows to f.
+func (s *sqlClient) query(ctx context.Context, q querier, query string, args []interface{}, f func(*vanity.Entry)) error {
+	rows, err := q.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		e, err := decodeEntry(rows)
+		if err != nil {
+			return err
+		}
+		f(e)
+	}
+
+	return rows.Err()
+}
+
+func (s *sqlClient) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return s.InsertEntry(ctx, vanity.NewEntry(importPath, vcs, vcsPath))
+}
+
+func (s *sqlClient) InsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	err := s.single(ctx, vanity.Mutation{Op: vanity.MutationInsert, Entry: entry})
+	if err != nil && err != vanity.ErrAlreadyExists && err != vanity.ErrAlreadyClosed {
+		// a concurrent insert violates the primary key
+		if _, e := s.GetEntry(ctx, entry.ImportPath); e == nil {
+			return vanity.ErrAlreadyExists
+		}
+	}
+
+	return err
+}
+
+func (s *sqlClient) UpdateEntry(ctx context.Context, entry *vanity.Entry, ifRevision int64) error {
+	return s.single(ctx, vanity.Mutation{Op: vanity.MutationUpdate, Entry: entry, IfRevision: ifRevision})
+}
+
+func (s *sqlClient) UpsertEntry(ctx context.Context, entry *vanity.Entry) error {
+	return s.single(ctx, vanity.Mutation{Op: vanity.MutationUpsert, Entry: entry})
+}
+
+func (s *sqlClient) Remove(ctx context.Context, importPath string) error {
+	return s.single(ctx, vanity.Mutation{Op: vanity.MutationRemove, Entry: &vanity.Entry{ImportPath: importPath}})
+}
+
+// single applies a single mutation, returning the reason it failed.
+func (s *sqlClient) single(ctx context.Context, m vanity.Mutation) error {
+	err := s.Apply(ctx, []vanity.Mutation{m})
+	if me, ok := err.(*vanity.MutationError); ok {
+		return me.Err
+	}
+
+	return err
+}
+
+// Apply applies the mutations in a transaction, which reads the current rows
+// of their import paths and writes the changed ones.  Rows are only updated at
+// the revision read, so concurrent changes fail with vanity.ErrConflict.
+func (s *sqlClient) Apply(ctx context.Context, mutations []vanity.Mutation) error {
+	if err := s.checkClosed(); err != nil {
+		return err
+	}
+
+	var importPaths []string
+	for _, m := range mutations {
+		if m.Entry != nil {
+			importPaths = append(importPaths, m.Entry.ImportPath)
+		}
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		entries, err := s.read(ctx, tx, importPaths)
+		if err != nil {
+			return err
+		}
+
+		revisions := make(map[string]int64, len(entries))
+		for importPath, e := range entries {
+			revisions[importPath] = e.Revision
+		}
+
+		if err := vanity.ApplyMutations(entries, mutations, s.now()); err != nil {
+			return err
+		}
+
+		changed := make([]string, 0, len(entries))
+		for importPath := range entries {
+			changed = append(changed, importPath)
+		}
+		sort.Strings(changed)
+
+		for _, importPath := range changed {
+			revision, existed := revisions[importPath]
+			if err := s.write(ctx, tx, importPath, entries[importPath], revision, existed); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// write stores entry, or deletes the row of importPath if entry is nil.
+// Existing rows are only changed at revision.
+func (s *sqlClient) write(ctx context.Context, tx *sql.Tx, importPath string, entry *vanity.Entry, revision int64, existed bool) error {
+	var (
+		query string
+		args  []interface{}
+	)
+
+	switch {
+	case entry == nil && !existed:
+		return nil
+	case entry == nil:
+		query = fmt.Sprintf("DELETE FROM %s WHERE %s = ? AND %s = ?", s.table, importPathColumn, revisionColumn)
+		args = []interface{}{importPath, revision}
+	case existed:
+		values, err := encodeEntry(entry)
+		if err != nil {
+			return err
+		}
+		set := make([]string, len(entryColumns)-1)
+		for i, c := range entryColumns[1:] {
+			set[i] = c + " = ?"
+		}
+		query = fmt.Sprintf("UPDATE %s SET %s WHERE %s = ? AND %s = ?",
+			s.table, strings.Join(set, ", "), importPathColumn, revisionColumn)
+		args = append(values[1:], importPath, revision)
+	default:
+		values, err := encodeEntry(entry)
+		if err != nil {
+			return err
+		}
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (?%s)",
+			s.table, strings.Join(entryColumns, ", "), strings.Repeat(", ?", len(entryColumns)-1))
+		args = values
+	}
+
+	result, err := tx.ExecContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return vanity.ErrConflict
+	}
+
+	return nil
+}
+
+func (s *sqlClient) List(ctx context.Context, consumer vanity.Consumer) error {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+func (s *sqlClient) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	_, err := s.ListPage(ctx, vanity.ListOptions{}, consumer)
+
+	return err
+}
+
+// ListPage lists a page of entries with a query on the primary key, which is
+// served by an index range scan.
+func (s *sqlClient) ListPage(ctx context.Context, opts vanity.ListOptions, consumer vanity.EntryConsumer) (string, error) {
+	if err := s.checkClosed(); err != nil {
+		return "", err
+	}
+
+	after, err := vanity.DecodePageToken(opts.PageToken)
+	if err != nil {
+		return "", err
+	}
+
+	query, args := s.pageQuery(opts, after)
+
+	var page []*vanity.Entry
+	err = s.query(ctx, s.db, query, args, func(e *vanity.Entry) {
+		page = append(page, e)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	next := ""
+	if opts.PageSize > 0 && len(page) > opts.PageSize {
+		// the extra row shows there is another page
+		page = page[:opts.PageSize]
+		next = vanity.EncodePageToken(page[len(page)-1].ImportPath)
+	}
+
+	for _, e := range page {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		consumer.Consume(ctx, e)
+	}
+
+	return next, nil
+}
+
+// pageQuery creates the query of a page of entries, which come after the
+// import path after.  One more row than the size of the page is selected to
+// determine whether there is another page.
+func (s *sqlClient) pageQuery(opts vanity.ListOptions, after string) (string, []interface{}) {
+	var (
+		where []string
+		args  []interface{}
+	)
+
+	if opts.Prefix != "" {
+		// the range condition lets the prefix be found by an index scan
+		where = append(where, fmt.Sprintf("%s >= ?", importPathColumn),
+			fmt.Sprintf("SUBSTR(%s, 1, %d) = ?", importPathColumn, utf8.RuneCountInString(opts.Prefix)))
+		args = append(args, opts.Prefix, opts.Prefix)
+	}
+
+	order, cmp := "ASC", ">"
+	if opts.Order == vanity.Descending {
+		order, cmp = "DESC", "<"
+	}
+
+	if after != "" {
+		where = append(where, fmt.Sprintf("%s %s ?", importPathColumn, cmp))
+		args = append(args, after)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(entryColumns, ", "), s.table)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s", importPathColumn, order)
+	if opts.PageSize > 0 {
+		query += fmt.Sprintf(" LIMIT %d", opts.PageSize+1)
+	}
+
+	return query, args
+}
+
+// encodeEntry encodes entry as the values of entryColumns.  Timestamps are
+// stored as nanoseconds since the Unix epoch, which every dialect preserves.
+func encodeEntry(entry *vanity.Entry) ([]interface{}, error) {
+	var labels sql.NullString
+	if len(entry.Labels) > 0 {
+		b, err := json.Marshal(entry.Labels)
+		if err != nil {
+			return nil, err
+		}
+		labels = sql.NullString{String: string(b), Valid: true}
+	}
+
+	return []interface{}{
+		entry.ImportPath,
+		entry.VCS,
+		entry.VCSPath,
+		int64(entry.Version),
+		entry.Revision,
+		nullString(entry.Description),
+		nullString(entry.Owner),
+		nullString(entry.DefaultBranch),
+		nullString(entry.DocURL),
+		nullString(entry.SourceTemplate),
+		nullString(string(entry.Visibility)),
+		labels,
+		entry.Created.UnixNano(),
+		entry.Updated.UnixNano(),
+	}, nil
+}
+
+// decodeEntry decodes a row of entryColumns.
+func decodeEntry(rows *sql.Rows) (*vanity.Entry, error) {
+	var (
+		e                                                         vanity.Entry
+		version                                                   int64
+		description, owner, defaultBranch, docURL, sourceTemplate sql.NullString
+		visibility, labels                                        sql.NullString
+		created, updated                                          int64
+	)
+
+	err := rows.Scan(&e.ImportPath, &e.VCS, &e.VCSPath, &version, &e.Revision,
+		&description, &owner, &defaultBranch, &docURL, &sourceTemplate,
+		&visibility, &labels, &created, &updated)
+	if err != nil {
+		return nil, fmt.Errorf(unableToExtractEntry, e.ImportPath, err)
+	}
+
+	e.Version = int(version)
+	e.Description = description.String
+	e.Owner = owner.String
+	e.DefaultBranch = defaultBranch.String
+	e.DocURL = docURL.String
+	e.SourceTemplate = sourceTemplate.String
+	e.Visibility = vanity.Visibility(visibility.String)
+	if labels.Valid && labels.String != "" {
+		if err := json.Unmarshal([]byte(labels.String), &e.Labels); err != nil {
+			return nil, fmt.Errorf(unableToExtractEntry, e.ImportPath, err)
+		}
+	}
+	e.Created = time.Unix(0, created).UTC()
+	e.Updated = time.Unix(0, updated).UTC()
+
+	return &e, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
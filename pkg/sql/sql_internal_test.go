@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sql
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+)
+
+func TestPageQuery(t *testing.T) {
+	s := &sqlClient{table: "urls"}
+	columns := "import_path, vcs, vcs_path, version, revision, description, owner, default_branch, doc_url, " +
+		"source_template, visibility, labels, created_at, updated_at"
+
+	query, args := s.pageQuery(vanity.ListOptions{}, "")
+	assert.Equal(t, "SELECT "+columns+" FROM urls ORDER BY import_path ASC", query)
+	assert.Empty(t, args)
+
+	query, args = s.pageQuery(vanity.ListOptions{Prefix: "a.com/", PageSize: 10, Order: vanity.Descending}, "a.com/m")
+	assert.Equal(t, "SELECT "+columns+" FROM urls WHERE import_path >= ? AND SUBSTR(import_path, 1, 6) = ? "+
+		"AND import_path < ? ORDER BY import_path DESC LIMIT 11", query)
+	assert.Equal(t, []interface{}{"a.com/", "a.com/", "a.com/m"}, args)
+}
+
+func TestRebind(t *testing.T) {
+	query := "UPDATE urls SET vcs = ? WHERE import_path = ?"
+	assert.Equal(t, query, dialects[SQLite].rebind(query))
+	assert.Equal(t, query, dialects[MySQL].rebind(query))
+	assert.Equal(t, "UPDATE urls SET vcs = $1 WHERE import_path = $2", dialects[PostgreSQL].rebind(query))
+}
+
+func TestParseDSN(t *testing.T) {
+	for dsn, expected := range map[string][2]string{
+		"sqlite3:///var/lib/vanity.db?table=vanity&poll-interval=1m": {SQLite, "/var/lib/vanity.db"},
+		"sqlite3:vanity.db?_busy_timeout=5000":                       {SQLite, "file:vanity.db?_busy_timeout=5000"},
+		"postgres://u:p@localhost/vanity?sslmode=disable&table=vanity": {PostgreSQL,
+			"postgres://u:p@localhost/vanity?sslmode=disable"},
+		"mysql://u:p@localhost:3306/vanity?parseTime=true&migrate=false": {MySQL,
+			"u:p@tcp(localhost:3306)/vanity?parseTime=true"},
+	} {
+		u, _ := url.Parse(dsn)
+		driverName, dataSource, _, err := parseDSN(u)
+		assert.NoError(t, err, dsn)
+		assert.Equal(t, expected[0], driverName, dsn)
+		assert.Equal(t, expected[1], dataSource, dsn)
+	}
+
+	u, _ := url.Parse("sqlite3:///var/lib/vanity.db?table=vanity&poll-interval=1m&migrate=false")
+	_, _, opts, err := parseDSN(u)
+	assert.NoError(t, err)
+
+	s := collectSettings(opts...)
+	assert.Equal(t, "vanity", s.table)
+	assert.Equal(t, time.Minute, s.pollInterval)
+	assert.False(t, s.migrate)
+
+	for _, dsn := range []string{"sqlite3://", "mysql:///vanity", "sqlite3:///vanity.db?poll-interval=often"} {
+		u, _ = url.Parse(dsn)
+		_, _, _, err = parseDSN(u)
+		assert.Equal(t, vanity.ErrInvalidDSN, err, dsn)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sql_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+	"l7e.io/vanity/pkg/sql"
+)
+
+func newSQLite(t *testing.T) (vanity.EntryBackend, func()) {
+	dir, err := ioutil.TempDir("", "sql")
+	assert.NoError(t, err)
+
+	be, err := sql.NewClient(context.Background(), sql.SQLite, filepath.Join(dir, "vanity.db"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return be, func() {
+		_ = be.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestSQL(t *testing.T) {
+	apitest.TestEntryBackend(t, newSQLite)
+}
+
+func TestSQL_concurrentInsert(t *testing.T) {
+	be, done := newSQLite(t)
+	defer done()
+
+	const n = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- be.Add(context.Background(), "l7e.io/vanity", "git", "https://github.com/livetribe/vanity")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	inserted := 0
+	for err := range errs {
+		if err == nil {
+			inserted++
+			continue
+		}
+		assert.Equal(t, vanity.ErrAlreadyExists, err)
+	}
+	assert.Equal(t, 1, inserted)
+}
+
+func TestSQL_migrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sql")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	dsn := "sqlite3://" + filepath.Join(dir, "vanity.db") + "?table=vanity"
+
+	be, err := vanity.Open(context.Background(), dsn)
+	assert.NoError(t, err)
+	assert.NoError(t, be.Add(context.Background(), "l7e.io/vanity", "git", "https://github.com/livetribe/vanity"))
+	assert.NoError(t, be.Close())
+
+	// reopening an up to date schema keeps the table
+	be, err = vanity.Open(context.Background(), dsn)
+	assert.NoError(t, err)
+	defer func() { _ = be.Close() }()
+
+	_, _, err = be.Get(context.Background(), "l7e.io/vanity")
+	assert.NoError(t, err)
+
+	_, err = sql.NewClient(context.Background(), "oracle", "")
+	assert.Error(t, err)
+
+	_, err = sql.NewClient(context.Background(), sql.SQLite, filepath.Join(dir, "vanity.db"), sql.WithTable("urls; DROP TABLE vanity"))
+	assert.Error(t, err)
+}
@@ -15,11 +15,11 @@
  */
 
 /*
-Package toml provides an R/O, in-memory, TOML-initialized, implementation of Backend.
+Package toml provides an in-memory, TOML-initialized, implementation of Backend.
 
-This in-memory backend is an R/O implementation of vanity.Backend which is initialized
+This in-memory backend is an implementation of vanity.Backend which is initialized
 using TOML, https://github.com/toml-lang/toml.  The vanity URL configurations are
-stored in-memory.
+stored in-memory, and are R/O unless read from a file.
 
 
 TOML Configuration
@@ -46,6 +46,35 @@ The TOML configuration can be passed in during construction by passing in the ap
 option to the NewTOMLBackend method to specify file, Reader, string, or byte array content.
 Specifying multiple content, e.g. both a file and string results in undefined behavior.
 
+Reloading
+
+Backends whose configuration is read from a file can reload it whenever it changes, using the
+WatchFile option, or when the process receives a signal, using the ReloadOnSignal option, e.g.
+
+    be, err := toml.NewTOMLBackend(toml.FromFile("/etc/vanity/entries.toml"),
+        toml.WatchFile(), toml.ReloadOnSignal(syscall.SIGHUP))
+
+The file is re-parsed and validated, and all the entries replaced at once; watchers are
+notified of the changes, and the revisions of changed entries incremented.  If the file is
+invalid, the previous entries are kept.  The outcome of reloads is counted by the Reloads
+and ReloadErrors Prometheus counters.  The file of data source names is watched if their
+watch query parameter is true, e.g. "toml:///etc/vanity/entries.toml?watch=true".
+
+Writing
+
+Backends whose configuration is read from a file write their changes back to it.  The file
+is re-read, so changes apply to its latest content, and replaced atomically by renaming a
+temporary file.  Changes are serialized by a lock of the backend, not of the file: a single
+backend is assumed to write to the file, as a change made by another process, or backend,
+between the re-read and the rename would be lost.
+
+Only the tables of the changed entries are rewritten, the rest of the file, including other
+tables and comments, being kept as is: added entries are appended after the last table of the
+array, and removed entries are removed along with the comments directly above them.  Removing
+the last entry leaves an empty array, e.g. "obj = []", which the next added entry replaces.
+Entries must be written as an array of tables, rather than an inline array.  Backends whose
+configuration is not read from a file return vanity.ErrNotSupported.
+
 Creating a TOML-based Backend
 
 To create a backend instance:
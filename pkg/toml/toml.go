@@ -21,29 +21,147 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
-
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
 	"github.com/pelletier/go-toml"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"l7e.io/vanity"
 )
 
+const (
+	// DefaultTable is the default table of the data source names passed to
+	// vanity.Open.
+	DefaultTable = "entry"
+
+	// reloadDelay is the time changes to a watched file are collected before
+	// it is reloaded, so it is read once completely written.
+	reloadDelay = 100 * time.Millisecond
+)
+
+var (
+	// Reloads is a Prometheus counter that tracks the total successful
+	// reloads of TOML files.
+	Reloads = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "toml",
+		Name:      "reloads_total",
+		Help:      "The total successful TOML file reloads",
+	})
+
+	// ReloadErrors is a Prometheus counter that tracks the total reloads of
+	// TOML files that failed, keeping the previous entries.
+	ReloadErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "vanity",
+		Subsystem: "toml",
+		Name:      "reload_errors_total",
+		Help:      "The total failed TOML file reloads",
+	})
+)
+
+func init() { //nolint:gochecknoinits
+	vanity.RegisterBackend("toml", openDSN)
+}
+
+// openDSN creates a TOML-based Backend from a data source name such as
+// "toml:///etc/vanity/entries.toml?table=vanity.entry"; dotted table names
+// are split into their tokens.
+func openDSN(_ context.Context, dsn *url.URL) (vanity.Backend, error) {
+	path := dsn.Opaque
+	if path == "" {
+		path = dsn.Host + dsn.Path
+	}
+	if path == "" {
+		return nil, vanity.ErrInvalidDSN
+	}
+
+	q := dsn.Query()
+
+	table := q.Get("table")
+	if table == "" {
+		table = DefaultTable
+	}
+
+	options := []Option{FromFile(path), InTable(strings.Split(table, ".")...)}
+	if q.Get("watch") == "true" {
+		options = append(options, WatchFile())
+	}
+
+	return NewTOMLBackend(options...)
+}
+
 type entry struct {
-	ImportPath string `toml:"import_path"`
-	Vcs        string
-	VcsPath    string `toml:"vcs_path"`
+	ImportPath     string `toml:"import_path"`
+	Vcs            string
+	VcsPath        string            `toml:"vcs_path"`
+	Description    string            `toml:"description"`
+	Owner          string            `toml:"owner"`
+	DefaultBranch  string            `toml:"default_branch"`
+	DocURL         string            `toml:"doc_url"`
+	SourceTemplate string            `toml:"source_template"`
+	Visibility     string            `toml:"visibility"`
+	Labels         map[string]string `toml:"labels"`
+	Created        time.Time         `toml:"created"`
+	Updated        time.Time         `toml:"updated"`
+}
+
+func (e *entry) toEntry() *vanity.Entry {
+	return &vanity.Entry{
+		Version:        vanity.EntryVersion,
+		Revision:       1,
+		ImportPath:     e.ImportPath,
+		VCS:            e.Vcs,
+		VCSPath:        e.VcsPath,
+		Description:    e.Description,
+		Owner:          e.Owner,
+		DefaultBranch:  e.DefaultBranch,
+		DocURL:         e.DocURL,
+		SourceTemplate: e.SourceTemplate,
+		Visibility:     vanity.Visibility(e.Visibility),
+		Labels:         e.Labels,
+		Created:        e.Created,
+		Updated:        e.Updated,
+	}
 }
 
 type tomlBE struct {
-	entries map[string]*entry
+	path          string
+	tables        []string
+	onReloadError func(error)
+	events        *vanity.Broadcaster
+
+	watcher *fsnotify.Watcher
+	signals chan os.Signal
+	stop    chan struct{}
+	done    chan struct{}
+
+	// reloadLock serializes reloads
+	reloadLock sync.Mutex
+
+	lock sync.RWMutex
+	// entries are replaced, never modified, by reloads
+	entries map[string]*vanity.Entry
 	closed  bool
 }
 
 type settings struct {
-	Tables []string
-	Path   string
-	Reader io.Reader
-	String string
-	Bytes  []byte
+	Tables        []string
+	Path          string
+	Reader        io.Reader
+	String        string
+	Bytes         []byte
+	Watch         bool
+	Signals       []os.Signal
+	OnReloadError func(error)
 }
 
 // An Option is an option for a TOML-based Backend.
@@ -57,6 +175,8 @@ var (
 	errImportPathNotSpecified = fmt.Errorf("import_path not specified")
 	errVcsNotSpecified        = fmt.Errorf("vcs not specified")
 	errVcsPathNotSpecified    = fmt.Errorf("vcs_path not specified")
+	errInvalidVisibility      = fmt.Errorf("invalid visibility")
+	errReloadRequiresFile     = fmt.Errorf("reloads require a file")
 )
 
 // InTable is used to specify the table the configuration can be found.
@@ -115,8 +235,49 @@ func (b bytesOption) Apply(o *settings) {
 	o.Bytes = b.bytes
 }
 
+// WatchFile is used to reload the TOML configuration file whenever it
+// changes; the file must be specified using FromFile.
+func WatchFile() Option {
+	return watchOption{}
+}
+
+type watchOption struct{}
+
+func (w watchOption) Apply(o *settings) {
+	o.Watch = true
+}
+
+// ReloadOnSignal is used to reload the TOML configuration file whenever one
+// of the signals is received, e.g. syscall.SIGHUP; the file must be specified
+// using FromFile.
+func ReloadOnSignal(signals ...os.Signal) Option {
+	return signalOption{signals: signals}
+}
+
+type signalOption struct{ signals []os.Signal }
+
+func (s signalOption) Apply(o *settings) {
+	o.Signals = s.signals
+}
+
+// OnReloadError is used to report the errors of reloads, which are otherwise
+// only counted by ReloadErrors.
+func OnReloadError(f func(error)) Option {
+	return reloadErrorOption{f: f}
+}
+
+type reloadErrorOption struct{ f func(error) }
+
+func (r reloadErrorOption) Apply(o *settings) {
+	o.OnReloadError = r.f
+}
+
 // NewTOMLBackend creates a new TOML-backend using the specified options.
-func NewTOMLBackend(options ...Option) (be vanity.Backend, err error) {
+//
+// Backends whose file is watched, or reloaded on signals, re-parse the file
+// and replace all their entries at once, notifying watchers of the changes;
+// the entries are kept if the file is invalid.
+func NewTOMLBackend(options ...Option) (be vanity.EntryBackend, err error) {
 	s := settings{Tables: []string{}}
 	for _, o := range options {
 		o.Apply(&s)
@@ -146,12 +307,43 @@ func NewTOMLBackend(options ...Option) (be vanity.Backend, err error) {
 		return nil, errNoContentSpecified
 	}
 
+	reload := s.Watch || len(s.Signals) > 0
+	if reload && s.Path == "" {
+		return nil, errReloadRequiresFile
+	}
+
+	entries, err := parse(b, s.Tables)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tomlBE{
+		path:          s.Path,
+		tables:        s.Tables,
+		onReloadError: s.OnReloadError,
+		entries:       entries,
+		events:        vanity.NewBroadcaster(vanity.DefaultRetainedEvents),
+	}
+
+	if reload {
+		if err := t.startReloads(s.Watch, s.Signals); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// parse extracts and validates the entries of the array of tables at paths.
+func parse(b []byte, paths []string) (map[string]*vanity.Entry, error) {
 	tree, err := toml.LoadBytes(b)
 	if err != nil {
 		return nil, err
 	}
+	if len(paths) == 0 {
+		return nil, errTableDoesNotExist
+	}
 
-	paths := s.Tables
 	tables := paths[:len(paths)-1]
 	key := paths[len(paths)-1]
 
@@ -160,12 +352,20 @@ func NewTOMLBackend(options ...Option) (be vanity.Backend, err error) {
 		return nil, errTableDoesNotExist
 	}
 
-	array, ok := tree.Get(key).([]*toml.Tree)
-	if !ok {
+	var array []*toml.Tree
+	switch v := tree.Get(key).(type) {
+	case []*toml.Tree:
+		array = v
+	case []interface{}:
+		// an empty array, e.g. once the last entry is removed
+		if len(v) > 0 {
+			return nil, errTableDoesNotExist
+		}
+	default:
 		return nil, errTableDoesNotExist
 	}
 
-	entries := make(map[string]*entry)
+	entries := make(map[string]*vanity.Entry)
 	for _, z := range array {
 		var e = &entry{}
 		err = z.Unmarshal(e)
@@ -181,70 +381,322 @@ func NewTOMLBackend(options ...Option) (be vanity.Backend, err error) {
 		if e.VcsPath == "" {
 			return nil, errVcsPathNotSpecified
 		}
-		entries[e.ImportPath] = e
+		if !vanity.Visibility(e.Visibility).IsValid() {
+			return nil, errInvalidVisibility
+		}
+		entries[e.ImportPath] = e.toEntry()
+	}
+
+	return entries, nil
+}
+
+// startReloads watches the directory of the file, so it is also reloaded
+// when replaced, and listens to the signals.
+func (s *tomlBE) startReloads(watch bool, signals []os.Signal) error {
+	s.path = filepath.Clean(s.path)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	var events chan fsnotify.Event
+	var errs chan error
+	if watch {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+			_ = watcher.Close()
+			return err
+		}
+		s.watcher = watcher
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	if len(signals) > 0 {
+		s.signals = make(chan os.Signal, 1)
+		signal.Notify(s.signals, signals...)
 	}
 
-	return &tomlBE{entries: entries}, nil
+	go s.run(events, errs)
+
+	return nil
+}
+
+// run reloads the file once it stops changing for reloadDelay, or when a
+// signal is received.
+func (s *tomlBE) run(events <-chan fsnotify.Event, errs <-chan error) {
+	defer close(s.done)
+
+	timer := time.NewTimer(reloadDelay)
+	if !timer.Stop() {
+		<-timer.C
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case ev := <-events:
+			if filepath.Clean(ev.Name) == s.path && ev.Op != fsnotify.Chmod {
+				timer.Reset(reloadDelay)
+			}
+		case err := <-errs:
+			s.reportReloadError(err)
+		case <-timer.C:
+			s.reload()
+		case <-s.signals:
+			s.reload()
+		}
+	}
+}
+
+func (s *tomlBE) reportReloadError(err error) {
+	ReloadErrors.Inc()
+	if s.onReloadError != nil {
+		s.onReloadError(err)
+	}
+}
+
+// reload re-parses the file, replacing the entries and publishing their
+// changes, whose revisions are incremented; the entries are kept if the file
+// cannot be parsed.
+func (s *tomlBE) reload() {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+
+	b, err := ioutil.ReadFile(s.path)
+	if err == nil {
+		var current map[string]*vanity.Entry
+		if current, err = parse(b, s.tables); err == nil {
+			s.replace(current)
+			Reloads.Inc()
+			return
+		}
+	}
+
+	s.reportReloadError(err)
+}
+
+// replace replaces the entries by current, publishing their changes.
+func (s *tomlBE) replace(current map[string]*vanity.Entry) {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return
+	}
+	known := s.entries
+
+	importPaths := make([]string, 0, len(current)+len(known))
+	for importPath, e := range current {
+		if old, found := known[importPath]; found {
+			if e.Revision = old.Revision; !sameEntry(old, e) {
+				e.Revision++
+			}
+		}
+		importPaths = append(importPaths, importPath)
+	}
+	for importPath := range known {
+		if _, found := current[importPath]; !found {
+			importPaths = append(importPaths, importPath)
+		}
+	}
+	sort.Strings(importPaths)
+
+	s.entries = current
+	s.lock.Unlock()
+
+	for _, importPath := range importPaths {
+		old, wasKnown := known[importPath]
+		e, found := current[importPath]
+		switch {
+		case !found:
+			s.events.Publish(vanity.EventRemoved, importPath, nil)
+		case !wasKnown:
+			s.events.Publish(vanity.EventAdded, importPath, e)
+		case e.Revision != old.Revision:
+			s.events.Publish(vanity.EventUpdated, importPath, e)
+		}
+	}
+}
+
+// sameEntry reports whether the entries are the same but for their revisions.
+func sameEntry(a, b *vanity.Entry) bool {
+	x, y := *a, *b
+	x.Revision, y.Revision = 0, 0
+
+	return reflect.DeepEqual(x, y)
 }
 
 func (s *tomlBE) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
 	s.closed = true
 	s.entries = nil
+	s.lock.Unlock()
 
-	return nil
+	var err error
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+
+		if s.signals != nil {
+			signal.Stop(s.signals)
+		}
+		if s.watcher != nil {
+			err = s.watcher.Close()
+		}
+	}
+
+	s.events.Close()
+
+	return err
 }
 
-func (s *tomlBE) check() error {
-	// must not hold a lock
+// snapshot returns the current entries, which must not be modified.
+func (s *tomlBE) snapshot() (map[string]*vanity.Entry, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
 	if s.closed {
-		return vanity.ErrAlreadyClosed
+		return nil, vanity.ErrAlreadyClosed
 	}
-	return nil
+	return s.entries, nil
 }
 
-func (s *tomlBE) Get(_ context.Context, importPath string) (string, string, error) {
-	if err := s.check(); err != nil {
+func (s *tomlBE) Get(ctx context.Context, importPath string) (string, string, error) {
+	e, err := s.GetEntry(ctx, importPath)
+	if err != nil {
 		return "", "", err
 	}
+	return e.VCS, e.VCSPath, nil
+}
 
-	e, found := s.entries[importPath]
+func (s *tomlBE) GetEntry(_ context.Context, importPath string) (*vanity.Entry, error) {
+	entries, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	e, found := entries[importPath]
 	if !found {
-		return "", "", vanity.ErrNotFound
+		return nil, vanity.ErrNotFound
+	}
+	return e.Clone(), nil
+}
+
+func (s *tomlBE) GetPrefix(_ context.Context, path string) (*vanity.Entry, error) {
+	entries, err := s.snapshot()
+	if err != nil {
+		return nil, err
 	}
-	return e.Vcs, e.VcsPath, nil
+
+	for _, importPath := range vanity.Prefixes(path) {
+		if e, found := entries[importPath]; found {
+			return e.Clone(), nil
+		}
+	}
+	return nil, vanity.ErrNotFound
 }
 
-func (s *tomlBE) Add(_ context.Context, importPath, vcs, vcsPath string) error {
-	return vanity.ErrNotSupported
+func (s *tomlBE) Add(ctx context.Context, importPath, vcs, vcsPath string) error {
+	return s.InsertEntry(ctx, vanity.NewEntry(importPath, vcs, vcsPath))
 }
 
+// InsertEntry rewrites the file of the backend, appending the entry to the
+// array of tables; ErrNotSupported is returned unless the configuration was
+// read from a file.
+func (s *tomlBE) InsertEntry(_ context.Context, entry *vanity.Entry) error {
+	return s.mutate(vanity.InsertMutation(entry))
+}
+
+// UpdateEntry rewrites the file of the backend, replacing the table of the
+// entry; ErrNotSupported is returned unless the configuration was read from a
+// file.
+func (s *tomlBE) UpdateEntry(_ context.Context, entry *vanity.Entry, ifRevision int64) error {
+	return s.mutate(vanity.UpdateMutation(entry, ifRevision))
+}
+
+// UpsertEntry rewrites the file of the backend, replacing or appending the
+// table of the entry; ErrNotSupported is returned unless the configuration
+// was read from a file.
+func (s *tomlBE) UpsertEntry(_ context.Context, entry *vanity.Entry) error {
+	return s.mutate(vanity.UpsertMutation(entry))
+}
+
+// Remove rewrites the file of the backend, removing the table of the entry
+// along with the comments directly above it; ErrNotSupported is returned
+// unless the configuration was read from a file.
 func (s *tomlBE) Remove(_ context.Context, importPath string) error {
-	return vanity.ErrNotSupported
+	return s.mutate(vanity.RemoveMutation(importPath))
 }
 
 func (s *tomlBE) List(ctx context.Context, consumer vanity.Consumer) error {
-	if err := s.check(); err != nil {
+	return s.ListEntries(ctx, vanity.EntryConsumerFunc(func(ctx context.Context, e *vanity.Entry) {
+		consumer.OnEntry(ctx, e.ImportPath, e.VCS, e.VCSPath)
+	}))
+}
+
+func (s *tomlBE) ListEntries(ctx context.Context, consumer vanity.EntryConsumer) error {
+	entries, err := s.snapshot()
+	if err != nil {
 		return err
 	}
 
-	c := make(map[string]*entry)
-	for k, v := range s.entries {
-		c[k] = v
+	c := make([]*vanity.Entry, 0, len(entries))
+	for _, v := range entries {
+		c = append(c, v)
 	}
 
-	for importPath, e := range c {
+	for _, e := range c {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
 
-		consumer.OnEntry(ctx, importPath, e.Vcs, e.VcsPath)
+		consumer.Consume(ctx, e.Clone())
 	}
 
 	return nil
 }
 
+func (s *tomlBE) ListPage(ctx context.Context, opts vanity.ListOptions, consumer vanity.EntryConsumer) (string, error) {
+	entries, err := s.snapshot()
+	if err != nil {
+		return "", err
+	}
+
+	c := make([]*vanity.Entry, 0, len(entries))
+	for _, v := range entries {
+		c = append(c, v)
+	}
+
+	page, next, err := vanity.PageEntries(c, opts)
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range page {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		consumer.Consume(ctx, e.Clone())
+	}
+
+	return next, nil
+}
+
+func (s *tomlBE) Watch(ctx context.Context, position string) (<-chan vanity.Event, error) {
+	return s.events.Watch(ctx, position)
+}
+
+// Healthz is always healthy, as the entries are kept when reloads fail.
 func (s *tomlBE) Healthz(_ context.Context) error {
 	return nil
 }
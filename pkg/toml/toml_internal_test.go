@@ -22,8 +22,12 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	. "github.com/smartystreets/goconvey/convey"
 
 	"l7e.io/vanity"
@@ -289,6 +293,17 @@ vcs_path = "https://github.com/livetribe/one"
 			})
 		})
 
+		Convey("Construction with no table", func() {
+			be, err := NewTOMLBackend(InTable(), FromString(`
+[[obj]]
+import_path = "l7e.io/one"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/one"
+`))
+			So(err, ShouldEqual, errTableDoesNotExist)
+			So(be, ShouldBeNil)
+		})
+
 		Convey("Construction with no content", func() {
 			be, err := NewTOMLBackend(InTable("x", "y", "obj"))
 			So(err, ShouldNotBeNil)
@@ -299,9 +314,9 @@ vcs_path = "https://github.com/livetribe/one"
 
 func TestTomlBackend(t *testing.T) {
 	var expected = map[string]entry{
-		"l7e.io/one":   {"l7e.io/one", "git", "https://github.com/livetribe/one"},
-		"l7e.io/two":   {"l7e.io/two", "git", "https://github.com/livetribe/two"},
-		"l7e.io/three": {"l7e.io/three", "git", "https://github.com/livetribe/three"},
+		"l7e.io/one":   {ImportPath: "l7e.io/one", Vcs: "git", VcsPath: "https://github.com/livetribe/one"},
+		"l7e.io/two":   {ImportPath: "l7e.io/two", Vcs: "git", VcsPath: "https://github.com/livetribe/two"},
+		"l7e.io/three": {ImportPath: "l7e.io/three", Vcs: "git", VcsPath: "https://github.com/livetribe/three"},
 	}
 
 	Convey("Test TOML backend methods", t, func() {
@@ -332,11 +347,25 @@ vcs_path = "https://github.com/livetribe/three"
 			So(err, ShouldNotBeNil)
 		})
 
+		Convey("Test GetPrefix", func() {
+			pg, ok := be.(vanity.PrefixGetter)
+			So(ok, ShouldBeTrue)
+
+			e, err := pg.GetPrefix(context.Background(), "l7e.io/two/cmd/two")
+			So(err, ShouldBeNil)
+			So(e.ImportPath, ShouldEqual, "l7e.io/two")
+			So(e.VCS, ShouldEqual, "git")
+			So(e.VCSPath, ShouldEqual, "https://github.com/livetribe/two")
+
+			_, err = pg.GetPrefix(context.Background(), "l7e.io/four/cmd")
+			So(err, ShouldEqual, vanity.ErrNotFound)
+		})
+
 		Convey("Test List", func() {
 			entries := make(map[string]entry)
 			err := be.List(context.Background(),
 				vanity.ConsumerFunc(func(_ context.Context, importPath, vcs, vcsPath string) {
-					entries[importPath] = entry{importPath, vcs, vcsPath}
+					entries[importPath] = entry{ImportPath: importPath, Vcs: vcs, VcsPath: vcsPath}
 				}))
 			So(err, ShouldBeNil)
 			So(entries, ShouldResemble, expected)
@@ -420,6 +449,465 @@ vcs_path = "https://github.com/livetribe/three"
 	})
 }
 
+func TestTomlEntries(t *testing.T) {
+	Convey("Test TOML entry metadata", t, func() {
+		Convey("Metadata is parsed", func() {
+			be, err := NewTOMLBackend(InTable("obj"), FromString(`
+[[obj]]
+import_path = "l7e.io/one"
+vcs = "git"
+vcs_path = "https://gitlab.com/livetribe/one"
+description = "The first one"
+owner = "platform"
+default_branch = "main"
+doc_url = "https://docs.l7e.io/{{.ImportPath}}"
+source_template = "gitlab"
+visibility = "internal"
+created = 2020-06-01T10:00:00Z
+updated = 2020-06-02T10:00:00Z
+[obj.labels]
+tier = "core"
+`))
+			So(err, ShouldBeNil)
+
+			e, err := be.GetEntry(context.Background(), "l7e.io/one")
+			So(err, ShouldBeNil)
+			So(e, ShouldResemble, &vanity.Entry{
+				Version:        vanity.EntryVersion,
+				Revision:       1,
+				ImportPath:     "l7e.io/one",
+				VCS:            "git",
+				VCSPath:        "https://gitlab.com/livetribe/one",
+				Description:    "The first one",
+				Owner:          "platform",
+				DefaultBranch:  "main",
+				DocURL:         "https://docs.l7e.io/{{.ImportPath}}",
+				SourceTemplate: "gitlab",
+				Visibility:     vanity.VisibilityInternal,
+				Labels:         map[string]string{"tier": "core"},
+				Created:        time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC),
+				Updated:        time.Date(2020, 6, 2, 10, 0, 0, 0, time.UTC),
+			})
+
+			var entries []*vanity.Entry
+			err = be.ListEntries(context.Background(), vanity.EntryConsumerFunc(func(_ context.Context, e *vanity.Entry) {
+				entries = append(entries, e)
+			}))
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 1)
+			So(entries[0].Owner, ShouldEqual, "platform")
+
+			err = be.InsertEntry(context.Background(), e)
+			So(err, ShouldEqual, vanity.ErrNotSupported)
+		})
+
+		Convey("Invalid visibility", func() {
+			be, err := NewTOMLBackend(InTable("obj"), FromString(`
+[[obj]]
+import_path = "l7e.io/one"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/one"
+visibility = "secret"
+`))
+			So(err, ShouldEqual, errInvalidVisibility)
+			So(be, ShouldBeNil)
+		})
+	})
+}
+
+func counter(c prometheus.Counter) float64 {
+	m := &dto.Metric{}
+	_ = c.Write(m)
+	return m.Counter.GetValue()
+}
+
+func TestTomlReload(t *testing.T) {
+	Convey("Test reloading TOML files", t, func() {
+		dir, err := ioutil.TempDir("", "vanity")
+		So(err, ShouldBeNil)
+		defer func() { _ = os.RemoveAll(dir) }()
+
+		file := dir + "/entries.toml"
+		write := func(content string) {
+			// replaced as editors do
+			So(ioutil.WriteFile(file+".tmp", []byte(content), 0644), ShouldBeNil)
+			So(os.Rename(file+".tmp", file), ShouldBeNil)
+		}
+		write(`
+[[obj]]
+import_path = "l7e.io/one"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/one"
+[[obj]]
+import_path = "l7e.io/two"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/two"
+`)
+
+		reloadErrors := make(chan error, 10)
+		be, err := NewTOMLBackend(InTable("obj"), FromFile(file), WatchFile(), ReloadOnSignal(syscall.SIGHUP),
+			OnReloadError(func(err error) { reloadErrors <- err }))
+		So(err, ShouldBeNil)
+		defer func() { _ = be.Close() }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events, err := be.(vanity.Watcher).Watch(ctx, "")
+		So(err, ShouldBeNil)
+
+		next := func() vanity.Event {
+			select {
+			case e := <-events:
+				return e
+			case <-time.After(5 * time.Second):
+				return vanity.Event{}
+			}
+		}
+
+		Convey("Changes to the file are applied", func() {
+			reloads := counter(Reloads)
+
+			write(`
+[[obj]]
+import_path = "l7e.io/one"
+vcs = "git"
+vcs_path = "https://gitlab.com/livetribe/one"
+[[obj]]
+import_path = "l7e.io/three"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/three"
+`)
+
+			e := next()
+			So(e.Type, ShouldEqual, vanity.EventUpdated)
+			So(e.ImportPath, ShouldEqual, "l7e.io/one")
+			So(e.Entry.Revision, ShouldEqual, 2)
+			e = next()
+			So(e.Type, ShouldEqual, vanity.EventAdded)
+			So(e.ImportPath, ShouldEqual, "l7e.io/three")
+			e = next()
+			So(e.Type, ShouldEqual, vanity.EventRemoved)
+			So(e.ImportPath, ShouldEqual, "l7e.io/two")
+
+			_, vcsPath, err := be.Get(ctx, "l7e.io/one")
+			So(err, ShouldBeNil)
+			So(vcsPath, ShouldEqual, "https://gitlab.com/livetribe/one")
+			_, _, err = be.Get(ctx, "l7e.io/two")
+			So(err, ShouldEqual, vanity.ErrNotFound)
+			So(counter(Reloads), ShouldBeGreaterThan, reloads)
+		})
+
+		Convey("Invalid changes keep the previous entries", func() {
+			failures := counter(ReloadErrors)
+
+			write(`
+[[obj]]
+import_path = "l7e.io/one"
+vcs = "git"
+`)
+
+			select {
+			case err := <-reloadErrors:
+				So(err, ShouldEqual, errVcsPathNotSpecified)
+			case <-time.After(5 * time.Second):
+				So("no reload error", ShouldBeEmpty)
+			}
+			So(counter(ReloadErrors), ShouldBeGreaterThan, failures)
+
+			_, _, err = be.Get(ctx, "l7e.io/two")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Signals reload the file", func() {
+			reloads := counter(Reloads)
+			be.(*tomlBE).signals <- syscall.SIGHUP
+
+			for deadline := time.Now().Add(5 * time.Second); counter(Reloads) == reloads && time.Now().Before(deadline); {
+				time.Sleep(10 * time.Millisecond)
+			}
+			So(counter(Reloads), ShouldBeGreaterThan, reloads)
+
+			// the unchanged entries keep their revisions
+			e, err := be.GetEntry(ctx, "l7e.io/one")
+			So(err, ShouldBeNil)
+			So(e.Revision, ShouldEqual, 1)
+		})
+
+		Convey("Reloads require a file", func() {
+			_, err := NewTOMLBackend(InTable("obj"), FromString(`
+[[obj]]
+import_path = "l7e.io/one"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/one"
+`), WatchFile())
+			So(err, ShouldEqual, errReloadRequiresFile)
+		})
+	})
+}
+
+func TestTomlWrite(t *testing.T) {
+	Convey("Test writing TOML files", t, func() {
+		f, err := ioutil.TempFile("", "vanity-*.toml")
+		So(err, ShouldBeNil)
+		defer func() { _ = os.Remove(f.Name()) }()
+
+		_, err = f.WriteString(`# Vanity URLs
+title = "vanity"
+
+[server]
+port = 8080
+
+# the core entry
+[[obj]]
+import_path = "l7e.io/one"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/one"
+
+# the second entry
+[[obj]]
+import_path = "l7e.io/two"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/two"
+[obj.labels]
+team = "platform"
+
+# a trailing table
+[other]
+key = "value"
+`)
+		So(err, ShouldBeNil)
+		So(f.Close(), ShouldBeNil)
+
+		be, err := NewTOMLBackend(InTable("obj"), FromFile(f.Name()))
+		So(err, ShouldBeNil)
+		defer func() { _ = be.Close() }()
+
+		ctx := context.Background()
+		content := func() string {
+			b, err := ioutil.ReadFile(f.Name())
+			So(err, ShouldBeNil)
+			return string(b)
+		}
+		reopen := func() vanity.EntryBackend {
+			other, err := NewTOMLBackend(InTable("obj"), FromFile(f.Name()))
+			So(err, ShouldBeNil)
+			return other
+		}
+
+		Convey("Add appends a table after the last entry", func() {
+			So(be.Add(ctx, "l7e.io/three", "git", "https://github.com/livetribe/three"), ShouldBeNil)
+			So(be.Add(ctx, "l7e.io/three", "git", "https://github.com/livetribe/three"), ShouldEqual, vanity.ErrAlreadyExists)
+
+			So(content(), ShouldContainSubstring, `[obj.labels]
+team = "platform"
+
+[[obj]]
+import_path = "l7e.io/three"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/three"
+`)
+			So(content(), ShouldStartWith, "# Vanity URLs\ntitle = \"vanity\"\n\n[server]\nport = 8080\n\n# the core entry\n")
+			So(content(), ShouldEndWith, "\n\n# a trailing table\n[other]\nkey = \"value\"\n")
+
+			e, err := be.GetEntry(ctx, "l7e.io/three")
+			So(err, ShouldBeNil)
+			So(e.Revision, ShouldEqual, 1)
+
+			saved, err := reopen().GetEntry(ctx, "l7e.io/three")
+			So(err, ShouldBeNil)
+			So(saved.VCSPath, ShouldEqual, "https://github.com/livetribe/three")
+			So(saved.Created, ShouldResemble, e.Created)
+		})
+
+		Convey("UpdateEntry replaces the table of the entry", func() {
+			e, err := be.GetEntry(ctx, "l7e.io/two")
+			So(err, ShouldBeNil)
+			e.VCSPath = "https://gitlab.com/livetribe/two"
+			e.Description = `The "second" one`
+			e.Labels = map[string]string{"team": "core", "tier.name": "gold"}
+			So(be.UpdateEntry(ctx, e, 1), ShouldBeNil)
+			So(be.UpdateEntry(ctx, e, 1), ShouldEqual, vanity.ErrConflict)
+
+			So(content(), ShouldContainSubstring, `# the second entry
+[[obj]]
+import_path = "l7e.io/two"
+vcs = "git"
+vcs_path = "https://gitlab.com/livetribe/two"
+description = "The \"second\" one"
+`)
+			So(content(), ShouldContainSubstring, `[obj.labels]
+team = "core"
+"tier.name" = "gold"
+
+# a trailing table
+`)
+
+			updated, err := be.GetEntry(ctx, "l7e.io/two")
+			So(err, ShouldBeNil)
+			So(updated.Revision, ShouldEqual, 2)
+			So(updated.Description, ShouldEqual, `The "second" one`)
+
+			saved, err := reopen().GetEntry(ctx, "l7e.io/two")
+			So(err, ShouldBeNil)
+			So(saved.Labels, ShouldResemble, e.Labels)
+		})
+
+		Convey("Remove removes the table of the entry and its comments", func() {
+			So(be.Remove(ctx, "l7e.io/two"), ShouldBeNil)
+			So(be.Remove(ctx, "l7e.io/two"), ShouldEqual, vanity.ErrNotFound)
+
+			So(content(), ShouldEqual, `# Vanity URLs
+title = "vanity"
+
+[server]
+port = 8080
+
+# the core entry
+[[obj]]
+import_path = "l7e.io/one"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/one"
+
+# a trailing table
+[other]
+key = "value"
+`)
+
+			_, _, err := reopen().Get(ctx, "l7e.io/two")
+			So(err, ShouldEqual, vanity.ErrNotFound)
+		})
+
+		Convey("Removing the last entry leaves an empty array", func() {
+			So(be.Remove(ctx, "l7e.io/two"), ShouldBeNil)
+			So(be.Remove(ctx, "l7e.io/one"), ShouldBeNil)
+
+			So(content(), ShouldEqual, `# Vanity URLs
+title = "vanity"
+obj = []
+
+[server]
+port = 8080
+
+# a trailing table
+[other]
+key = "value"
+`)
+
+			err := reopen().List(ctx, vanity.ConsumerFunc(func(_ context.Context, importPath, _, _ string) {
+				So(importPath, ShouldBeEmpty)
+			}))
+			So(err, ShouldBeNil)
+
+			So(be.Add(ctx, "l7e.io/three", "git", "https://github.com/livetribe/three"), ShouldBeNil)
+			So(content(), ShouldStartWith, `# Vanity URLs
+title = "vanity"
+
+[server]
+`)
+			So(content(), ShouldContainSubstring, `[other]
+key = "value"
+
+[[obj]]
+import_path = "l7e.io/three"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/three"
+`)
+
+			_, _, err = reopen().Get(ctx, "l7e.io/three")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Removing the last nested entry declares the empty array in its table", func() {
+			for _, c := range []struct{ before, after string }{
+				{"[a.b]\nkey = 1\n\n[[a.b.obj]]\nimport_path = \"l7e.io/one\"\nvcs = \"git\"\nvcs_path = \"https://github.com/livetribe/one\"\n",
+					"[a.b]\nkey = 1\nobj = []\n"},
+				{"[other]\nkey = 1\n\n[[a.b.obj]]\nimport_path = \"l7e.io/one\"\nvcs = \"git\"\nvcs_path = \"https://github.com/livetribe/one\"\n",
+					"[other]\nkey = 1\n\n[a.b]\nobj = []\n"},
+			} {
+				So(ioutil.WriteFile(f.Name(), []byte(c.before), 0600), ShouldBeNil)
+				nested, err := NewTOMLBackend(InTable("a", "b", "obj"), FromFile(f.Name()))
+				So(err, ShouldBeNil)
+
+				So(nested.Remove(ctx, "l7e.io/one"), ShouldBeNil)
+				So(content(), ShouldEqual, c.after)
+
+				So(nested.Add(ctx, "l7e.io/one", "git", "https://github.com/livetribe/one"), ShouldBeNil)
+				_, _, err = nested.Get(ctx, "l7e.io/one")
+				So(err, ShouldBeNil)
+				So(nested.Close(), ShouldBeNil)
+			}
+		})
+
+		Convey("Changes are published", func() {
+			events, err := be.(vanity.Watcher).Watch(ctx, "")
+			So(err, ShouldBeNil)
+
+			So(be.UpsertEntry(ctx, vanity.NewEntry("l7e.io/one", "git", "https://gitlab.com/livetribe/one")), ShouldBeNil)
+
+			e := <-events
+			So(e.Type, ShouldEqual, vanity.EventUpdated)
+			So(e.ImportPath, ShouldEqual, "l7e.io/one")
+			So(e.Entry.Revision, ShouldEqual, 2)
+		})
+
+		Convey("Entries not read from a file cannot be changed", func() {
+			be, err := NewTOMLBackend(InTable("obj"), FromString(`
+[[obj]]
+import_path = "l7e.io/one"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/one"
+`))
+			So(err, ShouldBeNil)
+			So(be.Remove(ctx, "l7e.io/one"), ShouldEqual, vanity.ErrNotSupported)
+		})
+
+		Convey("Entries written as inline tables cannot be changed", func() {
+			So(ioutil.WriteFile(f.Name(), []byte(`obj = [{import_path = "l7e.io/one", vcs = "git", vcs_path = "https://github.com/livetribe/one"}]
+`), 0600), ShouldBeNil)
+
+			So(be.Add(ctx, "l7e.io/two", "git", "https://github.com/livetribe/two"), ShouldEqual, errUnsupportedLayout)
+		})
+	})
+}
+
+func TestTomlOpen(t *testing.T) {
+	Convey("Test opening TOML backends by data source name", t, func() {
+		f, err := ioutil.TempFile("", "vanity-*.toml")
+		So(err, ShouldBeNil)
+		defer func() { _ = os.Remove(f.Name()) }()
+
+		_, err = f.WriteString(`
+[vanity]
+[[vanity.urls]]
+import_path = "l7e.io/one"
+vcs = "git"
+vcs_path = "https://github.com/livetribe/one"
+`)
+		So(err, ShouldBeNil)
+		So(f.Close(), ShouldBeNil)
+
+		be, err := vanity.Open(context.Background(), "toml://"+f.Name()+"?table=vanity.urls")
+		So(err, ShouldBeNil)
+
+		vcs, vcsPath, err := be.Get(context.Background(), "l7e.io/one")
+		So(err, ShouldBeNil)
+		So(vcs, ShouldEqual, "git")
+		So(vcsPath, ShouldEqual, "https://github.com/livetribe/one")
+
+		watched, err := vanity.Open(context.Background(), "toml://"+f.Name()+"?table=vanity.urls&watch=true")
+		So(err, ShouldBeNil)
+		So(watched.(*tomlBE).watcher, ShouldNotBeNil)
+		So(watched.Close(), ShouldBeNil)
+
+		_, err = vanity.Open(context.Background(), "toml://"+f.Name())
+		So(err, ShouldEqual, errTableDoesNotExist)
+
+		_, err = vanity.Open(context.Background(), "toml:")
+		So(err, ShouldEqual, vanity.ErrInvalidDSN)
+	})
+}
+
 func verify(be vanity.Backend) {
 	vcs, vcsPath, err := be.Get(context.Background(), "l7e.io/one")
 	So(err, ShouldBeNil)
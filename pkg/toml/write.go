@@ -0,0 +1,387 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package toml
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"l7e.io/vanity"
+)
+
+var (
+	errUnsupportedLayout = fmt.Errorf("entries must be written as an array of tables")
+
+	bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// mutate applies a mutation to the file of the backend, which is re-read so
+// the mutation applies to its latest content, then rewritten atomically.
+func (s *tomlBE) mutate(m vanity.Mutation) error {
+	if _, err := s.snapshot(); err != nil {
+		return err
+	}
+	if s.path == "" {
+		return vanity.ErrNotSupported
+	}
+
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	current, err := parse(b, s.tables)
+	if err != nil {
+		return err
+	}
+	s.replace(current)
+
+	known, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+
+	importPath := m.Entry.ImportPath
+	entries := make(map[string]*vanity.Entry, 1)
+	if e, found := known[importPath]; found {
+		entries[importPath] = e.Clone()
+	}
+
+	if err = vanity.ApplyMutations(entries, []vanity.Mutation{m}, time.Now().UTC()); err != nil {
+		if me, ok := err.(*vanity.MutationError); ok {
+			return me.Err
+		}
+		return err
+	}
+
+	if b, err = edit(b, s.tables, importPath, entries[importPath]); err != nil {
+		return err
+	}
+	if current, err = parse(b, s.tables); err != nil {
+		return err
+	}
+	if err = writeFile(s.path, b); err != nil {
+		return err
+	}
+	s.replace(current)
+
+	return nil
+}
+
+// edit replaces the table of an import path in the array of tables at
+// tables, appending it after the last table if new, or removes it if e is
+// nil.  The rest of the document, including comments, is kept as is.
+//
+// Removing the last table leaves an empty array, e.g. "entry = []", which is
+// replaced by the table of the next import path added.
+func edit(b []byte, tables []string, importPath string, e *vanity.Entry) ([]byte, error) {
+	tree, err := toml.LoadBytes(b)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(string(b), "\n")
+	header := tableHeader(tables)
+
+	var array []*toml.Tree
+	switch v := tree.GetPath(tables).(type) {
+	case []*toml.Tree:
+		array = v
+	case []interface{}:
+		if len(v) > 0 {
+			return nil, errTableDoesNotExist
+		}
+		if e == nil {
+			return nil, vanity.ErrNotFound
+		}
+		return addToEmpty(lines, tables, tree.GetPositionPath(tables).Line-1, encodeEntry(header, e))
+	default:
+		return nil, errTableDoesNotExist
+	}
+	if len(array) == 0 {
+		return nil, errTableDoesNotExist
+	}
+
+	// the tables of the array, as [start, end) line ranges
+	var at, last [2]int
+	found := false
+	for _, z := range array {
+		start := z.Position().Line - 1
+		if start < 0 || start >= len(lines) || !strings.HasPrefix(strings.TrimSpace(lines[start]), "[[") {
+			return nil, errUnsupportedLayout
+		}
+		r := [2]int{start, blockEnd(lines, start, header)}
+		if r[0] > last[0] || last == [2]int{} {
+			last = r
+		}
+		if p, _ := z.Get("import_path").(string); p == importPath {
+			at, found = r, true
+		}
+	}
+
+	var replacement []string
+	if e != nil {
+		replacement = encodeEntry(header, e)
+	}
+
+	switch {
+	case found && e == nil:
+		// comments directly above the table describe it
+		for at[0] > 0 && strings.HasPrefix(strings.TrimSpace(lines[at[0]-1]), "#") {
+			at[0]--
+		}
+		// as does the blank line separating it from the previous table
+		if at[0] > 0 && strings.TrimSpace(lines[at[0]-1]) == "" {
+			at[0]--
+		}
+	case !found && e == nil:
+		return nil, vanity.ErrNotFound
+	case !found:
+		at = [2]int{last[1], last[1]}
+		replacement = append([]string{""}, replacement...)
+	}
+
+	edited := splice(lines, at[0], at[1], replacement)
+	if len(array) == 1 && found && e == nil {
+		edited = emptyArray(edited, tables, at[0])
+	}
+
+	return []byte(strings.Join(edited, "\n")), nil
+}
+
+// emptyArray declares the array of tables at tables empty, after its last
+// table was removed at line removed.  The declaration follows the key/value
+// pairs of the parent table, which is declared at removed if it was only
+// implied by the array.
+func emptyArray(lines []string, tables []string, removed int) []string {
+	declaration := quoteKey(tables[len(tables)-1]) + " = []"
+
+	start := 0
+	if len(tables) > 1 {
+		parent := "[" + tableHeader(tables[:len(tables)-1]) + "]"
+
+		start = -1
+		for i, l := range lines {
+			if strings.TrimSpace(l) == parent {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			insert := []string{parent, declaration}
+			if removed > 0 {
+				insert = append([]string{""}, insert...)
+			}
+			return splice(lines, removed, removed, insert)
+		}
+	}
+
+	// the key/value pairs of the parent table end before the next table and
+	// the blank and comment lines preceding it
+	end := len(lines)
+	for i := start; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "[") {
+			end = i
+			break
+		}
+	}
+	for end > start {
+		l := strings.TrimSpace(lines[end-1])
+		if l != "" && !strings.HasPrefix(l, "#") {
+			break
+		}
+		end--
+	}
+
+	return splice(lines, end, end, []string{declaration})
+}
+
+// addToEmpty replaces the declaration of an empty array of tables at line
+// with its first table, appended to the document.
+func addToEmpty(lines []string, tables []string, line int, table []string) ([]byte, error) {
+	key := regexp.MustCompile(`^` + regexp.QuoteMeta(quoteKey(tables[len(tables)-1])) + `\s*=\s*\[\s*\]\s*(#.*)?$`)
+	if line < 0 || line >= len(lines) || !key.MatchString(strings.TrimSpace(lines[line])) {
+		return nil, errUnsupportedLayout
+	}
+	lines = splice(lines, line, line+1, nil)
+
+	end := len(lines)
+	for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	if end > 0 {
+		table = append([]string{""}, table...)
+	}
+
+	return []byte(strings.Join(splice(lines, end, end, table), "\n")), nil
+}
+
+// splice returns lines with the lines [from, to) replaced by replacement.
+func splice(lines []string, from, to int, replacement []string) []string {
+	spliced := make([]string, 0, len(lines)-(to-from)+len(replacement))
+	spliced = append(spliced, lines[:from]...)
+	spliced = append(spliced, replacement...)
+	return append(spliced, lines[to:]...)
+}
+
+// blockEnd returns the line following the table starting at start, and its
+// sub-tables, excluding the blank and comment lines preceding the next table.
+func blockEnd(lines []string, start int, header string) int {
+	end := len(lines)
+	for i := start + 1; i < len(lines); i++ {
+		l := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(l, "[") {
+			continue
+		}
+
+		name := strings.TrimSpace(strings.Trim(l, "[]"))
+		if !strings.HasPrefix(name, header+".") {
+			end = i
+			break
+		}
+	}
+
+	for end > start+1 {
+		l := strings.TrimSpace(lines[end-1])
+		if l != "" && !strings.HasPrefix(l, "#") {
+			break
+		}
+		end--
+	}
+
+	return end
+}
+
+func tableHeader(tables []string) string {
+	keys := make([]string, len(tables))
+	for i, t := range tables {
+		keys[i] = quoteKey(t)
+	}
+
+	return strings.Join(keys, ".")
+}
+
+func quoteKey(k string) string {
+	if bareKey.MatchString(k) {
+		return k
+	}
+	return quote(k)
+}
+
+// quote returns a TOML basic string.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// encodeEntry returns the lines of the table of an entry.
+func encodeEntry(header string, e *vanity.Entry) []string {
+	lines := []string{
+		"[[" + header + "]]",
+		"import_path = " + quote(e.ImportPath),
+		"vcs = " + quote(e.VCS),
+		"vcs_path = " + quote(e.VCSPath),
+	}
+
+	optional := []struct{ key, value string }{
+		{"description", e.Description},
+		{"owner", e.Owner},
+		{"default_branch", e.DefaultBranch},
+		{"doc_url", e.DocURL},
+		{"source_template", e.SourceTemplate},
+		{"visibility", string(e.Visibility)},
+	}
+	for _, o := range optional {
+		if o.value != "" {
+			lines = append(lines, o.key+" = "+quote(o.value))
+		}
+	}
+
+	lines = append(lines,
+		"created = "+e.Created.UTC().Format(time.RFC3339Nano),
+		"updated = "+e.Updated.UTC().Format(time.RFC3339Nano))
+
+	if len(e.Labels) > 0 {
+		keys := make([]string, 0, len(e.Labels))
+		for k := range e.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		lines = append(lines, "["+header+".labels]")
+		for _, k := range keys {
+			lines = append(lines, quoteKey(k)+" = "+quote(e.Labels[k]))
+		}
+	}
+
+	return lines
+}
+
+// writeFile replaces a file atomically, by renaming a temporary file written
+// in the same directory, keeping its permissions.
+func writeFile(path string, b []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	_, err = tmp.Write(b)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
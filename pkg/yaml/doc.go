@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package yaml provides an in-memory, YAML-initialized, implementation of Backend.
+
+This in-memory backend is an implementation of vanity.Backend which is initialized
+using YAML, https://yaml.org.  The vanity URL configurations are stored in-memory,
+and are R/O.
+
+
+YAML Configuration
+
+Vanity entries are specified via a sequence of mappings, with the same keys as the
+tables of the TOML backend, e.g. import_path, vcs and vcs_path.  The vanity entry for
+this project could be
+
+	entries:
+	  - import_path: l7e.io/vanity
+	    vcs: git
+	    vcs_path: https://github.com/livetribe/vanity
+
+Here, the sequence is under the "entries" key and is specified using the InTable option;
+the sequence is the root of the document if no keys are specified.
+
+Entries can also be specified via a mapping keyed by import path, as in the paths of a
+govanityurls, https://github.com/GoogleCloudPlatform/govanityurls, configuration:
+
+	host: l7e.io
+	paths:
+	  /vanity:
+	    repo: https://github.com/livetribe/vanity
+
+Keys starting with a slash are relative to the host at the root of the document, repo is
+an alternative to vcs_path, and vcs defaults to git for repositories on GitHub or Bitbucket.
+Other govanityurls keys are ignored.
+
+The YAML configuration can be passed in during construction by passing in the appropriate
+option to the NewYAMLBackend method to specify file, Reader, string, or byte array content.
+Specifying multiple content, e.g. both a file and string results in undefined behavior.
+Data source names are opened with the table query parameter splitting dotted keys, e.g.
+"yaml:///etc/vanity/vanity.yaml?table=paths".
+
+Creating a YAML-based Backend
+
+To create a backend instance:
+
+    be := yaml.NewYAMLBackend(InTable("obj"), FromString(`
+    obj:
+      - import_path: l7e.io/one
+        vcs: git
+        vcs_path: https://github.com/livetribe/one
+      - import_path: l7e.io/two
+        vcs: git
+        vcs_path: https://github.com/livetribe/two
+    `))
+    defer be.Close()
+
+Remember to close the backend instance after use.
+
+*/
+package yaml // import "l7e.io/vanity/pkg/yaml"
@@ -0,0 +1,172 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package yaml
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/pkg/internal/readonly"
+)
+
+func init() { //nolint:gochecknoinits
+	vanity.RegisterBackend("yaml", openDSN)
+}
+
+// openDSN creates a YAML-based Backend from a data source name such as
+// "yaml:///etc/vanity/vanity.yaml?table=paths"; dotted table names are split
+// into their tokens, and the entries are at the root of the document if no
+// table is specified.
+func openDSN(_ context.Context, dsn *url.URL) (vanity.Backend, error) {
+	path := dsn.Opaque
+	if path == "" {
+		path = dsn.Host + dsn.Path
+	}
+	if path == "" {
+		return nil, vanity.ErrInvalidDSN
+	}
+
+	options := []Option{FromFile(path)}
+	if table := dsn.Query().Get("table"); table != "" {
+		options = append(options, InTable(strings.Split(table, ".")...))
+	}
+
+	return NewYAMLBackend(options...)
+}
+
+// An Option is an option for a YAML-based Backend.
+type Option = readonly.Option
+
+// InTable is used to specify the mapping the configuration can be found,
+// by the keys leading to it from the root of the document, in order.
+func InTable(tables ...string) Option {
+	return readonly.InTable(tables...)
+}
+
+// FromFile is used to specify the path of the YAML configuration file.
+func FromFile(path string) Option {
+	return readonly.FromFile(path)
+}
+
+// FromReader is used to specify a Reader that contains the YAML contents of the configuration.
+func FromReader(r io.Reader) Option {
+	return readonly.FromReader(r)
+}
+
+// FromString is used to specify the string YAML contents of the configuration.
+func FromString(s string) Option {
+	return readonly.FromString(s)
+}
+
+// FromBytes is used to specify the byte YAML contents of the configuration.
+func FromBytes(b []byte) Option {
+	return readonly.FromBytes(b)
+}
+
+// NewYAMLBackend creates a new, read-only, YAML-backend using the specified
+// options.
+func NewYAMLBackend(options ...Option) (vanity.EntryBackend, error) {
+	return readonly.NewBackend(parse, options...)
+}
+
+// parse extracts and validates the entries of the sequence, or mapping, at
+// tables.
+//
+// The keys of mappings are the import paths of their entries, as in the
+// paths of govanityurls configurations, relative to the host at the root of
+// the document.
+func parse(b []byte, tables []string) (map[string]*vanity.Entry, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, readonly.ErrTableDoesNotExist
+	}
+
+	root := resolve(doc.Content[0])
+	node := root
+	for _, t := range tables {
+		if node = child(node, t); node == nil {
+			return nil, readonly.ErrTableDoesNotExist
+		}
+	}
+
+	entries := make(map[string]*vanity.Entry)
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, n := range node.Content {
+			var e = &readonly.Entry{}
+			if err := n.Decode(e); err != nil {
+				return nil, err
+			}
+			if err := e.Validate(); err != nil {
+				return nil, err
+			}
+			entries[e.ImportPath] = e.ToEntry()
+		}
+	case yaml.MappingNode:
+		var host string
+		if h := child(root, "host"); h != nil {
+			host = h.Value
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			var e = &readonly.Entry{}
+			if err := node.Content[i+1].Decode(e); err != nil {
+				return nil, err
+			}
+			e.Keyed(host, node.Content[i].Value)
+			if err := e.Validate(); err != nil {
+				return nil, err
+			}
+			entries[e.ImportPath] = e.ToEntry()
+		}
+	default:
+		return nil, readonly.ErrTableDoesNotExist
+	}
+
+	return entries, nil
+}
+
+// child returns the value of key in the mapping node, or nil if the node is
+// not a mapping or has no such key.
+func child(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return resolve(node.Content[i+1])
+		}
+	}
+
+	return nil
+}
+
+func resolve(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity
+
+import (
+	"context"
+	"strings"
+)
+
+// GetPrefix obtains the vanity URL configuration whose import path is the
+// longest prefix of path.  If api implements PrefixGetter, its GetPrefix
+// method is used; otherwise every candidate returned by Prefixes is looked up,
+// longest first, until one is found.
+//
+// ErrNotFound is returned if there is no such configuration.
+func GetPrefix(ctx context.Context, api Backend, path string) (*Entry, error) {
+	if pg, ok := api.(PrefixGetter); ok {
+		return pg.GetPrefix(ctx, path)
+	}
+
+	eb := AsEntryBackend(api)
+
+	for _, candidate := range Prefixes(path) {
+		entry, err := eb.GetEntry(ctx, candidate)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return entry, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// Prefixes returns the import paths which are a prefix of path, on path
+// segment boundaries, ordered from the longest, path itself, to the shortest,
+// its first segment.  Empty segments are ignored, e.g. "a.com//b/" has the
+// prefixes "a.com/b" and "a.com".
+func Prefixes(path string) []string {
+	segments := strings.FieldsFunc(path, func(c rune) bool { return c == '/' })
+
+	prefixes := make([]string, len(segments))
+	for i := range segments {
+		prefixes[len(segments)-1-i] = strings.Join(segments[:i+1], "/")
+	}
+
+	return prefixes
+}
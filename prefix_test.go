@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+)
+
+func TestPrefixes(t *testing.T) {
+	assert.Equal(t, []string{"a.com/b/c", "a.com/b", "a.com"}, vanity.Prefixes("a.com/b/c"))
+	assert.Equal(t, []string{"a.com/b", "a.com"}, vanity.Prefixes("a.com//b/"))
+	assert.Equal(t, []string{"a.com"}, vanity.Prefixes("a.com"))
+	assert.Empty(t, vanity.Prefixes(""))
+}
+
+func TestGetPrefix_longest(t *testing.T) {
+	be := &apitest.MockBackend{Urls: map[string][]string{
+		"a.com/b":     {"git", "https://github.com/b"},
+		"a.com/b/c/d": {"git", "https://github.com/d"},
+	}}
+
+	entry, err := vanity.GetPrefix(context.Background(), be, "a.com/b/c/d/e")
+	assert.NoError(t, err)
+	assert.Equal(t, vanity.NewEntry("a.com/b/c/d", "git", "https://github.com/d"), entry)
+
+	entry, err = vanity.GetPrefix(context.Background(), be, "a.com/b/c")
+	assert.NoError(t, err)
+	assert.Equal(t, vanity.NewEntry("a.com/b", "git", "https://github.com/b"), entry)
+}
+
+func TestGetPrefix_not_found(t *testing.T) {
+	be := &apitest.MockBackend{Urls: map[string][]string{"a.com/b": {"git", "https://github.com/b"}}}
+
+	_, err := vanity.GetPrefix(context.Background(), be, "a.com/z/b")
+	assert.Equal(t, vanity.ErrNotFound, err)
+}
+
+func TestGetPrefix_error(t *testing.T) {
+	be := &apitest.MockBackend{Healthy: errNotHealthy}
+
+	_, err := vanity.GetPrefix(context.Background(), be, "a.com/b/c")
+	assert.Equal(t, errNotHealthy, err)
+}
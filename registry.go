@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrInvalidDSN is returned by Open, and BackendFactory implementations,
+	// if a data source name is malformed.
+	ErrInvalidDSN = fmt.Errorf("invalid data source name")
+
+	// ErrUnknownBackend is returned by Open if no backend is registered for the
+	// scheme of a data source name.
+	ErrUnknownBackend = fmt.Errorf("unknown backend")
+
+	factoryLock sync.RWMutex
+	factories   = make(map[string]BackendFactory)
+)
+
+// BackendFactory creates a Backend from a parsed data source name, e.g.
+// "spanner://projects/p/instances/i/databases/d".
+type BackendFactory func(ctx context.Context, dsn *url.URL) (Backend, error)
+
+// RegisterBackend makes a backend available by the scheme of the data source
+// names passed to Open.  Backend implementations usually register themselves
+// in their package's init function.
+//
+// If RegisterBackend is called twice with the same scheme, or if factory is
+// nil, it panics.
+func RegisterBackend(scheme string, factory BackendFactory) {
+	factoryLock.Lock()
+	defer factoryLock.Unlock()
+
+	if factory == nil {
+		panic("vanity: RegisterBackend factory is nil")
+	}
+	if _, dup := factories[scheme]; dup {
+		panic("vanity: RegisterBackend called twice for scheme " + scheme)
+	}
+
+	factories[scheme] = factory
+}
+
+// RegisteredBackends returns the sorted schemes of the registered backends.
+func RegisteredBackends() []string {
+	factoryLock.RLock()
+	defer factoryLock.RUnlock()
+
+	schemes := make([]string, 0, len(factories))
+	for scheme := range factories {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+// Open creates a Backend from a data source name, whose scheme selects the
+// registered backend, e.g.
+//
+//     be, err := vanity.Open(ctx, "spanner://projects/p/instances/i/databases/d")
+//
+// ErrUnknownBackend is returned if no backend is registered for the scheme.
+func Open(ctx context.Context, dsn string) (Backend, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		return nil, ErrInvalidDSN
+	}
+
+	factoryLock.RLock()
+	factory, ok := factories[u.Scheme]
+	factoryLock.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownBackend
+	}
+
+	return factory(ctx, u)
+}
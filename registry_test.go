@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/apitest"
+)
+
+func TestOpen(t *testing.T) {
+	var opened *url.URL
+	vanity.RegisterBackend("mock", func(_ context.Context, dsn *url.URL) (vanity.Backend, error) {
+		opened = dsn
+		return &apitest.MockBackend{}, nil
+	})
+
+	assert.Contains(t, vanity.RegisteredBackends(), "mock")
+	assert.Contains(t, vanity.RegisteredBackends(), "memory")
+
+	be, err := vanity.Open(context.Background(), "mock://host/path?table=urls")
+	assert.NoError(t, err)
+	assert.NotNil(t, be)
+	assert.Equal(t, "host", opened.Host)
+	assert.Equal(t, "/path", opened.Path)
+	assert.Equal(t, "urls", opened.Query().Get("table"))
+
+	_, err = vanity.Open(context.Background(), "nope://host")
+	assert.Equal(t, vanity.ErrUnknownBackend, err)
+
+	_, err = vanity.Open(context.Background(), "no-scheme")
+	assert.Equal(t, vanity.ErrInvalidDSN, err)
+
+	assert.Panics(t, func() {
+		vanity.RegisterBackend("mock", func(context.Context, *url.URL) (vanity.Backend, error) { return nil, nil })
+	})
+	assert.Panics(t, func() {
+		vanity.RegisterBackend("nil", nil)
+	})
+}
+
+func TestOpen_memory(t *testing.T) {
+	be, err := vanity.Open(context.Background(), "memory:")
+	assert.NoError(t, err)
+
+	assert.NoError(t, be.Add(context.Background(), "a.com/b", "git", "https://github.com/b"))
+	vcs, vcsPath, err := be.Get(context.Background(), "a.com/b")
+	assert.NoError(t, err)
+	assert.Equal(t, "git", vcs)
+	assert.Equal(t, "https://github.com/b", vcsPath)
+}
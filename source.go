@@ -0,0 +1,239 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Forge types with built-in go-source templates.
+const (
+	ForgeGitHub    = "github"
+	ForgeGitLab    = "gitlab"
+	ForgeBitbucket = "bitbucket"
+	ForgeGitea     = "gitea"
+	ForgeSourcehut = "sourcehut"
+
+	// DefaultBranch is the default branch used in go-source links.
+	DefaultBranch = "master"
+)
+
+// SourceTemplate holds the text/template templates used to generate the
+// home, directory and file URLs of a go-source meta tag, see
+// https://github.com/golang/gddo/wiki/Source-Code-Links.
+//
+// The templates are executed with the fields VCSRoot, the root of the
+// repository with any ".git" suffix removed, and Branch.  The go-source
+// substitutions, {dir}, {/dir}, {file} and {line}, are left as is.
+type SourceTemplate struct {
+	Home      string
+	Directory string
+	File      string
+}
+
+type sourceData struct {
+	VCSRoot string
+	Branch  string
+}
+
+type sourceLinks struct {
+	Home      string
+	Directory string
+	File      string
+}
+
+type forgeTemplates struct {
+	home, directory, file *template.Template
+}
+
+var (
+	errUnknownForge = fmt.Errorf("unknown forge")
+
+	forgeLock sync.RWMutex
+	forges    = make(map[string]*forgeTemplates)
+
+	// forgeHosts maps well known hosts to their forge type.
+	forgeHosts = map[string]string{
+		"github.com":    ForgeGitHub,
+		"gitlab.com":    ForgeGitLab,
+		"bitbucket.org": ForgeBitbucket,
+		"codeberg.org":  ForgeGitea,
+		"gitea.com":     ForgeGitea,
+		"git.sr.ht":     ForgeSourcehut,
+	}
+
+	// forgeHostPrefixes maps conventional host name prefixes of self-hosted
+	// forges to their forge type.
+	forgeHostPrefixes = map[string]string{
+		"gitlab.":  ForgeGitLab,
+		"gitea.":   ForgeGitea,
+		"forgejo.": ForgeGitea,
+	}
+)
+
+func init() {
+	builtins := map[string]SourceTemplate{
+		ForgeGitHub: {
+			Home:      "{{.VCSRoot}}",
+			Directory: "{{.VCSRoot}}/tree/{{.Branch}}{/dir}",
+			File:      "{{.VCSRoot}}/blob/{{.Branch}}{/dir}/{file}#L{line}",
+		},
+		ForgeGitLab: {
+			Home:      "{{.VCSRoot}}",
+			Directory: "{{.VCSRoot}}/-/tree/{{.Branch}}{/dir}",
+			File:      "{{.VCSRoot}}/-/blob/{{.Branch}}{/dir}/{file}#L{line}",
+		},
+		ForgeBitbucket: {
+			Home:      "{{.VCSRoot}}",
+			Directory: "{{.VCSRoot}}/src/{{.Branch}}{/dir}",
+			File:      "{{.VCSRoot}}/src/{{.Branch}}{/dir}/{file}#lines-{line}",
+		},
+		ForgeGitea: {
+			Home:      "{{.VCSRoot}}",
+			Directory: "{{.VCSRoot}}/src/branch/{{.Branch}}{/dir}",
+			File:      "{{.VCSRoot}}/src/branch/{{.Branch}}{/dir}/{file}#L{line}",
+		},
+		ForgeSourcehut: {
+			Home:      "{{.VCSRoot}}",
+			Directory: "{{.VCSRoot}}/tree/{{.Branch}}/item{/dir}",
+			File:      "{{.VCSRoot}}/tree/{{.Branch}}/item{/dir}/{file}#L{line}",
+		},
+	}
+
+	for forge, st := range builtins {
+		if err := RegisterForge(forge, st); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterForge registers the go-source templates of a forge type, replacing
+// any templates previously registered for that type.
+func RegisterForge(forge string, st SourceTemplate) error {
+	home, err := template.New(forge + ".home").Parse(st.Home)
+	if err != nil {
+		return err
+	}
+
+	directory, err := template.New(forge + ".directory").Parse(st.Directory)
+	if err != nil {
+		return err
+	}
+
+	file, err := template.New(forge + ".file").Parse(st.File)
+	if err != nil {
+		return err
+	}
+
+	forgeLock.Lock()
+	defer forgeLock.Unlock()
+
+	forges[forge] = &forgeTemplates{home: home, directory: directory, file: file}
+
+	return nil
+}
+
+// IsForge reports whether go-source templates are registered for forge.
+func IsForge(forge string) bool {
+	forgeLock.RLock()
+	defer forgeLock.RUnlock()
+
+	_, ok := forges[forge]
+
+	return ok
+}
+
+// DetectForge returns the forge type of the repository vcsPath, detected from
+// its host, consulting hosts first.  The empty string is returned if the forge
+// type cannot be detected.
+func DetectForge(vcsPath string, hosts map[string]string) string {
+	host := vcsHost(vcsPath)
+
+	if forge, ok := hosts[host]; ok {
+		return forge
+	}
+
+	if forge, ok := forgeHosts[host]; ok {
+		return forge
+	}
+
+	for prefix, forge := range forgeHostPrefixes {
+		if strings.HasPrefix(host, prefix) {
+			return forge
+		}
+	}
+
+	return ""
+}
+
+// vcsHost extracts the host of vcsPath, which may or may not have a scheme.
+func vcsHost(vcsPath string) string {
+	if u, err := url.Parse(vcsPath); err == nil && u.Host != "" {
+		return strings.ToLower(u.Hostname())
+	}
+
+	return strings.ToLower(strings.SplitN(vcsPath, "/", 2)[0])
+}
+
+// sourceFor generates the go-source links of the repository at vcsRoot using
+// the templates of forge.  Unknown forges fall back to the GitHub layout.
+func sourceFor(forge, vcsRoot, branch string) (*sourceLinks, error) {
+	forgeLock.RLock()
+	ft, ok := forges[forge]
+	if !ok {
+		ft, ok = forges[ForgeGitHub]
+	}
+	forgeLock.RUnlock()
+
+	if !ok {
+		return nil, errUnknownForge
+	}
+
+	d := &sourceData{
+		VCSRoot: strings.TrimSuffix(vcsRoot, ".git"),
+		Branch:  branch,
+	}
+
+	var err error
+	links := &sourceLinks{}
+
+	if links.Home, err = execute(ft.home, d); err != nil {
+		return nil, err
+	}
+	if links.Directory, err = execute(ft.directory, d); err != nil {
+		return nil, err
+	}
+	if links.File, err = execute(ft.file, d); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+func execute(t *template.Template, d interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, d); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
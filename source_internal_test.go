@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectForge(t *testing.T) {
+	assert.Equal(t, ForgeGitHub, DetectForge("https://github.com/livetribe/vanity", nil))
+	assert.Equal(t, ForgeGitLab, DetectForge("https://gitlab.com/a/b", nil))
+	assert.Equal(t, ForgeGitLab, DetectForge("https://gitlab.example.com/a/b", nil))
+	assert.Equal(t, ForgeBitbucket, DetectForge("https://bitbucket.org/a/b", nil))
+	assert.Equal(t, ForgeGitea, DetectForge("https://codeberg.org/a/b", nil))
+	assert.Equal(t, ForgeSourcehut, DetectForge("https://git.sr.ht/~a/b", nil))
+	assert.Equal(t, ForgeGitHub, DetectForge("github.com/livetribe/vanity", nil))
+	assert.Equal(t, "", DetectForge("https://git.example.com/a/b", nil))
+	assert.Equal(t, ForgeGitea, DetectForge("https://git.example.com/a/b", map[string]string{"git.example.com": ForgeGitea}))
+}
+
+func TestSourceFor(t *testing.T) {
+	tests := []struct {
+		forge, directory, file string
+	}{
+		{ForgeGitHub, "r/tree/main{/dir}", "r/blob/main{/dir}/{file}#L{line}"},
+		{ForgeGitLab, "r/-/tree/main{/dir}", "r/-/blob/main{/dir}/{file}#L{line}"},
+		{ForgeBitbucket, "r/src/main{/dir}", "r/src/main{/dir}/{file}#lines-{line}"},
+		{ForgeGitea, "r/src/branch/main{/dir}", "r/src/branch/main{/dir}/{file}#L{line}"},
+		{ForgeSourcehut, "r/tree/main/item{/dir}", "r/tree/main/item{/dir}/{file}#L{line}"},
+		{"unknown", "r/tree/main{/dir}", "r/blob/main{/dir}/{file}#L{line}"},
+	}
+
+	for _, tt := range tests {
+		links, err := sourceFor(tt.forge, "r.git", "main")
+		assert.NoError(t, err, tt.forge)
+		assert.Equal(t, "r", links.Home, tt.forge)
+		assert.Equal(t, tt.directory, links.Directory, tt.forge)
+		assert.Equal(t, tt.file, links.File, tt.forge)
+	}
+}
+
+func TestRegisterForge(t *testing.T) {
+	assert.False(t, IsForge("custom"))
+
+	err := RegisterForge("custom", SourceTemplate{
+		Home:      "{{.VCSRoot}}",
+		Directory: "{{.VCSRoot}}/browse/{{.Branch}}{/dir}",
+		File:      "{{.VCSRoot}}/browse/{{.Branch}}{/dir}/{file}#{line}",
+	})
+	assert.NoError(t, err)
+	assert.True(t, IsForge("custom"))
+
+	links, err := sourceFor("custom", "r", "trunk")
+	assert.NoError(t, err)
+	assert.Equal(t, "r/browse/trunk{/dir}", links.Directory)
+	assert.Equal(t, "r/browse/trunk{/dir}/{file}#{line}", links.File)
+
+	err = RegisterForge("bad", SourceTemplate{Home: "{{.VCSRoot"})
+	assert.Error(t, err)
+	assert.False(t, IsForge("bad"))
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type staleKey struct{}
+
+// MarkStale is called by Backend implementations serving vanity URL
+// configurations that may be out of date, e.g. from a snapshot while their
+// store is unavailable, to report it to the caller that passed ctx.  It has
+// no effect unless ctx was created by WithStaleReport.
+func MarkStale(ctx context.Context) {
+	if stale, ok := ctx.Value(staleKey{}).(*int32); ok {
+		atomic.StoreInt32(stale, 1)
+	}
+}
+
+// WithStaleReport returns a copy of ctx in which Backend implementations can
+// report stale configurations through MarkStale, and a function reporting
+// whether any was.
+func WithStaleReport(ctx context.Context) (context.Context, func() bool) {
+	stale := new(int32)
+	return context.WithValue(ctx, staleKey{}, stale), func() bool {
+		return atomic.LoadInt32(stale) != 0
+	}
+}
@@ -0,0 +1,429 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventType is the type of change made to a vanity URL configuration.
+type EventType int
+
+// Event types.
+const (
+	EventAdded EventType = iota + 1
+	EventUpdated
+	EventRemoved
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventAdded:
+		return "added"
+	case EventUpdated:
+		return "updated"
+	case EventRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// DefaultPollInterval is the default interval between the listings of
+	// backends watched using Poll.
+	DefaultPollInterval = 10 * time.Second
+
+	// DefaultRetainedEvents is the default number of events a Broadcaster
+	// retains for watchers resuming from a position.
+	DefaultRetainedEvents = 1024
+)
+
+// ErrInvalidPosition is returned if a watch cannot be resumed from a position.
+var ErrInvalidPosition = fmt.Errorf("invalid watch position")
+
+// Event is a change made to a vanity URL configuration.
+type Event struct {
+	// Type is the type of change.
+	Type EventType
+
+	// ImportPath is the import path of the changed configuration.
+	ImportPath string
+
+	// Entry is the configuration after the change, nil if it was removed.
+	Entry *Entry
+
+	// Position is passed to Watcher.Watch to resume watching after this event.
+	Position string
+}
+
+// Watcher is an optional interface implemented by Backend implementations
+// that can notify of changes made to their vanity URL configurations.
+type Watcher interface {
+	// Watch delivers the changes made after position to the returned channel,
+	// which is closed when ctx is done or the backend is closed.  An empty
+	// position watches the changes made from now on.  ErrInvalidPosition is
+	// returned if watching cannot be resumed from position.
+	Watch(ctx context.Context, position string) (<-chan Event, error)
+}
+
+// Broadcaster is a helper for Watcher implementations that are notified of
+// every change, which delivers the published events to all watchers.  The
+// most recent events are retained so watchers can resume from their position.
+type Broadcaster struct {
+	lock     sync.Mutex
+	seq      uint64
+	retain   int
+	log      []Event
+	watchers map[*eventQueue]struct{}
+	closed   bool
+}
+
+// NewBroadcaster creates a Broadcaster retaining up to retain events.
+func NewBroadcaster(retain int) *Broadcaster {
+	return &Broadcaster{
+		retain:   retain,
+		watchers: make(map[*eventQueue]struct{}),
+	}
+}
+
+// Publish delivers an event to the watchers.  Events published after the
+// Broadcaster is closed are discarded.
+func (b *Broadcaster) Publish(t EventType, importPath string, entry *Entry) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.seq++
+	event := Event{Type: t, ImportPath: importPath, Position: strconv.FormatUint(b.seq, 10)}
+	if entry != nil {
+		event.Entry = entry.Clone()
+	}
+
+	b.log = append(b.log, event)
+	if len(b.log) > b.retain {
+		b.log = b.log[len(b.log)-b.retain:]
+	}
+
+	for q := range b.watchers {
+		q.push(event)
+	}
+}
+
+// Watch implements Watcher.Watch; positions are the sequence numbers of
+// published events.
+func (b *Broadcaster) Watch(ctx context.Context, position string) (<-chan Event, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		return nil, ErrAlreadyClosed
+	}
+
+	q := &eventQueue{notify: make(chan struct{}, 1)}
+
+	if position != "" {
+		seq, err := strconv.ParseUint(position, 10, 64)
+		if err != nil || seq > b.seq {
+			return nil, ErrInvalidPosition
+		}
+
+		// the event following position must still be retained
+		first := b.seq - uint64(len(b.log)) + 1
+		if seq+1 < first {
+			return nil, ErrInvalidPosition
+		}
+		q.pending = append(q.pending, b.log[seq+1-first:]...)
+	}
+
+	b.watchers[q] = struct{}{}
+
+	c := make(chan Event)
+	go q.run(ctx, c, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		delete(b.watchers, q)
+	})
+
+	return c, nil
+}
+
+// Close closes the channels of all watchers.
+func (b *Broadcaster) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.closed = true
+	for q := range b.watchers {
+		q.close()
+	}
+	b.watchers = nil
+}
+
+// eventQueue buffers the events of a watcher so publishers never block on
+// slow watchers.
+type eventQueue struct {
+	lock    sync.Mutex
+	pending []Event
+	closed  bool
+	notify  chan struct{}
+}
+
+func (q *eventQueue) push(e Event) {
+	q.lock.Lock()
+	q.pending = append(q.pending, e)
+	q.lock.Unlock()
+
+	q.signal()
+}
+
+func (q *eventQueue) close() {
+	q.lock.Lock()
+	q.closed = true
+	q.lock.Unlock()
+
+	q.signal()
+}
+
+func (q *eventQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *eventQueue) run(ctx context.Context, c chan<- Event, done func()) {
+	defer close(c)
+	defer done()
+
+	for {
+		q.lock.Lock()
+		closed, pending := q.closed, len(q.pending) > 0
+		var e Event
+		if pending {
+			e = q.pending[0]
+		}
+		q.lock.Unlock()
+
+		if closed {
+			return
+		}
+
+		if !pending {
+			select {
+			case <-q.notify:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		select {
+		case c <- e:
+			q.lock.Lock()
+			q.pending = q.pending[1:]
+			q.lock.Unlock()
+		case <-q.notify:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Poll is a helper for Watcher implementations of backends that cannot
+// notify of changes, which detects changes by listing the configurations of
+// api every interval.
+//
+// Positions are the latest update time seen, so a resumed watch delivers the
+// configurations updated since as added or updated; configurations removed
+// while not watching are not delivered.
+//
+// Every poll lists all the configurations, as listing only those updated
+// since the last poll would miss the removed ones; its cost grows with their
+// number, which the interval should be chosen for.
+func Poll(ctx context.Context, api Backend, interval time.Duration, position string) (<-chan Event, error) {
+	var since time.Time
+	if position != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339Nano, position); err != nil {
+			return nil, ErrInvalidPosition
+		}
+	}
+
+	known, err := snapshot(ctx, api)
+	if err != nil {
+		return nil, err
+	}
+
+	var initial []Event
+	if position != "" {
+		for _, e := range known {
+			if !e.Updated.After(since) {
+				continue
+			}
+
+			t := EventUpdated
+			if e.Created.After(since) {
+				t = EventAdded
+			}
+			initial = append(initial, Event{Type: t, ImportPath: e.ImportPath, Entry: e})
+		}
+	}
+	since = positionEvents(initial, latestUpdate(known, since))
+
+	c := make(chan Event)
+	go func() {
+		defer close(c)
+
+		if !send(ctx, c, initial) {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := snapshot(ctx, api)
+			if err == ErrAlreadyClosed {
+				return
+			}
+			if err != nil {
+				logger.Printf("Unable to poll for changes: %s", err)
+				continue
+			}
+
+			events := diffEntries(known, current)
+			since = positionEvents(events, latestUpdate(current, since))
+			known = current
+
+			if !send(ctx, c, events) {
+				return
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+func snapshot(ctx context.Context, api Backend) (map[string]*Entry, error) {
+	entries := make(map[string]*Entry)
+	err := AsEntryBackend(api).ListEntries(ctx, EntryConsumerFunc(func(_ context.Context, e *Entry) {
+		entries[e.ImportPath] = e
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func latestUpdate(entries map[string]*Entry, since time.Time) time.Time {
+	for _, e := range entries {
+		if e.Updated.After(since) {
+			since = e.Updated
+		}
+	}
+
+	return since
+}
+
+// diffEntries returns the events changing known into current.
+func diffEntries(known, current map[string]*Entry) []Event {
+	var events []Event
+
+	for importPath, e := range current {
+		old, found := known[importPath]
+		switch {
+		case !found:
+			events = append(events, Event{Type: EventAdded, ImportPath: importPath, Entry: e})
+		case !sameEntry(old, e):
+			events = append(events, Event{Type: EventUpdated, ImportPath: importPath, Entry: e})
+		}
+	}
+
+	for importPath := range known {
+		if _, found := current[importPath]; !found {
+			events = append(events, Event{Type: EventRemoved, ImportPath: importPath})
+		}
+	}
+
+	return events
+}
+
+// positionEvents orders the events by update time, removals last, and sets
+// their positions; latest is the position of the removals.
+func positionEvents(events []Event, latest time.Time) time.Time {
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[j].Entry == nil {
+			return events[i].Entry != nil
+		}
+		if events[i].Entry == nil {
+			return false
+		}
+		return events[i].Entry.Updated.Before(events[j].Entry.Updated)
+	})
+
+	for i := range events {
+		at := latest
+		if events[i].Entry != nil {
+			at = events[i].Entry.Updated
+		}
+		events[i].Position = at.Format(time.RFC3339Nano)
+	}
+
+	return latest
+}
+
+func sameEntry(a, b *Entry) bool {
+	if !a.Created.Equal(b.Created) || !a.Updated.Equal(b.Updated) {
+		return false
+	}
+
+	x, y := *a, *b
+	x.Created, x.Updated = time.Time{}, time.Time{}
+	y.Created, y.Updated = time.Time{}, time.Time{}
+
+	return reflect.DeepEqual(x, y)
+}
+
+func send(ctx context.Context, c chan<- Event, events []Event) bool {
+	for _, e := range events {
+		select {
+		case c <- e:
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	return true
+}
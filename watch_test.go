@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2020 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vanity_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"l7e.io/vanity"
+	"l7e.io/vanity/pkg/memory"
+)
+
+func receive(t *testing.T, events <-chan vanity.Event) vanity.Event {
+	select {
+	case e, ok := <-events:
+		assert.True(t, ok, "channel closed")
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	return vanity.Event{}
+}
+
+func TestBroadcaster(t *testing.T) {
+	b := vanity.NewBroadcaster(2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := b.Watch(ctx, "")
+	assert.NoError(t, err)
+
+	b.Publish(vanity.EventAdded, "a.com/b", vanity.NewEntry("a.com/b", "git", "https://github.com/b"))
+	b.Publish(vanity.EventUpdated, "a.com/b", vanity.NewEntry("a.com/b", "git", "https://gitlab.com/b"))
+	b.Publish(vanity.EventRemoved, "a.com/b", nil)
+
+	e := receive(t, events)
+	assert.Equal(t, vanity.EventAdded, e.Type)
+	assert.Equal(t, "https://github.com/b", e.Entry.VCSPath)
+
+	e = receive(t, events)
+	assert.Equal(t, vanity.EventUpdated, e.Type)
+	position := e.Position
+
+	e = receive(t, events)
+	assert.Equal(t, vanity.EventRemoved, e.Type)
+	assert.Equal(t, "a.com/b", e.ImportPath)
+	assert.Nil(t, e.Entry)
+
+	// resuming replays the retained events after the position
+	resumed, err := b.Watch(ctx, position)
+	assert.NoError(t, err)
+	assert.Equal(t, vanity.EventRemoved, receive(t, resumed).Type)
+
+	_, err = b.Watch(ctx, "0")
+	assert.Equal(t, vanity.ErrInvalidPosition, err)
+	_, err = b.Watch(ctx, "42")
+	assert.Equal(t, vanity.ErrInvalidPosition, err)
+	_, err = b.Watch(ctx, "x")
+	assert.Equal(t, vanity.ErrInvalidPosition, err)
+
+	b.Close()
+
+	_, ok := <-events
+	assert.False(t, ok)
+
+	_, err = b.Watch(ctx, "")
+	assert.Equal(t, vanity.ErrAlreadyClosed, err)
+}
+
+func TestBroadcaster_cancel(t *testing.T) {
+	b := vanity.NewBroadcaster(vanity.DefaultRetainedEvents)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := b.Watch(ctx, "")
+	assert.NoError(t, err)
+
+	cancel()
+
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+func TestPoll(t *testing.T) {
+	be := memory.NewInMemoryAPI()
+	be.AddEntry("a.com/b", "git", "https://github.com/b")
+	be.AddEntry("a.com/c", "git", "https://github.com/c")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := vanity.Poll(ctx, be, time.Millisecond, "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, be.UpsertEntry(ctx, vanity.NewEntry("a.com/b", "git", "https://gitlab.com/b")))
+	assert.NoError(t, be.Remove(ctx, "a.com/c"))
+	assert.NoError(t, be.InsertEntry(ctx, vanity.NewEntry("a.com/d", "git", "https://github.com/d")))
+
+	seen := make(map[string]vanity.EventType)
+	var position string
+	for len(seen) < 3 {
+		e := receive(t, events)
+		seen[e.ImportPath] = e.Type
+		if e.ImportPath == "a.com/b" {
+			position = e.Position
+		}
+	}
+
+	assert.Equal(t, map[string]vanity.EventType{
+		"a.com/b": vanity.EventUpdated,
+		"a.com/c": vanity.EventRemoved,
+		"a.com/d": vanity.EventAdded,
+	}, seen)
+
+	// resuming delivers the configurations updated since the position
+	resumed, err := vanity.Poll(ctx, be, time.Hour, position)
+	assert.NoError(t, err)
+	e := receive(t, resumed)
+	assert.Equal(t, "a.com/d", e.ImportPath)
+	assert.Equal(t, vanity.EventAdded, e.Type)
+
+	_, err = vanity.Poll(ctx, be, time.Millisecond, "yesterday")
+	assert.Equal(t, vanity.ErrInvalidPosition, err)
+}